@@ -0,0 +1,357 @@
+package tablestore
+
+import (
+	"context"
+	"fmt"
+	aliTableStore "github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore/search"
+	"github.com/hughcube-go/tablestore/schema"
+	"reflect"
+	"strconv"
+)
+
+type AggregateResponse struct {
+	Error      error
+	Response   *aliTableStore.SearchResponse
+	TotalCount int64
+	AggregationResults
+
+	// 发起的Search请求数, 包含重试
+	Attempts int
+
+	// Search请求消耗的CU
+	CapacityUnit CapacityUnit
+}
+
+// Aggregate 使用多元索引做统计聚合和分组, 不返回行, 和Search一样排除软删除的行
+//
+//	response := client.Aggregate(&Model{}, "model_index", schema.Term("Status", 1), schema.Avg("avg_age", "Age"), schema.GroupByField("by_type", "Type"))
+//	avg, ok, err := response.Avg("avg_age")
+//	buckets, err := response.GroupBy("by_type")
+func (t *TableStore) Aggregate(model schema.Tabler, indexName string, query schema.Query, aggregators ...schema.Aggregator) AggregateResponse {
+	return t.AggregateCtx(context.Background(), model, indexName, query, aggregators...)
+}
+
+func (t *TableStore) AggregateCtx(ctx context.Context, model schema.Tabler, indexName string, query schema.Query, aggregators ...schema.Aggregator) (result AggregateResponse) {
+	ctx, op := t.startOperation(ctx, "Aggregate")
+	defer func() { result.Attempts, result.CapacityUnit = op.end(result.Error) }()
+
+	tableSchema, err := t.ParseSchema(model)
+	if err != nil {
+		return AggregateResponse{Error: err}
+	}
+
+	if 0 == len(aggregators) {
+		return AggregateResponse{Error: fmt.Errorf("aggregate on %s has no aggregator", indexName)}
+	}
+
+	config := &searchConfig{offset: -1, limit: 0, totalCount: true, aggregators: aggregators}
+	request, err := t.buildSearchRequest(tableSchema, model.TableName(), indexName, query, config)
+	if err != nil {
+		return AggregateResponse{Error: err}
+	}
+
+	response, err := t.search(ctx, request)
+	if err != nil {
+		return AggregateResponse{Error: err, Response: response}
+	}
+
+	return AggregateResponse{
+		Response:           response,
+		TotalCount:         response.TotalCount,
+		AggregationResults: AggregationResults{aggregations: response.AggregationResults, groupBys: response.GroupByResults},
+	}
+}
+
+// AggregationResults 统计聚合和分组的结果, 按照Aggregator的name获取
+type AggregationResults struct {
+	aggregations search.AggregationResults
+	groupBys     search.GroupByResults
+}
+
+func (r AggregationResults) Count(name string) (int64, error) {
+	result, err := r.aggregations.Count(name)
+	if err != nil {
+		return 0, err
+	}
+	return result.Value, nil
+}
+
+func (r AggregationResults) DistinctCount(name string) (int64, error) {
+	result, err := r.aggregations.DistinctCount(name)
+	if err != nil {
+		return 0, err
+	}
+	return result.Value, nil
+}
+
+func (r AggregationResults) Sum(name string) (float64, error) {
+	result, err := r.aggregations.Sum(name)
+	if err != nil {
+		return 0, err
+	}
+	return result.Value, nil
+}
+
+// Avg 没有任何行包含该字段的时候ok为false
+func (r AggregationResults) Avg(name string) (value float64, ok bool, err error) {
+	result, err := r.aggregations.Avg(name)
+	if err != nil || !result.HasValue() {
+		return 0, false, err
+	}
+	return result.Value, true, nil
+}
+
+func (r AggregationResults) Min(name string) (value float64, ok bool, err error) {
+	result, err := r.aggregations.Min(name)
+	if err != nil || !result.HasValue() {
+		return 0, false, err
+	}
+	return result.Value, true, nil
+}
+
+func (r AggregationResults) Max(name string) (value float64, ok bool, err error) {
+	result, err := r.aggregations.Max(name)
+	if err != nil || !result.HasValue() {
+		return 0, false, err
+	}
+	return result.Value, true, nil
+}
+
+// Bucket 分组中的一组, Key只对GroupByField有效, From和To只对GroupByRange有效
+type Bucket struct {
+	Key      string
+	From     float64
+	To       float64
+	RowCount int64
+	AggregationResults
+}
+
+// GroupBy 分组的结果, GroupByFilter的分组顺序和查询条件的顺序相同
+func (r AggregationResults) GroupBy(name string) ([]Bucket, error) {
+	raw, ok := r.groupBys.GetRawResults()[name]
+	if !ok {
+		return nil, fmt.Errorf("group by %s not found", name)
+	}
+
+	buckets := []Bucket{}
+	switch result := raw.(type) {
+	case *search.GroupByFieldResult:
+		for _, item := range result.Items {
+			buckets = append(buckets, Bucket{Key: item.Key, RowCount: item.RowCount, AggregationResults: AggregationResults{aggregations: item.SubAggregations, groupBys: item.SubGroupBys}})
+		}
+	case *search.GroupByRangeResult:
+		for _, item := range result.Items {
+			buckets = append(buckets, Bucket{From: item.From, To: item.To, RowCount: item.RowCount, AggregationResults: AggregationResults{aggregations: item.SubAggregations, groupBys: item.SubGroupBys}})
+		}
+	case *search.GroupByFilterResult:
+		for _, item := range result.Items {
+			buckets = append(buckets, Bucket{RowCount: item.RowCount, AggregationResults: AggregationResults{aggregations: item.SubAggregations, groupBys: item.SubGroupBys}})
+		}
+	case *search.GroupByGeoDistanceResult:
+		for _, item := range result.Items {
+			buckets = append(buckets, Bucket{From: item.From, To: item.To, RowCount: item.RowCount, AggregationResults: AggregationResults{aggregations: item.SubAggregations, groupBys: item.SubGroupBys}})
+		}
+	default:
+		return nil, fmt.Errorf("group by %s has unknown type", name)
+	}
+
+	return buckets, nil
+}
+
+// DecodeGroupBy 把分组的结果填充到list, list是结构体或者结构体指针的slice的指针, 规则和Bucket.Decode相同
+func (r AggregationResults) DecodeGroupBy(name string, list interface{}) error {
+	buckets, err := r.GroupBy(name)
+	if err != nil {
+		return err
+	}
+
+	listValue := reflect.ValueOf(list)
+	if listValue.Kind() != reflect.Ptr || listValue.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("list must be a pointer of slice, %T given", list)
+	}
+	return decodeBuckets(buckets, listValue.Elem())
+}
+
+// Decode 把分组填充到结构体, dest是结构体指针
+//
+// 字段名或者aggregation标签是Key, From, To, RowCount的时候填充分组的属性,
+// 其他的按照名称填充分组中统计聚合的结果, slice类型的字段按照名称填充分组中的分组, 找不到的字段保持不变
+//
+//	type Stat struct {
+//		Key      int64
+//		RowCount int64
+//		AvgAge   float64 `aggregation:"avg_age"`
+//	}
+func (b Bucket) Decode(dest interface{}) error {
+	value := reflect.ValueOf(dest)
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("dest must be a pointer of struct, %T given", dest)
+	}
+	return b.decode(value.Elem())
+}
+
+func (b Bucket) decode(value reflect.Value) error {
+	valueType := value.Type()
+	for index := 0; index < valueType.NumField(); index++ {
+		structField := valueType.Field(index)
+		if "" != structField.PkgPath {
+			continue
+		}
+
+		name := structField.Name
+		if tag, ok := structField.Tag.Lookup("aggregation"); ok {
+			if "-" == tag {
+				continue
+			}
+			name = tag
+		}
+
+		field := value.Field(index)
+		var err error
+		switch name {
+		case "Key":
+			err = setAggregationValue(field, b.Key)
+		case "From":
+			err = setAggregationValue(field, b.From)
+		case "To":
+			err = setAggregationValue(field, b.To)
+		case "RowCount":
+			err = setAggregationValue(field, b.RowCount)
+		default:
+			err = b.decodeResult(name, field)
+		}
+		if err != nil {
+			return fmt.Errorf("decode %s.%s: %w", valueType.Name(), structField.Name, err)
+		}
+	}
+	return nil
+}
+
+func (b Bucket) decodeResult(name string, field reflect.Value) error {
+	if field.Kind() == reflect.Slice && field.Type().Elem().Kind() != reflect.Uint8 {
+		if _, ok := b.groupBys.GetRawResults()[name]; !ok {
+			return nil
+		}
+
+		buckets, err := b.GroupBy(name)
+		if err != nil {
+			return err
+		}
+		return decodeBuckets(buckets, field)
+	}
+
+	raw, ok := b.aggregations.GetRawResults()[name]
+	if !ok {
+		return nil
+	}
+
+	switch result := raw.(type) {
+	case *search.CountAggregationResult:
+		return setAggregationValue(field, result.Value)
+	case *search.DistinctCountAggregationResult:
+		return setAggregationValue(field, result.Value)
+	case *search.SumAggregationResult:
+		return setAggregationValue(field, result.Value)
+	case *search.AvgAggregationResult:
+		if result.HasValue() {
+			return setAggregationValue(field, result.Value)
+		}
+	case *search.MinAggregationResult:
+		if result.HasValue() {
+			return setAggregationValue(field, result.Value)
+		}
+	case *search.MaxAggregationResult:
+		if result.HasValue() {
+			return setAggregationValue(field, result.Value)
+		}
+	}
+	return nil
+}
+
+func decodeBuckets(buckets []Bucket, list reflect.Value) error {
+	elemType := list.Type().Elem()
+	isPtr := elemType.Kind() == reflect.Ptr
+	if isPtr {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		return fmt.Errorf("%s is not a slice of struct", list.Type())
+	}
+
+	result := reflect.MakeSlice(list.Type(), len(buckets), len(buckets))
+	for index, bucket := range buckets {
+		item := reflect.New(elemType)
+		if err := bucket.decode(item.Elem()); err != nil {
+			return err
+		}
+
+		if isPtr {
+			result.Index(index).Set(item)
+		} else {
+			result.Index(index).Set(item.Elem())
+		}
+	}
+	list.Set(result)
+
+	return nil
+}
+
+// 把分组的值和统计结果转换成字段的类型, 分组的Key是字符串, 统计结果是int64或者float64
+func setAggregationValue(field reflect.Value, value interface{}) error {
+	if field.Kind() == reflect.Ptr {
+		item := reflect.New(field.Type().Elem())
+		if err := setAggregationValue(item.Elem(), value); err != nil {
+			return err
+		}
+		field.Set(item)
+		return nil
+	}
+
+	if text, ok := value.(string); ok {
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(text)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			number, err := strconv.ParseInt(text, 10, 64)
+			if err != nil {
+				return err
+			}
+			field.SetInt(number)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			number, err := strconv.ParseUint(text, 10, 64)
+			if err != nil {
+				return err
+			}
+			field.SetUint(number)
+		case reflect.Float32, reflect.Float64:
+			number, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return err
+			}
+			field.SetFloat(number)
+		case reflect.Bool:
+			boolean, err := strconv.ParseBool(text)
+			if err != nil {
+				return err
+			}
+			field.SetBool(boolean)
+		default:
+			return fmt.Errorf("can not convert %q to %s", text, field.Type())
+		}
+		return nil
+	}
+
+	source := reflect.ValueOf(value)
+	switch field.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		field.Set(source.Convert(field.Type()))
+	case reflect.String:
+		field.SetString(fmt.Sprint(value))
+	default:
+		return fmt.Errorf("can not convert %v to %s", value, field.Type())
+	}
+	return nil
+}
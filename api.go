@@ -0,0 +1,238 @@
+package tablestore
+
+import (
+	"context"
+	"errors"
+	aliTableStore "github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+)
+
+// Api 是TableStore发起请求时依赖的sdk方法, 默认由aliTableStore.TableStoreClient实现
+type Api interface {
+	PutRow(request *aliTableStore.PutRowRequest) (*aliTableStore.PutRowResponse, error)
+	GetRow(request *aliTableStore.GetRowRequest) (*aliTableStore.GetRowResponse, error)
+	GetRange(request *aliTableStore.GetRangeRequest) (*aliTableStore.GetRangeResponse, error)
+	BatchGetRow(request *aliTableStore.BatchGetRowRequest) (*aliTableStore.BatchGetRowResponse, error)
+	BatchWriteRow(request *aliTableStore.BatchWriteRowRequest) (*aliTableStore.BatchWriteRowResponse, error)
+	UpdateRow(request *aliTableStore.UpdateRowRequest) (*aliTableStore.UpdateRowResponse, error)
+	DeleteRow(request *aliTableStore.DeleteRowRequest) (*aliTableStore.DeleteRowResponse, error)
+}
+
+// TableApi 是表管理需要的sdk方法, Api同时实现的时候才能使用AutoMigrate等表管理的功能
+type TableApi interface {
+	CreateTable(request *aliTableStore.CreateTableRequest) (*aliTableStore.CreateTableResponse, error)
+	DescribeTable(request *aliTableStore.DescribeTableRequest) (*aliTableStore.DescribeTableResponse, error)
+	UpdateTable(request *aliTableStore.UpdateTableRequest) (*aliTableStore.UpdateTableResponse, error)
+	AddDefinedColumn(request *aliTableStore.AddDefinedColumnRequest) (*aliTableStore.AddDefinedColumnResponse, error)
+	CreateIndex(request *aliTableStore.CreateIndexRequest) (*aliTableStore.CreateIndexResponse, error)
+}
+
+// SearchApi 是多元索引查询需要的sdk方法
+type SearchApi interface {
+	Search(request *aliTableStore.SearchRequest) (*aliTableStore.SearchResponse, error)
+}
+
+// TransactionApi 是局部事务需要的sdk方法
+type TransactionApi interface {
+	StartLocalTransaction(request *aliTableStore.StartLocalTransactionRequest) (*aliTableStore.StartLocalTransactionResponse, error)
+	CommitTransaction(request *aliTableStore.CommitTransactionRequest) (*aliTableStore.CommitTransactionResponse, error)
+	AbortTransaction(request *aliTableStore.AbortTransactionRequest) (*aliTableStore.AbortTransactionResponse, error)
+}
+
+var ErrSearchApiNotImplemented = errors.New("api does not implement tablestore.SearchApi")
+
+var ErrTableApiNotImplemented = errors.New("api does not implement tablestore.TableApi")
+
+var ErrTransactionApiNotImplemented = errors.New("api does not implement tablestore.TransactionApi")
+
+// WithApi 替换发起请求的sdk, 比如测试时使用tablestoretest.Fake
+func WithApi(api Api) ClientOption {
+	return func(t *TableStore) {
+		t.api = api
+	}
+}
+
+// 在ctx取消或者超时的时候不再等待请求返回并返回ctx.Err(), sdk不支持取消, 已经发出的请求会继续执行,
+// 写入仍然可能在服务端生效, 所以ctx.Err()不能视为请求没有执行, sdk的错误转换成*Error
+func invoke[T any](ctx context.Context, call func() (T, error)) (T, error) {
+	var zero T
+	if err := ctx.Err(); err != nil {
+		return zero, err
+	}
+
+	type result struct {
+		response T
+		err      error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		response, err := call()
+		done <- result{response: response, err: err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return zero, ctx.Err()
+	case r := <-done:
+		return r.response, wrapError(r.err)
+	}
+}
+
+// 事务中的单行读写带上事务ID, 请求经过拦截器之后按照RetryPolicy重试, 非幂等的请求只在服务端确定没有执行的时候重试
+func (t *TableStore) putRow(ctx context.Context, request *aliTableStore.PutRowRequest) (*aliTableStore.PutRowResponse, error) {
+	if nil != t.transactionId {
+		request.PutRowChange.TransactionId = t.transactionId
+	}
+	return intercept(ctx, t, newCall(OperationPutRow, request, request.PutRowChange.TableName), isIdempotentPutRowRequest, func(request *aliTableStore.PutRowRequest) (*aliTableStore.PutRowResponse, error) {
+		return t.api.PutRow(request)
+	})
+}
+
+func (t *TableStore) getRow(ctx context.Context, request *aliTableStore.GetRowRequest) (*aliTableStore.GetRowResponse, error) {
+	if nil != t.transactionId {
+		request.SingleRowQueryCriteria.TransactionId = t.transactionId
+	}
+	return intercept(ctx, t, newCall(OperationGetRow, request, request.SingleRowQueryCriteria.TableName), idempotentRequest, func(request *aliTableStore.GetRowRequest) (*aliTableStore.GetRowResponse, error) {
+		return t.api.GetRow(request)
+	})
+}
+
+func (t *TableStore) getRange(ctx context.Context, request *aliTableStore.GetRangeRequest) (*aliTableStore.GetRangeResponse, error) {
+	return intercept(ctx, t, newCall(OperationGetRange, request, request.RangeRowQueryCriteria.TableName), idempotentRequest, func(request *aliTableStore.GetRangeRequest) (*aliTableStore.GetRangeResponse, error) {
+		return t.api.GetRange(request)
+	})
+}
+
+func (t *TableStore) batchGetRow(ctx context.Context, request *aliTableStore.BatchGetRowRequest) (*aliTableStore.BatchGetRowResponse, error) {
+	return intercept(ctx, t, newCall(OperationBatchGetRow, request, batchGetRowTableNames(request)...), idempotentRequest, func(request *aliTableStore.BatchGetRowRequest) (*aliTableStore.BatchGetRowResponse, error) {
+		return t.api.BatchGetRow(request)
+	})
+}
+
+func (t *TableStore) batchWriteRow(ctx context.Context, request *aliTableStore.BatchWriteRowRequest) (*aliTableStore.BatchWriteRowResponse, error) {
+	return intercept(ctx, t, newCall(OperationBatchWriteRow, request, batchWriteRowTableNames(request)...), isIdempotentBatchWrite, func(request *aliTableStore.BatchWriteRowRequest) (*aliTableStore.BatchWriteRowResponse, error) {
+		return t.api.BatchWriteRow(request)
+	})
+}
+
+func (t *TableStore) updateRow(ctx context.Context, request *aliTableStore.UpdateRowRequest) (*aliTableStore.UpdateRowResponse, error) {
+	if nil != t.transactionId {
+		request.UpdateRowChange.TransactionId = t.transactionId
+	}
+	return intercept(ctx, t, newCall(OperationUpdateRow, request, request.UpdateRowChange.TableName), isIdempotentUpdateRowRequest, func(request *aliTableStore.UpdateRowRequest) (*aliTableStore.UpdateRowResponse, error) {
+		return t.api.UpdateRow(request)
+	})
+}
+
+func (t *TableStore) deleteRow(ctx context.Context, request *aliTableStore.DeleteRowRequest) (*aliTableStore.DeleteRowResponse, error) {
+	if nil != t.transactionId {
+		request.DeleteRowChange.TransactionId = t.transactionId
+	}
+	return intercept(ctx, t, newCall(OperationDeleteRow, request, request.DeleteRowChange.TableName), isIdempotentDeleteRowRequest, func(request *aliTableStore.DeleteRowRequest) (*aliTableStore.DeleteRowResponse, error) {
+		return t.api.DeleteRow(request)
+	})
+}
+
+func (t *TableStore) tableApi() (TableApi, error) {
+	if api, ok := t.api.(TableApi); ok {
+		return api, nil
+	}
+	return nil, ErrTableApiNotImplemented
+}
+
+func (t *TableStore) createTable(ctx context.Context, request *aliTableStore.CreateTableRequest) (*aliTableStore.CreateTableResponse, error) {
+	api, err := t.tableApi()
+	if err != nil {
+		return nil, err
+	}
+	return intercept(ctx, t, newCall(OperationCreateTable, request, request.TableMeta.TableName), nonIdempotentRequest, func(request *aliTableStore.CreateTableRequest) (*aliTableStore.CreateTableResponse, error) {
+		return api.CreateTable(request)
+	})
+}
+
+func (t *TableStore) describeTable(ctx context.Context, request *aliTableStore.DescribeTableRequest) (*aliTableStore.DescribeTableResponse, error) {
+	api, err := t.tableApi()
+	if err != nil {
+		return nil, err
+	}
+	return intercept(ctx, t, newCall(OperationDescribeTable, request, request.TableName), idempotentRequest, func(request *aliTableStore.DescribeTableRequest) (*aliTableStore.DescribeTableResponse, error) {
+		return api.DescribeTable(request)
+	})
+}
+
+func (t *TableStore) updateTable(ctx context.Context, request *aliTableStore.UpdateTableRequest) (*aliTableStore.UpdateTableResponse, error) {
+	api, err := t.tableApi()
+	if err != nil {
+		return nil, err
+	}
+	return intercept(ctx, t, newCall(OperationUpdateTable, request, request.TableName), idempotentRequest, func(request *aliTableStore.UpdateTableRequest) (*aliTableStore.UpdateTableResponse, error) {
+		return api.UpdateTable(request)
+	})
+}
+
+func (t *TableStore) addDefinedColumn(ctx context.Context, request *aliTableStore.AddDefinedColumnRequest) (*aliTableStore.AddDefinedColumnResponse, error) {
+	api, err := t.tableApi()
+	if err != nil {
+		return nil, err
+	}
+	return intercept(ctx, t, newCall(OperationAddDefinedColumn, request, request.TableName), nonIdempotentRequest, func(request *aliTableStore.AddDefinedColumnRequest) (*aliTableStore.AddDefinedColumnResponse, error) {
+		return api.AddDefinedColumn(request)
+	})
+}
+
+func (t *TableStore) createIndex(ctx context.Context, request *aliTableStore.CreateIndexRequest) (*aliTableStore.CreateIndexResponse, error) {
+	api, err := t.tableApi()
+	if err != nil {
+		return nil, err
+	}
+	return intercept(ctx, t, newCall(OperationCreateIndex, request, request.MainTableName), nonIdempotentRequest, func(request *aliTableStore.CreateIndexRequest) (*aliTableStore.CreateIndexResponse, error) {
+		return api.CreateIndex(request)
+	})
+}
+
+func (t *TableStore) search(ctx context.Context, request *aliTableStore.SearchRequest) (*aliTableStore.SearchResponse, error) {
+	api, ok := t.api.(SearchApi)
+	if !ok {
+		return nil, ErrSearchApiNotImplemented
+	}
+	return intercept(ctx, t, newCall(OperationSearch, request, request.TableName), idempotentRequest, func(request *aliTableStore.SearchRequest) (*aliTableStore.SearchResponse, error) {
+		return api.Search(request)
+	})
+}
+
+func (t *TableStore) transactionApi() (TransactionApi, error) {
+	if api, ok := t.api.(TransactionApi); ok {
+		return api, nil
+	}
+	return nil, ErrTransactionApiNotImplemented
+}
+
+func (t *TableStore) startLocalTransaction(ctx context.Context, request *aliTableStore.StartLocalTransactionRequest) (*aliTableStore.StartLocalTransactionResponse, error) {
+	api, err := t.transactionApi()
+	if err != nil {
+		return nil, err
+	}
+	return intercept(ctx, t, newCall(OperationStartLocalTransaction, request, request.TableName), nonIdempotentRequest, func(request *aliTableStore.StartLocalTransactionRequest) (*aliTableStore.StartLocalTransactionResponse, error) {
+		return api.StartLocalTransaction(request)
+	})
+}
+
+func (t *TableStore) commitTransaction(ctx context.Context, request *aliTableStore.CommitTransactionRequest) (*aliTableStore.CommitTransactionResponse, error) {
+	api, err := t.transactionApi()
+	if err != nil {
+		return nil, err
+	}
+	return intercept(ctx, t, newCall(OperationCommitTransaction, request), nonIdempotentRequest, func(request *aliTableStore.CommitTransactionRequest) (*aliTableStore.CommitTransactionResponse, error) {
+		return api.CommitTransaction(request)
+	})
+}
+
+func (t *TableStore) abortTransaction(ctx context.Context, request *aliTableStore.AbortTransactionRequest) (*aliTableStore.AbortTransactionResponse, error) {
+	api, err := t.transactionApi()
+	if err != nil {
+		return nil, err
+	}
+	return intercept(ctx, t, newCall(OperationAbortTransaction, request), idempotentRequest, func(request *aliTableStore.AbortTransactionRequest) (*aliTableStore.AbortTransactionResponse, error) {
+		return api.AbortTransaction(request)
+	})
+}
@@ -0,0 +1,303 @@
+package tablestore
+
+import (
+	"context"
+	"errors"
+	aliTableStore "github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// BatchConfig 批量写入的分批和重试设置
+type BatchConfig struct {
+	// 单次请求的最大行数和最大字节数, 服务端的限制是200行和4MB
+	MaxRows  int
+	MaxBytes int
+
+	// BatchGetRow单次请求的最大行数, 服务端的限制是100行
+	MaxGetRows int
+
+	// 同时发起的请求数
+	Concurrency int
+
+	// 请求成功但是单行失败的时候这一行的最大尝试次数, 包含第一次, 是否重试按照RetryPolicy的规则判断,
+	// 整个请求失败的时候已经按照RetryPolicy重试过, 不再按行重试, 所以尝试次数不会是两者的乘积
+	MaxAttempts int
+
+	// 重试的初始等待时间, 每次重试翻倍并加上随机抖动, 不超过MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+func DefaultBatchConfig() BatchConfig {
+	return BatchConfig{
+		MaxRows:     200,
+		MaxBytes:    4 * 1024 * 1024,
+		MaxGetRows:  100,
+		Concurrency: 4,
+		MaxAttempts: 3,
+		Backoff:     50 * time.Millisecond,
+		MaxBackoff:  2 * time.Second,
+	}
+}
+
+func WithBatchConfig(config BatchConfig) ClientOption {
+	return func(t *TableStore) {
+		t.batchConfig = config
+	}
+}
+
+// BatchWriteRowResult 对应输入中的一行
+type BatchWriteRowResult struct {
+	Index     int
+	IsSucceed bool
+	Code      string
+	Message   string
+	RequestId string
+	Attempts  int
+
+	// 这一行每次成功的请求返回的CU的和, 整个请求失败的时候没有CU
+	CapacityUnit CapacityUnit
+}
+
+// Err 失败的行返回*Error, 成功返回nil
+func (r BatchWriteRowResult) Err() error {
+	if r.IsSucceed {
+		return nil
+	}
+	return newRowError(r.Code, r.Message, r.RequestId)
+}
+
+type BatchWriteResponse struct {
+	// 第一个请求级别的错误, 行级别的错误在Results中
+	Error        error
+	Responses    []*aliTableStore.BatchWriteRowResponse
+	Results      []BatchWriteRowResult
+	FailureCount int
+
+	// 发起的BatchWriteRow请求数, 包含按照BatchConfig拆分的请求, 请求的重试和失败的行的重试
+	Attempts int
+
+	// 成功的BatchWriteRow请求返回的CU的和
+	CapacityUnit CapacityUnit
+}
+
+// BatchWrite 按照BatchConfig把changes拆分成多个请求并发写入, 失败的行按照错误码和幂等性重试, Results和changes一一对应
+func (t *TableStore) BatchWrite(changes []aliTableStore.RowChange, options ...func(*aliTableStore.BatchWriteRowRequest)) BatchWriteResponse {
+	return t.BatchWriteCtx(context.Background(), changes, options...)
+}
+
+func (t *TableStore) BatchWriteCtx(ctx context.Context, changes []aliTableStore.RowChange, options ...func(*aliTableStore.BatchWriteRowRequest)) (result BatchWriteResponse) {
+	ctx, op := t.startOperation(ctx, "BatchWrite")
+	defer func() { result.Attempts, result.CapacityUnit = op.end(result.Error) }()
+
+	config := t.batchConfig
+
+	response := BatchWriteResponse{Results: make([]BatchWriteRowResult, len(changes))}
+	for index := range changes {
+		response.Results[index].Index = index
+	}
+
+	var mu sync.Mutex
+	response.Error = runBatch(
+		ctx, config, len(changes),
+		func(pending []int) [][]int {
+			return splitBatchWriteChunks(changes, pending, config.MaxRows, config.MaxBytes)
+		},
+		func(chunk []int) error {
+			chunkResponse, err := t.batchWriteChunk(ctx, changes, chunk, response.Results, options...)
+			if nil != chunkResponse {
+				mu.Lock()
+				response.Responses = append(response.Responses, chunkResponse)
+				mu.Unlock()
+			}
+			return err
+		},
+		func(index int) bool {
+			return t.isRetryableRowResult(changes[index], response.Results[index])
+		},
+	)
+
+	for index := range response.Results {
+		if result := &response.Results[index]; !result.IsSucceed && "" == result.Code && nil != response.Error {
+			result.Code, result.Message = aliTableStore.OTS_CLIENT_UNKNOWN, response.Error.Error()
+		}
+	}
+
+	for _, result := range response.Results {
+		if !result.IsSucceed {
+			response.FailureCount++
+		}
+	}
+
+	return response
+}
+
+// 把count行按照split分批, 并发调用send, 之后对retryable的行等待重试, 返回第一个请求级别的错误, ctx结束的时候返回ctx.Err(),
+// send返回错误的请求已经按照RetryPolicy重试过, 其中的行不再重试
+func runBatch(ctx context.Context, config BatchConfig, count int, split func(pending []int) [][]int, send func(chunk []int) error, retryable func(index int) bool) error {
+	pending := make([]int, 0, count)
+	for index := 0; index < count; index++ {
+		pending = append(pending, index)
+	}
+
+	var firstErr error
+	var mu sync.Mutex
+	requestFailed := map[int]bool{}
+	for attempt := 1; 0 < len(pending); attempt++ {
+		if 1 < attempt {
+			if err := sleepContext(ctx, backoffDuration(config.Backoff, config.MaxBackoff, attempt-1)); err != nil {
+				break
+			}
+		}
+
+		semaphore := make(chan struct{}, max(1, config.Concurrency))
+		wg := sync.WaitGroup{}
+		for _, chunk := range split(pending) {
+			wg.Add(1)
+			semaphore <- struct{}{}
+			go func(chunk []int) {
+				defer wg.Done()
+				defer func() { <-semaphore }()
+
+				if err := send(chunk); err != nil {
+					mu.Lock()
+					if nil == firstErr {
+						firstErr = err
+					}
+					for _, index := range chunk {
+						requestFailed[index] = true
+					}
+					mu.Unlock()
+				}
+			}(chunk)
+		}
+		wg.Wait()
+
+		if attempt >= config.MaxAttempts {
+			break
+		}
+
+		retry := []int{}
+		for _, index := range pending {
+			if !requestFailed[index] && retryable(index) {
+				retry = append(retry, index)
+			}
+		}
+		pending = retry
+	}
+
+	if err := ctx.Err(); err != nil && nil == firstErr {
+		firstErr = err
+	}
+
+	return firstErr
+}
+
+// 发送一个请求, 把每一行的结果写回results, 请求失败的时候整个chunk都视为失败
+func (t *TableStore) batchWriteChunk(ctx context.Context, changes []aliTableStore.RowChange, chunk []int, results []BatchWriteRowResult, options ...func(*aliTableStore.BatchWriteRowRequest)) (*aliTableStore.BatchWriteRowResponse, error) {
+	request := new(aliTableStore.BatchWriteRowRequest)
+	tableIndexes := map[string][]int{}
+	for _, index := range chunk {
+		tableName := changes[index].GetTableName()
+		tableIndexes[tableName] = append(tableIndexes[tableName], index)
+		request.AddRowChange(changes[index])
+		results[index].Attempts++
+	}
+
+	for _, option := range options {
+		option(request)
+	}
+
+	response, err := t.batchWriteRow(ctx, request)
+	if err != nil {
+		code, message, requestId := requestErrorResult(err)
+		for _, index := range chunk {
+			results[index].IsSucceed, results[index].Code, results[index].Message, results[index].RequestId = false, code, message, requestId
+		}
+		return response, err
+	}
+
+	for tableName, rowResults := range response.TableToRowsResult {
+		for _, rowResult := range rowResults {
+			if int(rowResult.Index) >= len(tableIndexes[tableName]) {
+				continue
+			}
+
+			index := tableIndexes[tableName][rowResult.Index]
+			results[index].IsSucceed = rowResult.IsSucceed
+			results[index].Code = rowResult.Error.Code
+			results[index].Message = rowResult.Error.Message
+			results[index].RequestId = response.RequestId
+			results[index].CapacityUnit = results[index].CapacityUnit.Add(rowCapacityUnit(rowResult.ConsumedCapacityUnit))
+		}
+	}
+
+	return response, nil
+}
+
+// 失败的行是否重试, 超时等错误的时候服务端可能已经写入, 非幂等的行只在确定没有执行的时候重试, 避免自增主键的行被写入两次
+func (t *TableStore) isRetryableRowResult(change aliTableStore.RowChange, result BatchWriteRowResult) bool {
+	return !result.IsSucceed && t.retryPolicy.shouldRetryCode(result.Code, isIdempotentRowChange(change))
+}
+
+// 请求失败的时候每一行的错误码和错误信息
+func requestErrorResult(err error) (code string, message string, requestId string) {
+	var tableStoreError *Error
+	if errors.As(err, &tableStoreError) {
+		return tableStoreError.Code, tableStoreError.Message, tableStoreError.RequestId
+	}
+	return aliTableStore.OTS_CLIENT_UNKNOWN, err.Error(), ""
+}
+
+// 按照行数和字节数拆分, 单行超过MaxBytes的时候单独作为一个请求, 由服务端返回错误
+func splitBatchWriteChunks(changes []aliTableStore.RowChange, indexes []int, maxRows int, maxBytes int) [][]int {
+	chunks := [][]int{}
+	chunk, chunkBytes := []int{}, 0
+	for _, index := range indexes {
+		size := len(changes[index].Serialize())
+		if 0 < len(chunk) && ((0 < maxRows && len(chunk) >= maxRows) || (0 < maxBytes && chunkBytes+size > maxBytes)) {
+			chunks = append(chunks, chunk)
+			chunk, chunkBytes = []int{}, 0
+		}
+		chunk = append(chunk, index)
+		chunkBytes += size
+	}
+
+	if 0 < len(chunk) {
+		chunks = append(chunks, chunk)
+	}
+
+	return chunks
+}
+
+// 第retry次重试前的等待时间, 指数增长并加上随机抖动
+func backoffDuration(base time.Duration, maxBackoff time.Duration, retry int) time.Duration {
+	if 0 >= base {
+		return 0
+	}
+
+	backoff := base
+	for i := 1; i < retry && (0 >= maxBackoff || backoff < maxBackoff); i++ {
+		backoff *= 2
+	}
+	backoff += time.Duration(rand.Int63n(int64(base)))
+
+	if 0 < maxBackoff && backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	return backoff
+}
+
+func sleepContext(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,251 @@
+package tablestore
+
+import (
+	aliTableStore "github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/hughcube-go/tablestore/tablestoretest"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type BatchTestModel struct {
+	Pk   int64  `tableStore:"primaryKey;column:pk;"`
+	Name string `tableStore:"column:name;"`
+}
+
+func (m *BatchTestModel) TableName() string {
+	return "batch_test"
+}
+
+func Test_Client_BatchInsert_Chunk(t *testing.T) {
+	a := assert.New(t)
+
+	config := DefaultBatchConfig()
+	config.Backoff = time.Millisecond
+	fake := tablestoretest.New()
+	client := New("", "", "", "", WithApi(fake), WithBatchConfig(config))
+
+	// 奇数行第一次返回可重试的错误, pk为10的行总是返回不可重试的错误
+	failed := map[int64]bool{}
+	fake.SetFault(func(operation string, tableName string, primaryKey *aliTableStore.PrimaryKey) error {
+		pk := primaryKey.PrimaryKeys[0].Value.(int64)
+		if 10 == pk {
+			return tablestoretest.NewError("OTSParameterInvalid", "invalid", http.StatusBadRequest)
+		}
+		if 1 == pk%2 && !failed[pk] {
+			failed[pk] = true
+			return tablestoretest.NewError(aliTableStore.SERVER_BUSY, "busy", http.StatusServiceUnavailable)
+		}
+		return nil
+	})
+
+	rows := []*BatchTestModel{}
+	for i := 0; i < 450; i++ {
+		rows = append(rows, &BatchTestModel{Pk: int64(i), Name: "batch"})
+	}
+
+	response := client.BatchInsert(rows)
+	a.Nil(response.Error)
+	a.Equal(1, response.FailureCount)
+	a.Len(response.Results, 450)
+	a.Equal(449, fake.RowCount("batch_test"))
+
+	// 第一次450行拆成3个请求, 第二次重试225行拆成2个请求
+	a.Equal(5, fake.Calls("BatchWriteRow"))
+
+	for index, result := range response.Results {
+		a.Equal(index, result.Index)
+		if 10 == index {
+			a.False(result.IsSucceed)
+			a.Equal("OTSParameterInvalid", result.Code)
+			a.Equal(1, result.Attempts)
+		} else if 1 == index%2 {
+			a.True(result.IsSucceed)
+			a.Equal(2, result.Attempts)
+		} else {
+			a.True(result.IsSucceed)
+			a.Equal(1, result.Attempts)
+		}
+	}
+}
+
+type BatchAutoIncrementTestModel struct {
+	Pk   int64  `tableStore:"primaryKey;column:pk;"`
+	ID   int64  `tableStore:"primaryKey;column:id;autoIncrement;"`
+	Name string `tableStore:"column:name;"`
+}
+
+func (m *BatchAutoIncrementTestModel) TableName() string {
+	return "batch_auto_increment_test"
+}
+
+func Test_Client_BatchWrite_RetryIdempotent(t *testing.T) {
+	a := assert.New(t)
+
+	config := DefaultBatchConfig()
+	config.Backoff = time.Millisecond
+	fake := tablestoretest.New()
+	client := New("", "", "", "", WithApi(fake), WithBatchConfig(config))
+
+	// 每一行第一次返回超时, 服务端可能已经写入
+	failed := map[string]bool{}
+	fake.SetFault(func(operation string, tableName string, primaryKey *aliTableStore.PrimaryKey) error {
+		if !failed[tableName] {
+			failed[tableName] = true
+			return tablestoretest.NewError(aliTableStore.STORAGE_TIMEOUT, "timeout", http.StatusServiceUnavailable)
+		}
+		return nil
+	})
+
+	// 自增主键的写入不是幂等的, 不重试
+	response := client.BatchInsert([]*BatchAutoIncrementTestModel{{Pk: 1, Name: "a"}})
+	a.Equal(1, response.FailureCount)
+	a.Equal(aliTableStore.STORAGE_TIMEOUT, response.Results[0].Code)
+	a.Equal(1, response.Results[0].Attempts)
+	a.Equal(0, fake.RowCount("batch_auto_increment_test"))
+
+	// 覆盖写入是幂等的, 重试
+	change := new(aliTableStore.PutRowChange)
+	change.TableName = "batch_test"
+	change.PrimaryKey = new(aliTableStore.PrimaryKey)
+	change.PrimaryKey.AddPrimaryKeyColumn("pk", int64(1))
+	change.AddColumn("name", "a")
+	change.SetCondition(aliTableStore.RowExistenceExpectation_IGNORE)
+	writeResponse := client.BatchWrite([]aliTableStore.RowChange{change})
+	a.Equal(0, writeResponse.FailureCount)
+	a.Equal(2, writeResponse.Results[0].Attempts)
+	a.Equal(1, fake.RowCount("batch_test"))
+}
+
+func Test_Client_QueryAll_Chunk(t *testing.T) {
+	a := assert.New(t)
+
+	config := DefaultBatchConfig()
+	config.Backoff = time.Millisecond
+	fake := tablestoretest.New()
+	client := New("", "", "", "", WithApi(fake), WithBatchConfig(config))
+
+	rows := []*BatchTestModel{}
+	for i := 0; i < 300; i++ {
+		rows = append(rows, &BatchTestModel{Pk: int64(i), Name: "batch"})
+	}
+	a.Nil(client.BatchInsert(rows).Error)
+
+	// 奇数行第一次返回可重试的错误, pk为150的行总是返回不可重试的错误
+	failed := map[int64]bool{}
+	fake.SetFault(func(operation string, tableName string, primaryKey *aliTableStore.PrimaryKey) error {
+		pk := primaryKey.PrimaryKeys[0].Value.(int64)
+		if 150 == pk {
+			return tablestoretest.NewError("OTSParameterInvalid", "invalid", http.StatusBadRequest)
+		}
+		if 1 == pk%2 && !failed[pk] {
+			failed[pk] = true
+			return tablestoretest.NewError(aliTableStore.SERVER_BUSY, "busy", http.StatusServiceUnavailable)
+		}
+		return nil
+	})
+
+	// 倒序查询349到100, 其中300以上的行不存在
+	keys := []*BatchTestModel{}
+	for pk := 349; pk >= 100; pk-- {
+		keys = append(keys, &BatchTestModel{Pk: int64(pk)})
+	}
+
+	response := client.QueryAll(&keys)
+	a.Nil(response.Error)
+	a.Equal(199, response.RowsAffected)
+	a.Len(response.Results, 250)
+	a.Len(response.NotFound, 50)
+	a.Equal([]int{199}, response.Failed)
+
+	// 第一次250行拆成3个请求, 第二次重试125行拆成2个请求
+	a.Equal(5, fake.Calls("BatchGetRow"))
+	a.Len(response.Responses, 5)
+
+	for index, pk := 0, int64(299); index < len(keys); index, pk = index+1, pk-1 {
+		if 150 == pk {
+			pk--
+		}
+		a.Equal(pk, keys[index].Pk)
+		a.Equal("batch", keys[index].Name)
+	}
+
+	for index, result := range response.Results {
+		a.Equal(index, result.Index)
+		if 199 == index {
+			a.False(result.IsSucceed)
+			a.Equal("OTSParameterInvalid", result.Code)
+		} else {
+			a.True(result.IsSucceed)
+			a.Equal(50 <= index, result.Exists)
+		}
+	}
+}
+
+// 前failures次BatchWriteRow整个请求返回限流
+type batchRequestFailApi struct {
+	*tablestoretest.Fake
+	failures int
+}
+
+func (api *batchRequestFailApi) BatchWriteRow(request *aliTableStore.BatchWriteRowRequest) (*aliTableStore.BatchWriteRowResponse, error) {
+	if 0 < api.failures {
+		api.failures--
+		return nil, tablestoretest.NewError(aliTableStore.SERVER_BUSY, "busy", http.StatusServiceUnavailable)
+	}
+	return api.Fake.BatchWriteRow(request)
+}
+
+func Test_Client_BatchWrite_RetryPolicy(t *testing.T) {
+	a := assert.New(t)
+
+	config := DefaultBatchConfig()
+	config.Backoff = time.Millisecond
+	policy := DefaultRetryPolicy()
+	policy.Backoff = time.Millisecond
+
+	// 整个请求失败的时候只按照RetryPolicy重试, 不再按行重试
+	api := &batchRequestFailApi{Fake: tablestoretest.New(), failures: 100}
+	client := New("", "", "", "", WithApi(api), WithBatchConfig(config), WithRetryPolicy(policy))
+	response := client.BatchInsert([]*BatchTestModel{{Pk: 1, Name: "a"}})
+	a.NotNil(response.Error)
+	a.Equal(policy.MaxAttempts, response.Attempts)
+	a.Equal(1, response.Results[0].Attempts)
+
+	api.failures = 1
+	response = client.BatchInsert([]*BatchTestModel{{Pk: 1, Name: "a"}})
+	a.Nil(response.Error)
+	a.Equal(2, response.Attempts)
+	a.Equal(1, api.RowCount("batch_test"))
+
+	// 默认的RetryPolicy不重试失败的请求
+	api.failures = 1
+	response = New("", "", "", "", WithApi(api), WithBatchConfig(config)).BatchInsert([]*BatchTestModel{{Pk: 2, Name: "b"}})
+	a.NotNil(response.Error)
+	a.Equal(1, response.Attempts)
+
+	// 单行失败按照RetryPolicy的错误码和幂等性判断
+	fake := tablestoretest.New()
+	fake.SetFault(func(operation string, tableName string, primaryKey *aliTableStore.PrimaryKey) error {
+		return tablestoretest.NewError(aliTableStore.SERVER_BUSY, "busy", http.StatusServiceUnavailable)
+	})
+	policy.Codes = map[string]bool{aliTableStore.SERVER_BUSY: false}
+	response = New("", "", "", "", WithApi(fake), WithBatchConfig(config), WithRetryPolicy(policy)).BatchInsert([]*BatchTestModel{{Pk: 1, Name: "a"}})
+	a.Equal(1, response.FailureCount)
+	a.Equal(1, response.Results[0].Attempts)
+
+	failed := false
+	fake.SetFault(func(operation string, tableName string, primaryKey *aliTableStore.PrimaryKey) error {
+		if !failed {
+			failed = true
+			return tablestoretest.NewError(aliTableStore.STORAGE_TIMEOUT, "timeout", http.StatusServiceUnavailable)
+		}
+		return nil
+	})
+	policy.Codes = nil
+	policy.RetryNonIdempotent = true
+	response = New("", "", "", "", WithApi(fake), WithBatchConfig(config), WithRetryPolicy(policy)).BatchInsert([]*BatchAutoIncrementTestModel{{Pk: 1, Name: "a"}})
+	a.Equal(0, response.FailureCount)
+	a.Equal(2, response.Results[0].Attempts)
+}
@@ -0,0 +1,198 @@
+package tablestore
+
+import (
+	"context"
+	aliTableStore "github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"sync"
+)
+
+// CapacityUnit 消耗的读写CU, 只统计sdk响应中返回的CU, 请求失败的时候sdk的错误不包含CU,
+// 所以重试之前失败的请求消耗的CU不会被统计, 实际消耗可能更多, 多元索引查询的sdk响应不包含CU
+type CapacityUnit struct {
+	Read  int64
+	Write int64
+}
+
+func (c CapacityUnit) Add(other CapacityUnit) CapacityUnit {
+	return CapacityUnit{Read: c.Read + other.Read, Write: c.Write + other.Write}
+}
+
+// CapacityAccumulator 按照表累计消耗的CU, 可以定时Reset之后上报
+type CapacityAccumulator struct {
+	mu     sync.Mutex
+	tables map[string]CapacityUnit
+}
+
+func NewCapacityAccumulator() *CapacityAccumulator {
+	return &CapacityAccumulator{tables: map[string]CapacityUnit{}}
+}
+
+// WithCapacityAccumulator 多个客户端共用一个CapacityAccumulator, 默认每个客户端单独累计
+func WithCapacityAccumulator(accumulator *CapacityAccumulator) ClientOption {
+	return func(t *TableStore) {
+		t.capacity = accumulator
+	}
+}
+
+func (a *CapacityAccumulator) add(tableName string, capacityUnit CapacityUnit) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.tables[tableName] = a.tables[tableName].Add(capacityUnit)
+}
+
+// Snapshot 每个表累计消耗的CU
+func (a *CapacityAccumulator) Snapshot() map[string]CapacityUnit {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	snapshot := make(map[string]CapacityUnit, len(a.tables))
+	for tableName, capacityUnit := range a.tables {
+		snapshot[tableName] = capacityUnit
+	}
+	return snapshot
+}
+
+// Reset 返回Snapshot并且重新开始累计
+func (a *CapacityAccumulator) Reset() map[string]CapacityUnit {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	snapshot := a.tables
+	a.tables = map[string]CapacityUnit{}
+	return snapshot
+}
+
+// Total 所有表累计消耗的CU
+func (a *CapacityAccumulator) Total() CapacityUnit {
+	var total CapacityUnit
+	for _, capacityUnit := range a.Snapshot() {
+		total = total.Add(capacityUnit)
+	}
+	return total
+}
+
+// CapacityUnits 客户端累计消耗的CU, Unscoped和事务等派生的客户端共用
+func (t *TableStore) CapacityUnits() *CapacityAccumulator {
+	return t.capacity
+}
+
+// 累计到客户端和ctx中正在统计的调用, 失败的请求没有响应, 不统计
+func (t *TableStore) recordUsage(ctx context.Context, call *Call, response interface{}) {
+	usages := responseUsage(call, response)
+	addCallUsage(ctx, call, usages)
+	for tableName, usage := range usages {
+		capacityUnit := usage.capacityUnit()
+		if 0 == capacityUnit.Read && 0 == capacityUnit.Write {
+			continue
+		}
+		if nil != t.capacity {
+			t.capacity.add(tableName, capacityUnit)
+		}
+		addCapacityUnit(ctx, capacityUnit)
+	}
+}
+
+func rowCapacityUnit(consumed *aliTableStore.ConsumedCapacityUnit) CapacityUnit {
+	if nil == consumed {
+		return CapacityUnit{}
+	}
+	return CapacityUnit{Read: int64(consumed.Read), Write: int64(consumed.Write)}
+}
+
+// 一个表的读写行数和消耗的CU
+type tableUsage struct {
+	RowsRead          int64
+	RowsWritten       int64
+	RowsFailed        int64
+	ReadCapacityUnit  int64
+	WriteCapacityUnit int64
+}
+
+func (u *tableUsage) add(other *tableUsage) {
+	u.RowsRead += other.RowsRead
+	u.RowsWritten += other.RowsWritten
+	u.RowsFailed += other.RowsFailed
+	u.ReadCapacityUnit += other.ReadCapacityUnit
+	u.WriteCapacityUnit += other.WriteCapacityUnit
+}
+
+func (u *tableUsage) capacityUnit() CapacityUnit {
+	return CapacityUnit{Read: u.ReadCapacityUnit, Write: u.WriteCapacityUnit}
+}
+
+func (u *tableUsage) addCapacityUnit(consumed *aliTableStore.ConsumedCapacityUnit) {
+	capacityUnit := rowCapacityUnit(consumed)
+	u.ReadCapacityUnit += capacityUnit.Read
+	u.WriteCapacityUnit += capacityUnit.Write
+}
+
+// 按照表统计sdk响应中读写的行数和消耗的CU
+func responseUsage(call *Call, response interface{}) map[string]*tableUsage {
+	usages := map[string]*tableUsage{}
+	usage := func(tableName string) *tableUsage {
+		if _, ok := usages[tableName]; !ok {
+			usages[tableName] = &tableUsage{}
+		}
+		return usages[tableName]
+	}
+
+	switch response := response.(type) {
+	case *aliTableStore.PutRowResponse:
+		if nil != response {
+			usage(call.TableName()).RowsWritten++
+			usage(call.TableName()).addCapacityUnit(response.ConsumedCapacityUnit)
+		}
+	case *aliTableStore.UpdateRowResponse:
+		if nil != response {
+			usage(call.TableName()).RowsWritten++
+			usage(call.TableName()).addCapacityUnit(response.ConsumedCapacityUnit)
+		}
+	case *aliTableStore.DeleteRowResponse:
+		if nil != response {
+			usage(call.TableName()).RowsWritten++
+			usage(call.TableName()).addCapacityUnit(response.ConsumedCapacityUnit)
+		}
+	case *aliTableStore.GetRowResponse:
+		if nil != response {
+			if 0 < len(response.PrimaryKey.PrimaryKeys) {
+				usage(call.TableName()).RowsRead++
+			}
+			usage(call.TableName()).addCapacityUnit(response.ConsumedCapacityUnit)
+		}
+	case *aliTableStore.GetRangeResponse:
+		if nil != response {
+			usage(call.TableName()).RowsRead += int64(len(response.Rows))
+			usage(call.TableName()).addCapacityUnit(response.ConsumedCapacityUnit)
+		}
+	case *aliTableStore.SearchResponse:
+		if nil != response {
+			usage(call.TableName()).RowsRead += int64(len(response.Rows))
+		}
+	case *aliTableStore.BatchGetRowResponse:
+		if nil != response {
+			for tableName, results := range response.TableToRowsResult {
+				for _, result := range results {
+					switch {
+					case !result.IsSucceed:
+						usage(tableName).RowsFailed++
+					case 0 < len(result.PrimaryKey.PrimaryKeys):
+						usage(tableName).RowsRead++
+					}
+					usage(tableName).addCapacityUnit(result.ConsumedCapacityUnit)
+				}
+			}
+		}
+	case *aliTableStore.BatchWriteRowResponse:
+		if nil != response {
+			for tableName, results := range response.TableToRowsResult {
+				for _, result := range results {
+					if result.IsSucceed {
+						usage(tableName).RowsWritten++
+					} else {
+						usage(tableName).RowsFailed++
+					}
+					usage(tableName).addCapacityUnit(result.ConsumedCapacityUnit)
+				}
+			}
+		}
+	}
+	return usages
+}
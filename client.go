@@ -4,14 +4,32 @@ import (
 	aliTableStore "github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
 	"github.com/hughcube-go/tablestore/schema"
 	"sync"
+	"time"
 )
 
 type ClientOption func(*TableStore)
 
 type TableStore struct {
 	*aliTableStore.TableStoreClient
-	schemaCache *sync.Map
-	config      Config
+	api          Api
+	schemaCache  *sync.Map
+	codecs       *schema.CodecRegistry
+	config       Config
+	clock        func() time.Time
+	unscoped     bool
+	batchConfig  BatchConfig
+	retryPolicy  RetryPolicy
+	interceptors []Interceptor
+	telemetry    *telemetry
+	capacity     *CapacityAccumulator
+	where        *schema.Filter
+	index        *indexConfig
+
+	// 没有索引软删除字段的多元索引, 查询时不排除软删除的行
+	searchIndexesWithoutSoftDelete map[string]bool
+
+	// 在Transaction中使用, 单行读写带上事务ID
+	transactionId *string
 }
 
 func New(endPoint, instanceName, accessKeyId, accessKeySecret string, options ...ClientOption) *TableStore {
@@ -24,12 +42,43 @@ func New(endPoint, instanceName, accessKeyId, accessKeySecret string, options ..
 	})
 }
 
+// WithCodec 注册Codec, sample的类型的字段都使用codec转换
+//
+//	client := tablestore.New(endPoint, instanceName, accessKeyId, accessKeySecret, tablestore.WithCodec(decimal.Decimal{}, decimalCodec))
+func WithCodec(sample interface{}, codec schema.Codec) ClientOption {
+	return func(t *TableStore) {
+		t.codecs.Register(sample, codec)
+	}
+}
+
+// WithNamedCodec 注册Codec的名称, 字段使用codec:name标签指定
+func WithNamedCodec(name string, codec schema.Codec) ClientOption {
+	return func(t *TableStore) {
+		t.codecs.RegisterName(name, codec)
+	}
+}
+
+// WithClock 替换获取当前时间的方法, 比如测试时固定时间
+func WithClock(clock func() time.Time) ClientOption {
+	return func(t *TableStore) {
+		t.clock = clock
+	}
+}
+
 func NewWithConfig(config Config) *TableStore {
 	client := &TableStore{
 		TableStoreClient: aliTableStore.NewClient(config.EndPoint, config.InstanceName, config.AccessKeyId, config.AccessKeySecret),
 		schemaCache:      new(sync.Map),
+		codecs:           schema.NewCodecRegistry(),
+		capacity:         NewCapacityAccumulator(),
 		config:           config,
 	}
+	client.api = client.TableStoreClient
+	client.clock = time.Now
+	client.batchConfig = DefaultBatchConfig()
+	if nil != config.RetryPolicy {
+		client.retryPolicy = *config.RetryPolicy
+	}
 
 	for _, option := range config.Options {
 		option(client)
@@ -38,14 +87,29 @@ func NewWithConfig(config Config) *TableStore {
 	return client
 }
 
+// Unscoped 返回一个忽略软删除的客户端, 查询时包含已经软删除的行, 删除时物理删除
+func (t *TableStore) Unscoped() *TableStore {
+	client := *t
+	client.unscoped = true
+	return &client
+}
+
 func (t *TableStore) ParseSchema(dest interface{}) (*schema.Schema, error) {
-	return schema.Parse(dest, t.schemaCache)
+	return schema.ParseWithCodecs(dest, t.schemaCache, t.codecs)
 }
 
 func (t *TableStore) GetSdk() *aliTableStore.TableStoreClient {
 	return t.TableStoreClient
 }
 
+func (t *TableStore) GetApi() Api {
+	return t.api
+}
+
+func (t *TableStore) Now() time.Time {
+	return t.clock()
+}
+
 func (t *TableStore) GetConfig() Config {
 	return t.config
 }
@@ -1,12 +1,24 @@
 package tablestore
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	aliTableStore "github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore/search"
 	"github.com/hughcube-go/tablestore/schema"
+	"github.com/hughcube-go/tablestore/tablestoretest"
+	"github.com/hughcube-go/tablestore/tablestoretest/telemetrytest"
 	"github.com/hughcube-go/timestamps"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"math"
+	"net/http"
 	"os"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -77,6 +89,11 @@ func client_test_model() (*TestModel, sql.NullTime) {
 }
 
 func client_test_client() *TableStore {
+	// 没有配置实例的时候使用进程内的Fake
+	if "" == os.Getenv("ALIYUN_OTS_END_POINT") {
+		return New("", "", "", "", WithApi(tablestoretest.New()))
+	}
+
 	client := New(
 		os.Getenv("ALIYUN_OTS_END_POINT"),
 		os.Getenv("ALIYUN_OTS_INSTANCE_NAME"),
@@ -215,6 +232,49 @@ func Test_Client_QueryRange(t *testing.T) {
 	a.True(len(rows) > 0)
 }
 
+func Test_Client_QueryRange_Direction(t *testing.T) {
+	a := assert.New(t)
+
+	var direction aliTableStore.Direction
+	client := New("", "", "", "", WithApi(tablestoretest.New()), WithInterceptor(func(ctx context.Context, call *Call, next Handler) (interface{}, error) {
+		if request, ok := call.Request.(*aliTableStore.GetRangeRequest); ok {
+			direction = request.RangeRowQueryCriteria.Direction
+		}
+		return next(ctx, call)
+	}))
+	a.Nil(client.BatchInsert([]*RetryTestModel{{Pk: 1, Name: "a"}, {Pk: 2, Name: "b"}, {Pk: 3, Name: "c"}}).Error)
+
+	// 不翻页的时候start不是MinPrimaryKey就倒序
+	var rows []*RetryTestModel
+	a.Nil(client.QueryRange(&rows, schema.MinPrimaryKey{"Pk": 2}, schema.MaxPrimaryKey{}, 10).Error)
+	a.Equal(aliTableStore.FORWARD, direction)
+	a.Len(rows, 2)
+
+	a.Nil(client.QueryRange(&rows, schema.MaxPrimaryKey{"Pk": 2}, schema.MinPrimaryKey{}, 10).Error)
+	a.Equal(aliTableStore.BACKWARD, direction)
+	a.Equal(int64(2), rows[0].Pk)
+
+	primaryKey := new(aliTableStore.PrimaryKey)
+	primaryKey.AddPrimaryKeyColumn("pk", int64(2))
+	a.Nil(client.QueryRange(&rows, primaryKey, schema.MinPrimaryKey{}, 10).Error)
+	a.Equal(aliTableStore.BACKWARD, direction)
+
+	// 翻页的时候指定方向
+	a.Nil(client.QueryRange(&rows, primaryKey, schema.MaxPrimaryKey{}, 10, WithRangeDirection(aliTableStore.FORWARD)).Error)
+	a.Equal(aliTableStore.FORWARD, direction)
+	a.Len(rows, 2)
+
+	// Repository.Range根据end判断翻页的方向
+	repository := NewRepository[RetryTestModel](client)
+	list, next, err := repository.Range(context.Background(), schema.MinPrimaryKey{}, schema.MaxPrimaryKey{}, 1)
+	a.Nil(err)
+	a.Equal(int64(1), list[0].Pk)
+	list, _, err = repository.Range(context.Background(), next, schema.MaxPrimaryKey{}, 1)
+	a.Nil(err)
+	a.Equal(aliTableStore.FORWARD, direction)
+	a.Equal(int64(2), list[0].Pk)
+}
+
 func Test_Client_UpdateOne(t *testing.T) {
 	a := assert.New(t)
 
@@ -237,3 +297,1430 @@ func Test_Client_UpdateOne(t *testing.T) {
 	a.Equal("111111", *row.StringPtr1Column)
 	a.Equal(true, row.BoolColumn)
 }
+
+func Test_Client_AutoTime(t *testing.T) {
+	a := assert.New(t)
+
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	client := New("", "", "", "", WithApi(tablestoretest.New()), WithClock(func() time.Time {
+		return now
+	}))
+
+	row := &Model{}
+	insertResponse := client.Insert(row)
+	a.Nil(insertResponse.Error)
+	a.True(now.Equal(row.CreatedAt.Time))
+	a.True(now.Equal(row.UpdatedAt.Time))
+	a.False(row.DeletedAt.Valid)
+	row.ID = insertResponse.LastId
+
+	now = now.Add(time.Hour)
+	updateResponse := client.UpdateOne(row, map[string]interface{}{})
+	a.Nil(updateResponse.Error)
+
+	queryRow := &Model{ID: row.ID}
+	a.Nil(client.QueryOne(queryRow).Error)
+	a.True(now.Add(-time.Hour).Equal(queryRow.CreatedAt.Time))
+	a.True(now.Equal(queryRow.UpdatedAt.Time))
+}
+
+func Test_Client_SoftDelete(t *testing.T) {
+	a := assert.New(t)
+
+	client := client_test_client()
+
+	row := &Model{}
+	insertResponse := client.Insert(row)
+	a.Nil(insertResponse.Error)
+	row.ID = insertResponse.LastId
+
+	deleteResponse := client.DeleteOne(row)
+	a.Nil(deleteResponse.Error)
+	a.Equal(1, deleteResponse.RowsAffected)
+	a.True(row.DeletedAt.Valid)
+
+	a.False(client.QueryOne(&Model{ID: row.ID}).Exists)
+
+	var rows []*Model
+	a.Nil(client.QueryRange(&rows, schema.MinPrimaryKey{}, schema.MaxPrimaryKey{}, 10).Error)
+	a.Len(rows, 0)
+
+	queryRow := &Model{ID: row.ID}
+	a.True(client.Unscoped().QueryOne(queryRow).Exists)
+	a.True(queryRow.DeletedAt.Valid)
+
+	// 重复删除不覆盖删除时间
+	deletedAt := queryRow.DeletedAt.Time
+	deleteResponse = client.DeleteOne(&Model{ID: row.ID})
+	a.Nil(deleteResponse.Error)
+	a.Equal(0, deleteResponse.RowsAffected)
+	queryRow = &Model{ID: row.ID}
+	a.True(client.Unscoped().QueryOne(queryRow).Exists)
+	a.True(deletedAt.Equal(queryRow.DeletedAt.Time))
+
+	deleteResponse = client.ForceDelete(row)
+	a.Nil(deleteResponse.Error)
+	a.Equal(1, deleteResponse.RowsAffected)
+	a.False(client.Unscoped().QueryOne(&Model{ID: row.ID}).Exists)
+
+	deleteResponse = client.DeleteOne(row)
+	a.Nil(deleteResponse.Error)
+	a.Equal(0, deleteResponse.RowsAffected)
+
+	// Where条件不满足的时候返回错误
+	row = &Model{}
+	row.ID = client.Insert(row).LastId
+	deleteResponse = client.Where(schema.Eq("CreatedAt", nil)).DeleteOne(row)
+	a.True(IsConditionFailed(deleteResponse.Error))
+	a.Equal(0, deleteResponse.RowsAffected)
+	a.True(client.QueryOne(&Model{ID: row.ID}).Exists)
+}
+
+type client_test_slow_api struct {
+	*tablestoretest.Fake
+	delay time.Duration
+}
+
+func (api client_test_slow_api) GetRow(request *aliTableStore.GetRowRequest) (*aliTableStore.GetRowResponse, error) {
+	time.Sleep(api.delay)
+	return api.Fake.GetRow(request)
+}
+
+func (api client_test_slow_api) PutRow(request *aliTableStore.PutRowRequest) (*aliTableStore.PutRowResponse, error) {
+	time.Sleep(api.delay)
+	return api.Fake.PutRow(request)
+}
+
+func Test_Client_Context(t *testing.T) {
+	a := assert.New(t)
+
+	client := New("", "", "", "", WithApi(client_test_slow_api{Fake: tablestoretest.New(), delay: time.Second}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	a.Equal(context.Canceled, client.InsertCtx(ctx, &Model{}).Error)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	begin := time.Now()
+	a.Equal(context.DeadlineExceeded, client.QueryOneCtx(ctx, &Model{ID: 1}).Error)
+	a.True(time.Since(begin) < time.Second)
+
+	// 超时的写入不会重试, 但是已经发出的请求仍然会在服务端生效
+	fake := tablestoretest.New()
+	policy := DefaultRetryPolicy()
+	policy.RetryNonIdempotent = true
+	client = New("", "", "", "", WithApi(client_test_slow_api{Fake: fake, delay: 50 * time.Millisecond}), WithRetryPolicy(policy))
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	response := client.InsertCtx(ctx, &BatchTestModel{Pk: 1, Name: "a"})
+	a.Equal(context.DeadlineExceeded, response.Error)
+	a.Equal(1, response.Attempts)
+	a.Eventually(func() bool { return 1 == fake.RowCount("batch_test") }, time.Second, 10*time.Millisecond)
+	a.Equal(1, fake.Calls("PutRow"))
+}
+
+func Test_Client_Where(t *testing.T) {
+	a := assert.New(t)
+
+	client := New("", "", "", "", WithApi(tablestoretest.New()))
+
+	rows := []*BatchTestModel{{Pk: 1, Name: "a"}, {Pk: 2, Name: "b"}, {Pk: 3, Name: "c"}}
+	a.Nil(client.BatchInsert(rows).Error)
+
+	// 写入条件
+	a.NotNil(client.Where(schema.Eq("Name", "x")).UpdateOne(&BatchTestModel{Pk: 1}, map[string]interface{}{"Name": "y"}).Error)
+	a.Nil(client.Where(schema.Eq("Name", "a")).UpdateOne(&BatchTestModel{Pk: 1}, map[string]interface{}{"Name": "y"}).Error)
+	a.NotNil(client.Where(schema.NotExistsRow()).UpdateOne(&BatchTestModel{Pk: 1}, map[string]interface{}{"Name": "z"}).Error)
+	a.NotNil(client.Where(schema.ExistsRow()).UpdateOne(&BatchTestModel{Pk: 4}, map[string]interface{}{"Name": "z"}).Error)
+	a.Nil(client.Where(schema.ExistsRow()).Where(schema.Eq("name", "b")).Insert(&BatchTestModel{Pk: 2, Name: "bb"}).Error)
+	a.NotNil(client.Where(schema.Or(schema.Eq("Name", "x"), schema.Not(schema.Eq("Name", "c")))).DeleteOne(&BatchTestModel{Pk: 3}).Error)
+
+	// 读取过滤
+	var list []*BatchTestModel
+	a.Nil(client.Where(schema.Ne("Name", "bb")).QueryRange(&list, schema.MinPrimaryKey{}, schema.MaxPrimaryKey{}, 10).Error)
+	a.Len(list, 2)
+
+	keys := []*BatchTestModel{{Pk: 1}, {Pk: 2}, {Pk: 3}}
+	response := client.Where(schema.Eq("Name", "bb").Or(schema.Eq("Name", "c"))).QueryAll(&keys)
+	a.Nil(response.Error)
+	a.Equal([]int{0}, response.NotFound)
+	a.Len(keys, 2)
+
+	a.False(client.Where(schema.Eq("Name", "a")).QueryOne(&BatchTestModel{Pk: 1}).Exists)
+	a.True(client.Where(schema.Eq("Name", "y")).QueryOne(&BatchTestModel{Pk: 1}).Exists)
+
+	// 字段不存在或者读取时使用行条件
+	a.NotNil(client.Where(schema.Eq("Unknown", 1)).QueryOne(&BatchTestModel{Pk: 1}).Error)
+	a.NotNil(client.Where(schema.ExistsRow()).QueryOne(&BatchTestModel{Pk: 1}).Error)
+	a.NotNil(client.Where(schema.Not(schema.ExistsRow())).UpdateOne(&BatchTestModel{Pk: 1}, map[string]interface{}{"Name": "z"}).Error)
+
+	// 和软删除的过滤同时生效
+	deleted := &Model{}
+	deleted.ID = client.Insert(deleted).LastId
+	a.Nil(client.DeleteOne(deleted).Error)
+	a.Nil(client.Insert(&Model{}).Error)
+
+	var models []*Model
+	a.Nil(client.Where(schema.Ne("CreatedAt", nil)).QueryRange(&models, schema.MinPrimaryKey{}, schema.MaxPrimaryKey{}, 10).Error)
+	a.Len(models, 1)
+}
+
+type VersionTestModel struct {
+	Pk      int64  `tableStore:"primaryKey;column:pk;"`
+	Name    string `tableStore:"column:name;"`
+	Version int64  `tableStore:"version;column:version;"`
+}
+
+func (m *VersionTestModel) TableName() string {
+	return "version_test"
+}
+
+func Test_Client_Version(t *testing.T) {
+	a := assert.New(t)
+
+	client := New("", "", "", "", WithApi(tablestoretest.New()))
+	a.Nil(client.Insert(&VersionTestModel{Pk: 1, Name: "a"}).Error)
+
+	first, second := &VersionTestModel{Pk: 1}, &VersionTestModel{Pk: 1}
+	a.True(client.QueryOne(first).Exists)
+	a.True(client.QueryOne(second).Exists)
+
+	a.Nil(client.UpdateOne(first, map[string]interface{}{"Name": "b"}).Error)
+	a.Equal(int64(1), first.Version)
+	a.Equal("b", first.Name)
+
+	response := client.UpdateOne(second, map[string]interface{}{"Name": "c"})
+	a.Equal(ErrStaleObject, response.Error)
+	a.Equal(int64(0), second.Version)
+
+	a.Nil(client.UpdateOne(first, map[string]interface{}{"Name": "d"}).Error)
+	a.Equal(int64(2), first.Version)
+
+	// Where条件不满足的时候返回原来的错误, 不是ErrStaleObject
+	response = client.Where(schema.Eq("Name", "x")).UpdateOne(first, map[string]interface{}{"Name": "e"})
+	a.True(IsConditionFailed(response.Error))
+	a.NotEqual(ErrStaleObject, response.Error)
+	a.Equal(int64(2), first.Version)
+
+	row := &VersionTestModel{Pk: 1}
+	a.True(client.QueryOne(row).Exists)
+	a.Equal("d", row.Name)
+	a.Equal(int64(2), row.Version)
+
+	// 行已经删除
+	a.Nil(client.DeleteOne(row).Error)
+	a.Equal(ErrStaleObject, client.UpdateOne(first, map[string]interface{}{"Name": "e"}).Error)
+
+	// 增加版本字段之前写入的行没有版本列, 版本为0的时候允许更新
+	change := new(aliTableStore.PutRowChange)
+	change.TableName = "version_test"
+	change.PrimaryKey = new(aliTableStore.PrimaryKey)
+	change.PrimaryKey.AddPrimaryKeyColumn("pk", int64(2))
+	change.AddColumn("name", "a")
+	change.SetCondition(aliTableStore.RowExistenceExpectation_IGNORE)
+	a.Nil(client.BatchWrite([]aliTableStore.RowChange{change}).Error)
+
+	legacy := &VersionTestModel{Pk: 2}
+	a.True(client.QueryOne(legacy).Exists)
+	a.Nil(client.UpdateOne(legacy, map[string]interface{}{"Name": "b"}).Error)
+	a.Equal(int64(1), legacy.Version)
+	a.Equal(ErrStaleObject, client.UpdateOne(&VersionTestModel{Pk: 2}, map[string]interface{}{"Name": "c"}).Error)
+}
+
+type MigrateTestModel struct {
+	Pk   string `tableStore:"primaryKey;column:pk;sort:1;"`
+	ID   int64  `tableStore:"primaryKey;column:id;autoIncrement;sort:2;"`
+	Name string `tableStore:"column:name;definedColumn;"`
+}
+
+func (m *MigrateTestModel) TableName() string {
+	return "migrate_test"
+}
+
+type MigrateTestModelV2 struct {
+	MigrateTestModel
+	Age int64 `tableStore:"column:age;definedColumn;"`
+}
+
+func (m *MigrateTestModelV2) TableOptions() schema.TableOptions {
+	return schema.TableOptions{TimeToAlive: 86400, MaxVersions: 1}
+}
+
+type MigrateTestModelV3 struct {
+	Pk   int64  `tableStore:"primaryKey;column:pk;"`
+	Name string `tableStore:"column:name;definedColumn;"`
+}
+
+func (m *MigrateTestModelV3) TableName() string {
+	return "migrate_test"
+}
+
+func Test_Client_AutoMigrate(t *testing.T) {
+	a := assert.New(t)
+
+	fake := tablestoretest.New()
+	client := New("", "", "", "", WithApi(fake))
+
+	plan := client.PlanMigrate(&MigrateTestModel{})
+	a.Nil(plan.Error)
+	a.True(plan.Results[0].Created)
+	a.False(plan.Results[0].Applied)
+	a.Equal(0, fake.Calls("CreateTable"))
+
+	response := client.AutoMigrate(&MigrateTestModel{})
+	a.Nil(response.Error)
+	a.True(response.Results[0].Created)
+	a.True(response.Results[0].Applied)
+
+	describe, err := fake.DescribeTable(&aliTableStore.DescribeTableRequest{TableName: "migrate_test"})
+	a.Nil(err)
+	a.Len(describe.TableMeta.SchemaEntry, 2)
+	a.Equal("pk", *describe.TableMeta.SchemaEntry[0].Name)
+	a.Equal(aliTableStore.PrimaryKeyType_STRING, *describe.TableMeta.SchemaEntry[0].Type)
+	a.Equal(aliTableStore.AUTO_INCREMENT, *describe.TableMeta.SchemaEntry[1].Option)
+	a.Equal(-1, describe.TableOption.TimeToAlive)
+
+	// 没有差异
+	response = client.AutoMigrate(&MigrateTestModel{})
+	a.Nil(response.Error)
+	a.False(response.Results[0].Created)
+	a.Len(response.Results[0].Changes, 0)
+
+	// 新增预定义列和修改TTL
+	response = client.AutoMigrate(&MigrateTestModelV2{})
+	a.Nil(response.Error)
+	a.Len(response.Results[0].Changes, 2)
+	a.True(response.Results[0].Applied)
+
+	describe, err = fake.DescribeTable(&aliTableStore.DescribeTableRequest{TableName: "migrate_test"})
+	a.Nil(err)
+	a.Len(describe.TableMeta.DefinedColumns, 2)
+	a.Equal(86400, describe.TableOption.TimeToAlive)
+
+	// 主键不同
+	plan = client.PlanMigrate(&MigrateTestModelV3{})
+	a.Nil(plan.Error)
+	a.NotEmpty(plan.Results[0].Incompatible)
+	a.NotNil(client.AutoMigrate(&MigrateTestModelV3{}).Error)
+
+	// 不支持表管理的Api
+	a.Equal(ErrTableApiNotImplemented, New("", "", "", "", WithApi(struct{ Api }{fake})).AutoMigrate(&MigrateTestModel{}).Error)
+}
+
+type IndexTestModel struct {
+	Pk   int64  `tableStore:"primaryKey;column:pk;"`
+	Name string `tableStore:"column:name;index:idx_name,1;"`
+	Age  int64  `tableStore:"column:age;"`
+}
+
+func (m *IndexTestModel) TableName() string {
+	return "index_test"
+}
+
+func (m *IndexTestModel) Indexes() []schema.IndexDefinition {
+	return []schema.IndexDefinition{{Name: "idx_age", PrimaryKey: []string{"Age"}, DefinedColumns: []string{"Name"}}}
+}
+
+func Test_Client_UseIndex(t *testing.T) {
+	a := assert.New(t)
+
+	client := New("", "", "", "", WithApi(tablestoretest.New()))
+	a.Nil(client.AutoMigrate(&IndexTestModel{}).Error)
+
+	response := client.CreateIndexes(&IndexTestModel{})
+	a.Nil(response.Error)
+	a.Equal([]string{"idx_age", "idx_name"}, response.Created)
+
+	response = client.CreateIndexes(&IndexTestModel{})
+	a.Nil(response.Error)
+	a.Len(response.Created, 0)
+	a.Equal([]string{"idx_age", "idx_name"}, response.Existing)
+
+	rows := []*IndexTestModel{{Pk: 1, Name: "b", Age: 30}, {Pk: 2, Name: "a", Age: 20}, {Pk: 3, Name: "b", Age: 10}}
+	a.Nil(client.BatchInsert(rows).Error)
+
+	// 只返回索引中的列
+	var list []*IndexTestModel
+	a.Nil(client.UseIndex("idx_name").QueryRange(&list, schema.MinPrimaryKey{"Name": "b"}, schema.MaxPrimaryKey{"Name": "b"}, 10).Error)
+	a.Len(list, 2)
+	a.Equal(int64(1), list[0].Pk)
+	a.Equal(int64(3), list[1].Pk)
+	a.Equal(int64(0), list[0].Age)
+
+	// 回表查询完整的行, 过滤条件在主表上生效
+	a.Nil(client.UseIndex("idx_name", WithBackFetch()).QueryRange(&list, schema.MinPrimaryKey{"Name": "b"}, schema.MaxPrimaryKey{"Name": "b"}, 10).Error)
+	a.Len(list, 2)
+	a.Equal(int64(30), list[0].Age)
+	a.Equal(int64(10), list[1].Age)
+
+	a.Nil(client.UseIndex("idx_name", WithBackFetch()).Where(schema.Lt("Age", 20)).QueryRange(&list, schema.MinPrimaryKey{"Name": "b"}, schema.MaxPrimaryKey{"Name": "b"}, 10).Error)
+	a.Len(list, 1)
+	a.Equal(int64(3), list[0].Pk)
+
+	// Indexer定义的索引, 倒序
+	a.Nil(client.UseIndex("idx_age").QueryRange(&list, schema.MaxPrimaryKey{}, schema.MinPrimaryKey{}, 10).Error)
+	a.Len(list, 3)
+	a.Equal(int64(30), list[0].Age)
+	a.Equal("b", list[0].Name)
+
+	a.NotNil(client.UseIndex("idx_unknown").QueryRange(&list, schema.MinPrimaryKey{}, schema.MaxPrimaryKey{}, 10).Error)
+
+	// 不回表的时候过滤条件不能在索引表上生效
+	err := client.UseIndex("idx_name").Where(schema.Lt("Age", 20)).QueryRange(&list, schema.MinPrimaryKey{"Name": "b"}, schema.MaxPrimaryKey{"Name": "b"}, 10).Error
+	a.True(errors.Is(err, ErrIndexFilterWithoutBackFetch))
+}
+
+type IndexSoftDeleteTestModel struct {
+	Pk        int64        `tableStore:"primaryKey;column:pk;"`
+	Name      string       `tableStore:"column:name;index:idx_name,1;"`
+	DeletedAt sql.NullTime `tableStore:"softDelete;column:deleted_at;"`
+}
+
+func (m *IndexSoftDeleteTestModel) TableName() string {
+	return "index_soft_delete_test"
+}
+
+func Test_Client_UseIndex_SoftDelete(t *testing.T) {
+	a := assert.New(t)
+
+	client := New("", "", "", "", WithApi(tablestoretest.New()))
+	a.Nil(client.AutoMigrate(&IndexSoftDeleteTestModel{}).Error)
+	a.Nil(client.CreateIndexes(&IndexSoftDeleteTestModel{}).Error)
+	a.Nil(client.BatchInsert([]*IndexSoftDeleteTestModel{{Pk: 1, Name: "a"}, {Pk: 2, Name: "a"}}).Error)
+	a.Nil(client.DeleteOne(&IndexSoftDeleteTestModel{Pk: 1}).Error)
+
+	// 索引表中没有软删除字段, 不回表的时候不能排除软删除的行
+	var list []*IndexSoftDeleteTestModel
+	response := client.UseIndex("idx_name").QueryRange(&list, schema.MinPrimaryKey{"Name": "a"}, schema.MaxPrimaryKey{"Name": "a"}, 10)
+	a.True(errors.Is(response.Error, ErrIndexFilterWithoutBackFetch))
+
+	a.Nil(client.UseIndex("idx_name", WithBackFetch()).QueryRange(&list, schema.MinPrimaryKey{"Name": "a"}, schema.MaxPrimaryKey{"Name": "a"}, 10).Error)
+	a.Len(list, 1)
+	a.Equal(int64(2), list[0].Pk)
+
+	list = nil
+	a.Nil(client.Unscoped().UseIndex("idx_name").QueryRange(&list, schema.MinPrimaryKey{"Name": "a"}, schema.MaxPrimaryKey{"Name": "a"}, 10).Error)
+	a.Len(list, 2)
+
+	// 回表失败的行不能跳过
+	fake := tablestoretest.New()
+	client = New("", "", "", "", WithApi(fake))
+	a.Nil(client.AutoMigrate(&IndexSoftDeleteTestModel{}).Error)
+	a.Nil(client.CreateIndexes(&IndexSoftDeleteTestModel{}).Error)
+	a.Nil(client.BatchInsert([]*IndexSoftDeleteTestModel{{Pk: 1, Name: "a"}, {Pk: 2, Name: "a"}}).Error)
+	fake.SetFault(func(operation string, tableName string, primaryKey *aliTableStore.PrimaryKey) error {
+		if "BatchGetRow" == operation && int64(2) == primaryKey.PrimaryKeys[0].Value {
+			return tablestoretest.NewError(aliTableStore.SERVER_BUSY, "busy", http.StatusServiceUnavailable)
+		}
+		return nil
+	})
+	response = client.UseIndex("idx_name", WithBackFetch()).QueryRange(&list, schema.MinPrimaryKey{"Name": "a"}, schema.MaxPrimaryKey{"Name": "a"}, 10)
+	a.Equal(aliTableStore.SERVER_BUSY, ErrorCode(response.Error))
+}
+
+type client_test_search_api struct {
+	*tablestoretest.Fake
+	request  *aliTableStore.SearchRequest
+	response *aliTableStore.SearchResponse
+}
+
+func (api *client_test_search_api) Search(request *aliTableStore.SearchRequest) (*aliTableStore.SearchResponse, error) {
+	api.request = request
+	return api.response, nil
+}
+
+func Test_Client_Search(t *testing.T) {
+	a := assert.New(t)
+
+	api := &client_test_search_api{Fake: tablestoretest.New()}
+	api.response = &aliTableStore.SearchResponse{
+		TotalCount: 3,
+		NextToken:  []byte("next"),
+		Rows: []*aliTableStore.Row{
+			{
+				PrimaryKey: &aliTableStore.PrimaryKey{PrimaryKeys: []*aliTableStore.PrimaryKeyColumn{{ColumnName: "pk", Value: int64(1)}}},
+				Columns:    []*aliTableStore.AttributeColumn{{ColumnName: "name", Value: "a"}, {ColumnName: "age", Value: int64(18)}},
+			},
+			{
+				PrimaryKey: &aliTableStore.PrimaryKey{PrimaryKeys: []*aliTableStore.PrimaryKeyColumn{{ColumnName: "pk", Value: int64(2)}}},
+				Columns:    []*aliTableStore.AttributeColumn{{ColumnName: "name", Value: "b"}},
+			},
+		},
+	}
+	client := New("", "", "", "", WithApi(api))
+
+	var list []*IndexTestModel
+	query := schema.Bool().Must(schema.Term("Name", "a"), schema.Range("Age").Gte(18).Lt(30)).MustNot(schema.Prefix("name", "x"))
+	response := client.Search(&list, "index_test_search", query, WithSearchSort(schema.Desc("Age")), WithSearchLimit(2), WithSearchTotalCount(), WithSearchColumns("Name", "Age"))
+	a.Nil(response.Error)
+	a.Equal(int64(3), response.TotalCount)
+	a.True(response.HasNext)
+	a.Equal(2, response.RowCount)
+	a.Equal(int64(1), list[0].Pk)
+	a.Equal("a", list[0].Name)
+	a.Equal(int64(18), list[0].Age)
+	a.Equal("b", list[1].Name)
+
+	a.Equal("index_test", api.request.TableName)
+	a.Equal("index_test_search", api.request.IndexName)
+	a.Equal([]string{"name", "age"}, api.request.ColumnsToGet.Columns)
+	_, err := api.request.ProtoBuffer()
+	a.Nil(err)
+
+	// 字段不存在
+	a.NotNil(client.Search(&list, "index_test_search", schema.Term("Unknown", 1)).Error)
+	a.NotNil(client.Search(&list, "index_test_search", nil, WithSearchSort(schema.Asc("Unknown"))).Error)
+
+	// 软删除的模型默认排除已经删除的行, 声明没有索引软删除字段的索引不排除
+	modelSchema, err := client.ParseSchema(&Model{})
+	a.Nil(err)
+	a.NotNil(client.buildSearchSoftDeleteQuery(modelSchema, "model_search"))
+	a.Nil(client.Unscoped().buildSearchSoftDeleteQuery(modelSchema, "model_search"))
+	withoutSoftDelete := New("", "", "", "", WithApi(api), WithSearchIndexWithoutSoftDelete("model_search"))
+	a.Nil(withoutSoftDelete.buildSearchSoftDeleteQuery(modelSchema, "model_search"))
+	a.NotNil(withoutSoftDelete.buildSearchSoftDeleteQuery(modelSchema, "model_search_all"))
+
+	// 不支持多元索引的Api
+	a.Equal(ErrSearchApiNotImplemented, New("", "", "", "", WithApi(tablestoretest.New())).Search(&list, "index_test_search", nil).Error)
+}
+
+type AggregateTestBucket struct {
+	Key      int64
+	RowCount int64
+	MaxAge   *float64 `aggregation:"max_age"`
+	Ranges   []struct {
+		From     float64
+		RowCount int
+	} `aggregation:"by_age"`
+}
+
+func Test_Client_Aggregate(t *testing.T) {
+	a := assert.New(t)
+
+	subAggregations := search.AggregationResults{}
+	subAggregations.Put("max_age", &search.MaxAggregationResult{Name: "max_age", Value: 30})
+	subGroupBys := search.GroupByResults{}
+	subGroupBys.Put("by_age", &search.GroupByRangeResult{Name: "by_age", Items: []search.GroupByRangeResultItem{{From: 0, To: 18, RowCount: 1}, {From: 18, To: 60, RowCount: 2}}})
+
+	api := &client_test_search_api{Fake: tablestoretest.New()}
+	api.response = &aliTableStore.SearchResponse{TotalCount: 5}
+	api.response.AggregationResults.Put("count", &search.CountAggregationResult{Name: "count", Value: 5})
+	api.response.AggregationResults.Put("avg_age", &search.AvgAggregationResult{Name: "avg_age", Value: 20.5})
+	api.response.AggregationResults.Put("min_age", &search.MinAggregationResult{Name: "min_age", Value: math.Inf(1)})
+	api.response.GroupByResults.Put("by_name", &search.GroupByFieldResult{Name: "by_name", Items: []search.GroupByFieldResultItem{
+		{Key: "3", RowCount: 3, SubAggregations: subAggregations, SubGroupBys: subGroupBys},
+		{Key: "4", RowCount: 2},
+	}})
+	client := New("", "", "", "", WithApi(api))
+
+	response := client.Aggregate(&IndexTestModel{}, "index_test_search", schema.Term("Name", "a"),
+		schema.Count("count", "Pk"),
+		schema.Avg("avg_age", "Age"),
+		schema.Min("min_age", "Age"),
+		schema.GroupByField("by_name", "Name").SubAggregations(schema.Max("max_age", "Age"), schema.GroupByRange("by_age", "Age").Range(0, 18).Range(18, 60)),
+	)
+	a.Nil(response.Error)
+	a.Equal(int64(5), response.TotalCount)
+
+	count, err := response.Count("count")
+	a.Nil(err)
+	a.Equal(int64(5), count)
+
+	avg, ok, err := response.Avg("avg_age")
+	a.Nil(err)
+	a.True(ok)
+	a.Equal(20.5, avg)
+
+	_, ok, err = response.Min("min_age")
+	a.Nil(err)
+	a.False(ok)
+
+	// 类型不匹配或者不存在
+	_, err = response.Sum("count")
+	a.NotNil(err)
+	_, err = response.GroupBy("unknown")
+	a.NotNil(err)
+
+	buckets, err := response.GroupBy("by_name")
+	a.Nil(err)
+	a.Len(buckets, 2)
+	a.Equal("3", buckets[0].Key)
+	max, ok, err := buckets[0].Max("max_age")
+	a.Nil(err)
+	a.True(ok)
+	a.Equal(float64(30), max)
+
+	var list []*AggregateTestBucket
+	a.Nil(response.DecodeGroupBy("by_name", &list))
+	a.Len(list, 2)
+	a.Equal(int64(3), list[0].Key)
+	a.Equal(int64(3), list[0].RowCount)
+	a.Equal(float64(30), *list[0].MaxAge)
+	a.Len(list[0].Ranges, 2)
+	a.Equal(float64(18), list[0].Ranges[1].From)
+	a.Equal(2, list[0].Ranges[1].RowCount)
+	a.Nil(list[1].MaxAge)
+	a.Len(list[1].Ranges, 0)
+
+	var single AggregateTestBucket
+	a.Nil(buckets[1].Decode(&single))
+	a.Equal(int64(4), single.Key)
+	a.NotNil(buckets[1].Decode(single))
+
+	a.Equal("index_test", api.request.TableName)
+	_, err = api.request.ProtoBuffer()
+	a.Nil(err)
+	searchQuery := reflect.ValueOf(api.request.SearchQuery).Elem()
+	a.Equal(int64(0), searchQuery.FieldByName("Limit").Int())
+	a.Equal(3, searchQuery.FieldByName("Aggregations").Len())
+	a.Equal(1, searchQuery.FieldByName("GroupBys").Len())
+
+	// 字段不存在
+	a.NotNil(client.Aggregate(&IndexTestModel{}, "index_test_search", nil, schema.Sum("sum", "Unknown")).Error)
+	a.NotNil(client.Aggregate(&IndexTestModel{}, "index_test_search", nil).Error)
+}
+
+type TransactionTestModel struct {
+	UserId  int64 `tableStore:"primaryKey;column:user_id;sort:1;"`
+	OrderId int64 `tableStore:"primaryKey;column:order_id;sort:2;"`
+	Status  int64 `tableStore:"column:status;"`
+}
+
+func (m *TransactionTestModel) TableName() string {
+	return "transaction_test"
+}
+
+func Test_Client_Transaction(t *testing.T) {
+	a := assert.New(t)
+
+	fake := tablestoretest.New()
+	client := New("", "", "", "", WithApi(fake))
+	ctx := context.Background()
+
+	err := client.Transaction(ctx, &TransactionTestModel{UserId: 1}, func(tx *Tx) error {
+		a.NotEmpty(tx.ID())
+		a.Nil(tx.Insert(&TransactionTestModel{UserId: 1, OrderId: 1, Status: 1}).Error)
+		a.Nil(tx.Insert(&TransactionTestModel{UserId: 1, OrderId: 2, Status: 1}).Error)
+		a.Nil(tx.UpdateOne(&TransactionTestModel{UserId: 1, OrderId: 1}, map[string]interface{}{"Status": 2}).Error)
+
+		// 事务中可以读到未提交的修改, 事务外读不到, 也不能修改
+		row := &TransactionTestModel{UserId: 1, OrderId: 1}
+		a.True(tx.QueryOne(row).Exists)
+		a.Equal(int64(2), row.Status)
+		a.False(client.QueryOne(&TransactionTestModel{UserId: 1, OrderId: 1}).Exists)
+		a.NotNil(client.Insert(&TransactionTestModel{UserId: 1, OrderId: 3}).Error)
+
+		// 其他分区的行
+		a.NotNil(tx.Insert(&TransactionTestModel{UserId: 2, OrderId: 1}).Error)
+		return nil
+	})
+	a.Nil(err)
+	a.Equal(2, fake.RowCount("transaction_test"))
+	row := &TransactionTestModel{UserId: 1, OrderId: 1}
+	a.True(client.QueryOne(row).Exists)
+	a.Equal(int64(2), row.Status)
+
+	// 返回错误的时候回滚
+	rollback := errors.New("rollback")
+	err = client.Transaction(ctx, &TransactionTestModel{UserId: 1}, func(tx *Tx) error {
+		a.Nil(tx.DeleteOne(&TransactionTestModel{UserId: 1, OrderId: 1}).Error)
+		a.False(tx.QueryOne(&TransactionTestModel{UserId: 1, OrderId: 1}).Exists)
+		return rollback
+	})
+	a.Equal(rollback, err)
+	a.Equal(2, fake.RowCount("transaction_test"))
+	a.Equal(1, fake.Calls("AbortTransaction"))
+
+	// panic的时候回滚
+	a.Panics(func() {
+		_ = client.Transaction(ctx, &TransactionTestModel{UserId: 1}, func(tx *Tx) error {
+			a.Nil(tx.Insert(&TransactionTestModel{UserId: 1, OrderId: 3}).Error)
+			panic("panic")
+		})
+	})
+	a.Equal(2, fake.RowCount("transaction_test"))
+	a.Equal(2, fake.Calls("AbortTransaction"))
+	a.Nil(client.Insert(&TransactionTestModel{UserId: 1, OrderId: 3}).Error)
+
+	// 提交失败
+	fake.SetFault(func(operation string, tableName string, primaryKey *aliTableStore.PrimaryKey) error {
+		if "CommitTransaction" == operation {
+			return tablestoretest.NewError("OTSInternalServerError", "internal error", 500)
+		}
+		return nil
+	})
+	err = client.Transaction(ctx, &TransactionTestModel{UserId: 2}, func(tx *Tx) error {
+		return tx.Insert(&TransactionTestModel{UserId: 2, OrderId: 1}).Error
+	})
+	a.NotNil(err)
+	fake.SetFault(nil)
+	a.Equal(3, fake.Calls("AbortTransaction"))
+	a.Equal(3, fake.RowCount("transaction_test"))
+	a.Nil(client.Insert(&TransactionTestModel{UserId: 2, OrderId: 2}).Error)
+
+	// fn返回之前ctx被取消, 仍然提交, 分区不会被锁定
+	cancelCtx, cancel := context.WithCancel(ctx)
+	err = client.Transaction(cancelCtx, &TransactionTestModel{UserId: 3}, func(tx *Tx) error {
+		defer cancel()
+		return tx.Insert(&TransactionTestModel{UserId: 3, OrderId: 1}).Error
+	})
+	a.Nil(err)
+	a.Equal(5, fake.RowCount("transaction_test"))
+	a.Equal(3, fake.Calls("AbortTransaction"))
+	a.Nil(client.Insert(&TransactionTestModel{UserId: 3, OrderId: 2}).Error)
+
+	// 不支持事务的Api
+	a.Equal(ErrTransactionApiNotImplemented, New("", "", "", "", WithApi(struct{ Api }{fake})).Transaction(ctx, &TransactionTestModel{UserId: 1}, func(tx *Tx) error { return nil }))
+}
+
+type SaveTestModel struct {
+	Pk        int64  `tableStore:"primaryKey;column:pk;"`
+	Name      string `tableStore:"column:name;"`
+	Age       int64  `tableStore:"column:age;"`
+	Version   int64  `tableStore:"version;column:version;"`
+	CreatedAt int64  `tableStore:"column:created_at;autoCreateTime;"`
+	UpdatedAt int64  `tableStore:"column:updated_at;autoUpdateTime;"`
+}
+
+func (m *SaveTestModel) TableName() string {
+	return "save_test"
+}
+
+func Test_Client_Save(t *testing.T) {
+	a := assert.New(t)
+
+	now := time.Unix(1600000000, 0)
+	client := New("", "", "", "", WithApi(tablestoretest.New()), WithClock(func() time.Time { return now }))
+
+	// 行不存在的时候写入新行, 版本加1
+	row := &SaveTestModel{Pk: 1, Name: "a", Age: 18}
+	response := client.Save(row)
+	a.Nil(response.Error)
+	a.Equal(1, response.RowsAffected)
+	a.Equal(int64(1), row.Version)
+	a.Equal(now.Unix(), row.CreatedAt)
+
+	// 覆盖已经存在的行, 创建时间不变
+	now = now.Add(time.Hour)
+	row.Name = "b"
+	a.Nil(client.Save(row).Error)
+	a.Equal(int64(2), row.Version)
+	a.Equal(now.Add(-time.Hour).Unix(), row.CreatedAt)
+	a.Equal(now.Unix(), row.UpdatedAt)
+
+	// 版本已经被修改
+	stale := &SaveTestModel{Pk: 1, Name: "c", Version: 1}
+	a.Equal(ErrStaleObject, client.Save(stale).Error)
+	a.Equal(int64(1), stale.Version)
+	a.Equal(ErrStaleObject, client.Save(&SaveTestModel{Pk: 1, Name: "c"}).Error)
+
+	// 同时有其他写入条件的时候返回原来的错误
+	saveResponse := client.Save(&SaveTestModel{Pk: 1, Name: "c", Version: 2}, WithSaveCondition(schema.Eq("Name", "x")))
+	a.True(IsConditionFailed(saveResponse.Error))
+	a.NotEqual(ErrStaleObject, saveResponse.Error)
+
+	// 附加的写入条件
+	a.NotNil(client.Save(row, WithSaveCondition(schema.Eq("Name", "x"))).Error)
+	a.NotNil(client.Save(&SaveTestModel{Pk: 2}, WithRowExistence(aliTableStore.RowExistenceExpectation_EXPECT_EXIST)).Error)
+
+	// 行已经存在的时候忽略
+	response = client.InsertOrIgnore(&SaveTestModel{Pk: 1, Name: "d"})
+	a.Nil(response.Error)
+	a.Equal(0, response.RowsAffected)
+	response = client.InsertOrIgnore(&SaveTestModel{Pk: 2, Name: "d"})
+	a.Nil(response.Error)
+	a.Equal(1, response.RowsAffected)
+
+	// 有其他写入条件的时候不能确定是行已经存在, 返回原来的错误
+	response = client.InsertOrIgnore(&SaveTestModel{Pk: 1, Name: "d"}, WithSaveCondition(schema.Eq("Name", "x")))
+	a.True(IsConditionFailed(response.Error))
+	response = client.Where(schema.Eq("Name", "x")).InsertOrIgnore(&SaveTestModel{Pk: 3, Name: "d"})
+	a.True(IsConditionFailed(response.Error))
+
+	result := &SaveTestModel{Pk: 1}
+	a.True(client.QueryOne(result).Exists)
+	a.Equal("b", result.Name)
+	a.Equal(int64(18), result.Age)
+
+	// 自增主键总是写入新行
+	autoIncrement := &MigrateTestModel{Pk: "a", Name: "a"}
+	response = client.Save(autoIncrement)
+	a.Nil(response.Error)
+	a.Equal(int64(1), response.LastId)
+}
+
+func Test_Client_Upsert(t *testing.T) {
+	a := assert.New(t)
+
+	now := time.Unix(1600000000, 0)
+	client := New("", "", "", "", WithApi(tablestoretest.New()), WithClock(func() time.Time { return now }))
+
+	// 行不存在的时候新建
+	row := &SaveTestModel{Pk: 1, Name: "a", Age: 18, CreatedAt: now.Unix()}
+	response := client.Upsert(row)
+	a.Nil(response.Error)
+	a.Equal(1, response.RowsAffected)
+	a.Equal(int64(1), row.Version)
+
+	// 只写入指定的列和更新时间, 其他列保持不变
+	now = now.Add(time.Hour)
+	a.Nil(client.Upsert(&SaveTestModel{Pk: 1, Name: "b", Version: 1}, "Name").Error)
+
+	result := &SaveTestModel{Pk: 1}
+	a.True(client.QueryOne(result).Exists)
+	a.Equal("b", result.Name)
+	a.Equal(int64(18), result.Age)
+	a.Equal(int64(2), result.Version)
+	a.Equal(now.Add(-time.Hour).Unix(), result.CreatedAt)
+	a.Equal(now.Unix(), result.UpdatedAt)
+
+	// 版本已经被修改
+	a.Equal(ErrStaleObject, client.Upsert(&SaveTestModel{Pk: 1, Name: "c", Version: 1}).Error)
+	a.Equal(ErrStaleObject, client.Upsert(&SaveTestModel{Pk: 1, Name: "c"}).Error)
+
+	// 覆盖所有的列, 零值的创建时间不写入
+	a.Nil(client.Upsert(&SaveTestModel{Pk: 1, Name: "d", Version: 2}).Error)
+	a.True(client.QueryOne(result).Exists)
+	a.Equal(int64(0), result.Age)
+	a.Equal(now.Add(-time.Hour).Unix(), result.CreatedAt)
+
+	// 新建的行没有设置创建时间的时候不写入
+	a.Nil(client.Upsert(&SaveTestModel{Pk: 3, Name: "e"}).Error)
+	created := &SaveTestModel{Pk: 3}
+	a.True(client.QueryOne(created).Exists)
+	a.Equal(int64(0), created.CreatedAt)
+	a.Equal(now.Unix(), created.UpdatedAt)
+
+	a.NotNil(client.Upsert(&SaveTestModel{Pk: 1}, "Unknown").Error)
+	a.Equal(ErrUpsertAutoIncrement, client.Upsert(&MigrateTestModel{Pk: "a"}).Error)
+}
+
+type UpdateFieldsTestModel struct {
+	Pk       int64   `tableStore:"primaryKey;column:pk;"`
+	Name     string  `tableStore:"column:name;"`
+	Nickname *string `tableStore:"column:nickname;"`
+	Age      int64   `tableStore:"column:age;"`
+	Version  int64   `tableStore:"version;column:version;"`
+}
+
+func (m *UpdateFieldsTestModel) TableName() string {
+	return "update_fields_test"
+}
+
+func Test_Client_UpdateFields(t *testing.T) {
+	a := assert.New(t)
+
+	fake := tablestoretest.New()
+	client := New("", "", "", "", WithApi(fake))
+
+	nickname := "nick"
+	a.Nil(client.Insert(&UpdateFieldsTestModel{Pk: 1, Name: "a", Nickname: &nickname, Age: 18}).Error)
+
+	// 只更新指定的字段
+	row := &UpdateFieldsTestModel{Pk: 1, Name: "b", Age: 20}
+	a.Nil(client.UpdateFields(row, "Name").Error)
+	a.Equal(int64(1), row.Version)
+
+	result := &UpdateFieldsTestModel{Pk: 1}
+	a.True(client.QueryOne(result).Exists)
+	a.Equal("b", result.Name)
+	a.Equal(int64(18), result.Age)
+	a.Equal("nick", *result.Nickname)
+
+	// 更新所有的属性列, 空指针删除对应的列
+	row = &UpdateFieldsTestModel{Pk: 1, Name: "c", Age: 21, Version: 1}
+	a.Nil(client.UpdateFields(row).Error)
+	a.Equal(int64(2), row.Version)
+
+	result = &UpdateFieldsTestModel{Pk: 1}
+	a.True(client.QueryOne(result).Exists)
+	a.Equal(int64(21), result.Age)
+	a.Nil(result.Nickname)
+
+	a.NotNil(client.UpdateFields(row, "Unknown").Error)
+	a.NotNil(client.UpdateFields(row, "Pk").Error)
+
+	// 只更新修改过的字段
+	original := &UpdateFieldsTestModel{Pk: 1}
+	a.True(client.QueryOne(original).Exists)
+	modified := *original
+	modified.Age = 30
+	modified.Nickname = &nickname
+
+	calls := fake.Calls("UpdateRow")
+	a.Nil(client.UpdateChanged(original, &modified).Error)
+	a.Equal(calls+1, fake.Calls("UpdateRow"))
+	a.Equal(int64(3), modified.Version)
+
+	result = &UpdateFieldsTestModel{Pk: 1}
+	a.True(client.QueryOne(result).Exists)
+	a.Equal("c", result.Name)
+	a.Equal(int64(30), result.Age)
+	a.Equal("nick", *result.Nickname)
+
+	// 没有修改的时候不发起请求
+	unchanged := modified
+	a.Nil(client.UpdateChanged(&modified, &unchanged).Error)
+	a.Equal(calls+1, fake.Calls("UpdateRow"))
+
+	// 主键不能修改
+	changedKey := modified
+	changedKey.Pk = 2
+	a.NotNil(client.UpdateChanged(&modified, &changedKey).Error)
+}
+
+type NullableTestModel struct {
+	Pk       int64        `tableStore:"primaryKey;column:pk;"`
+	Name     string       `tableStore:"column:name;"`
+	Nickname *string      `tableStore:"column:nickname;nullable;"`
+	LoginAt  sql.NullTime `tableStore:"column:login_at;omitempty;"`
+}
+
+func (m *NullableTestModel) TableName() string {
+	return "nullable_test"
+}
+
+func Test_Client_Nullable(t *testing.T) {
+	a := assert.New(t)
+
+	client := New("", "", "", "", WithApi(tablestoretest.New()))
+
+	// null值不写入
+	var request *aliTableStore.PutRowRequest
+	a.Nil(client.Insert(&NullableTestModel{Pk: 1, Name: "a"}, func(r *aliTableStore.PutRowRequest) { request = r }).Error)
+	a.Len(request.PutRowChange.Columns, 1)
+	a.Equal("name", request.PutRowChange.Columns[0].ColumnName)
+
+	nickname := "nick"
+	a.Nil(client.Insert(&NullableTestModel{Pk: 2, Name: "b", Nickname: &nickname, LoginAt: sql.NullTime{Time: time.Now(), Valid: true}}).Error)
+
+	row := &NullableTestModel{Pk: 2}
+	a.True(client.QueryOne(row).Exists)
+	a.Equal("nick", *row.Nickname)
+	a.True(row.LoginAt.Valid)
+
+	// null值删除对应的列
+	a.Nil(client.UpdateOne(&NullableTestModel{Pk: 2}, map[string]interface{}{"Nickname": (*string)(nil), "LoginAt": sql.NullTime{}}).Error)
+	row = &NullableTestModel{Pk: 2}
+	a.True(client.QueryOne(row).Exists)
+	a.Nil(row.Nickname)
+	a.False(row.LoginAt.Valid)
+
+	// 使用DeleteColumn删除
+	a.Nil(client.UpdateOne(&NullableTestModel{Pk: 2}, map[string]interface{}{"Nickname": "nick", "Name": schema.DeleteColumn}).Error)
+	row = &NullableTestModel{Pk: 2}
+	a.True(client.QueryOne(row).Exists)
+	a.Equal("", row.Name)
+	a.Equal("nick", *row.Nickname)
+
+	var update *aliTableStore.UpdateRowRequest
+	a.Nil(client.UpdateOne(&NullableTestModel{Pk: 2}, map[string]interface{}{"Nickname": schema.DeleteColumnVersion(1000)}, func(r *aliTableStore.UpdateRowRequest) { update = r }).Error)
+	a.Equal(byte(aliTableStore.DELETE_ONE_VERSION), update.UpdateRowChange.Columns[0].Type)
+	a.Equal(int64(1000), update.UpdateRowChange.Columns[0].Timestamp)
+
+	// nil会被忽略
+	a.Nil(client.UpdateOne(&NullableTestModel{Pk: 2}, map[string]interface{}{"Name": "c", "Nickname": nil}).Error)
+
+	// 从结构体更新
+	a.Nil(client.UpdateOne(&NullableTestModel{Pk: 2}, map[string]interface{}{"LoginAt": sql.NullTime{Time: time.Now(), Valid: true}}).Error)
+	a.Nil(client.UpdateFields(&NullableTestModel{Pk: 2, Name: "d"}, "LoginAt").Error)
+	row = &NullableTestModel{Pk: 2}
+	a.True(client.QueryOne(row).Exists)
+	a.False(row.LoginAt.Valid)
+	a.Equal("c", row.Name)
+
+	// Upsert删除null值的列
+	a.Nil(client.UpdateOne(&NullableTestModel{Pk: 2}, map[string]interface{}{"Nickname": "nick"}).Error)
+	a.Nil(client.Upsert(&NullableTestModel{Pk: 2, Name: "e"}).Error)
+	row = &NullableTestModel{Pk: 2}
+	a.True(client.QueryOne(row).Exists)
+	a.Nil(row.Nickname)
+	a.Equal("e", row.Name)
+}
+
+type CodecTestProfile struct {
+	Age  int    `json:"age"`
+	City string `json:"city"`
+}
+
+type CodecTestStatus struct {
+	Code int64
+}
+
+type codecTestStatusCodec struct{}
+
+func (codecTestStatusCodec) Encode(value interface{}) (interface{}, error) {
+	return []string{"draft", "published"}[value.(CodecTestStatus).Code], nil
+}
+
+func (codecTestStatusCodec) Decode(value interface{}, dest interface{}) error {
+	for code, name := range []string{"draft", "published"} {
+		if name == value {
+			dest.(*CodecTestStatus).Code = int64(code)
+			return nil
+		}
+	}
+	return errors.New("unknown status")
+}
+
+type CodecTestModel struct {
+	Pk      int64             `tableStore:"primaryKey;column:pk;"`
+	Profile *CodecTestProfile `tableStore:"column:profile;codec:json;"`
+	Status  CodecTestStatus   `tableStore:"column:status;"`
+	Score   float64           `tableStore:"column:score;codec:percent;"`
+}
+
+func (m *CodecTestModel) TableName() string {
+	return "codec_test"
+}
+
+type codecTestPercentCodec struct{}
+
+func (codecTestPercentCodec) Encode(value interface{}) (interface{}, error) {
+	return int64(math.Round(value.(float64) * 100)), nil
+}
+
+func (codecTestPercentCodec) Decode(value interface{}, dest interface{}) error {
+	*dest.(*float64) = float64(value.(int64)) / 100
+	return nil
+}
+
+func Test_Client_Codec(t *testing.T) {
+	a := assert.New(t)
+
+	fake := tablestoretest.New()
+	client := New("", "", "", "", WithApi(fake),
+		WithCodec(CodecTestStatus{}, codecTestStatusCodec{}),
+		WithNamedCodec("percent", codecTestPercentCodec{}),
+	)
+
+	a.Nil(client.Insert(&CodecTestModel{Pk: 1, Profile: &CodecTestProfile{Age: 18, City: "hz"}, Status: CodecTestStatus{Code: 1}, Score: 0.5}).Error)
+
+	row := &CodecTestModel{Pk: 1}
+	a.True(client.QueryOne(row).Exists)
+	a.Equal(&CodecTestProfile{Age: 18, City: "hz"}, row.Profile)
+	a.Equal(int64(1), row.Status.Code)
+	a.Equal(0.5, row.Score)
+
+	// 条件和更新中的值使用Codec编码
+	a.Nil(client.Where(schema.Eq("Status", CodecTestStatus{Code: 1})).UpdateOne(&CodecTestModel{Pk: 1}, map[string]interface{}{"Status": CodecTestStatus{Code: 0}}).Error)
+	row = &CodecTestModel{Pk: 1}
+	a.True(client.QueryOne(row).Exists)
+	a.Equal(int64(0), row.Status.Code)
+
+	// 没有注册Codec的客户端不能解析
+	a.NotNil(New("", "", "", "", WithApi(fake)).QueryOne(&CodecTestModel{Pk: 1}).Error)
+}
+
+type ErrorTestModel struct {
+	Pk   int64  `tableStore:"primaryKey;column:pk;"`
+	Name string `tableStore:"column:name;"`
+}
+
+func (m *ErrorTestModel) TableName() string {
+	return "error_test"
+}
+
+func Test_Client_Error(t *testing.T) {
+	a := assert.New(t)
+
+	fake := tablestoretest.New()
+	client := New("", "", "", "", WithApi(fake))
+
+	a.Nil(client.Insert(&ErrorTestModel{Pk: 1, Name: "a"}).Error)
+
+	// 行已经存在
+	err := client.Insert(&ErrorTestModel{Pk: 1, Name: "a"}).Error
+	a.True(IsConditionFailed(err))
+	a.True(errors.Is(err, ErrConditionFailed))
+	a.False(IsNotFound(err))
+	a.False(IsRetryable(err))
+	a.Equal(tablestoretest.ConditionCheckFail, ErrorCode(err))
+
+	var tableStoreError *Error
+	a.True(errors.As(err, &tableStoreError))
+	a.Equal(tablestoretest.ConditionCheckFail, tableStoreError.Code)
+	a.NotEmpty(tableStoreError.RequestId)
+	a.NotZero(tableStoreError.HttpStatus)
+
+	// 原始的sdk错误
+	var otsError *aliTableStore.OtsError
+	a.True(errors.As(err, &otsError))
+
+	// 限流
+	fake.SetFault(func(operation string, tableName string, primaryKey *aliTableStore.PrimaryKey) error {
+		if 2 == primaryKey.PrimaryKeys[0].Value.(int64) {
+			return tablestoretest.NewError(aliTableStore.NOT_ENOUGH_CAPACITY_UNIT, "throttled", 403)
+		}
+		return nil
+	})
+	err = client.QueryOne(&ErrorTestModel{Pk: 2}).Error
+	a.True(IsThrottled(err))
+	a.True(IsRetryable(err))
+	a.True(errors.Is(err, ErrThrottled))
+	a.False(IsConditionFailed(err))
+
+	// 批量操作中失败的行
+	config := DefaultBatchConfig()
+	config.MaxAttempts = 1
+	response := New("", "", "", "", WithApi(fake), WithBatchConfig(config)).BatchInsert([]*ErrorTestModel{{Pk: 2}, {Pk: 3}})
+	a.Nil(response.Error)
+	a.True(IsThrottled(response.Results[0].Err()))
+	a.NotEmpty(response.Results[0].RequestId)
+	a.Nil(response.Results[1].Err())
+
+	// 表不存在
+	fake.SetFault(func(operation string, tableName string, primaryKey *aliTableStore.PrimaryKey) error {
+		return tablestoretest.NewError(tablestoretest.ObjectNotExist, "table not exist", 404)
+	})
+	a.True(IsNotFound(client.QueryOne(&ErrorTestModel{Pk: 1}).Error))
+	fake.SetFault(nil)
+
+	// 其他错误不会被转换
+	a.Equal("", ErrorCode(errors.New("error")))
+	a.False(IsRetryable(context.Canceled))
+}
+
+type RetryTestModel struct {
+	Pk   int64  `tableStore:"primaryKey;column:pk;"`
+	Name string `tableStore:"column:name;"`
+}
+
+func (m *RetryTestModel) TableName() string {
+	return "retry_test"
+}
+
+func Test_Client_Retry(t *testing.T) {
+	a := assert.New(t)
+
+	fake := tablestoretest.New()
+	policy := DefaultRetryPolicy()
+	policy.Backoff = time.Millisecond
+	client := New("", "", "", "", WithApi(fake), WithRetryPolicy(policy))
+
+	// 前failures次请求返回code
+	fail := func(code string, failures int) {
+		count := 0
+		fake.SetFault(func(operation string, tableName string, primaryKey *aliTableStore.PrimaryKey) error {
+			if count++; count <= failures {
+				return tablestoretest.NewError(code, code, 503)
+			}
+			return nil
+		})
+	}
+
+	a.Nil(client.Insert(&RetryTestModel{Pk: 1, Name: "a"}).Error)
+
+	// 幂等的请求按照错误码重试
+	fail(aliTableStore.STORAGE_TIMEOUT, 2)
+	response := client.QueryOne(&RetryTestModel{Pk: 1})
+	a.Nil(response.Error)
+	a.True(response.Exists)
+	a.Equal(3, response.Attempts)
+
+	fail(aliTableStore.STORAGE_TIMEOUT, 3)
+	response = client.QueryOne(&RetryTestModel{Pk: 1})
+	a.True(IsRetryable(response.Error))
+	a.Equal(3, response.Attempts)
+
+	// 不可重试的错误码
+	fail(tablestoretest.ParameterInvalid, 1)
+	response = client.QueryOne(&RetryTestModel{Pk: 1})
+	a.NotNil(response.Error)
+	a.Equal(1, response.Attempts)
+
+	// 非幂等的请求只在服务端确定没有执行的时候重试
+	fail(aliTableStore.STORAGE_TIMEOUT, 1)
+	insertResponse := client.Insert(&RetryTestModel{Pk: 2, Name: "b"})
+	a.NotNil(insertResponse.Error)
+	a.Equal(1, insertResponse.Attempts)
+
+	fail(aliTableStore.SERVER_BUSY, 1)
+	insertResponse = client.Insert(&RetryTestModel{Pk: 2, Name: "b"})
+	a.Nil(insertResponse.Error)
+	a.Equal(2, insertResponse.Attempts)
+
+	fail(aliTableStore.STORAGE_TIMEOUT, 1)
+	updateResponse := client.UpdateOne(&RetryTestModel{Pk: 2}, map[string]interface{}{"Name": "c"})
+	a.Nil(updateResponse.Error)
+	a.Equal(2, updateResponse.Attempts)
+
+	// 覆盖写入是幂等的
+	fail(aliTableStore.STORAGE_TIMEOUT, 1)
+	saveResponse := client.Save(&RetryTestModel{Pk: 2, Name: "d"})
+	a.Nil(saveResponse.Error)
+	a.Equal(2, saveResponse.Attempts)
+
+	// 按照错误码指定
+	policy.Codes = map[string]bool{aliTableStore.STORAGE_TIMEOUT: false}
+	fail(aliTableStore.STORAGE_TIMEOUT, 1)
+	response = New("", "", "", "", WithApi(fake), WithRetryPolicy(policy)).QueryOne(&RetryTestModel{Pk: 1})
+	a.NotNil(response.Error)
+	a.Equal(1, response.Attempts)
+
+	// 超过MaxElapsedTime不再重试
+	policy.Codes = nil
+	policy.MaxAttempts = 100
+	policy.Backoff = 20 * time.Millisecond
+	policy.MaxElapsedTime = 50 * time.Millisecond
+	fail(aliTableStore.SERVER_BUSY, 100)
+	response = New("", "", "", "", WithApi(fake), WithRetryPolicy(policy)).QueryOne(&RetryTestModel{Pk: 1})
+	a.NotNil(response.Error)
+	a.Less(response.Attempts, 5)
+
+	// 默认不重试
+	fail(aliTableStore.SERVER_BUSY, 1)
+	response = New("", "", "", "", WithApi(fake)).QueryOne(&RetryTestModel{Pk: 1})
+	a.NotNil(response.Error)
+	a.Equal(1, response.Attempts)
+
+	// Config中设置
+	fail(aliTableStore.SERVER_BUSY, 1)
+	policy = DefaultRetryPolicy()
+	policy.Backoff = time.Millisecond
+	response = NewWithConfig(Config{RetryPolicy: &policy, Options: []ClientOption{WithApi(fake)}}).QueryOne(&RetryTestModel{Pk: 1})
+	a.Nil(response.Error)
+	a.Equal(2, response.Attempts)
+	fake.SetFault(nil)
+}
+
+func Test_Client_Interceptor(t *testing.T) {
+	a := assert.New(t)
+
+	var calls []string
+	recorder := func(ctx context.Context, call *Call, next Handler) (interface{}, error) {
+		calls = append(calls, call.Operation+":"+call.TableName())
+		return next(ctx, call)
+	}
+
+	// 租户检查, 不调用next直接返回错误
+	errForbidden := errors.New("forbidden")
+	tenant := func(ctx context.Context, call *Call, next Handler) (interface{}, error) {
+		calls = append(calls, "tenant")
+		for _, tableName := range call.TableNames {
+			if "retry_test" != tableName {
+				return nil, errForbidden
+			}
+		}
+		return next(ctx, call)
+	}
+
+	fake := tablestoretest.New()
+	policy := DefaultRetryPolicy()
+	policy.Backoff = time.Millisecond
+	client := New("", "", "", "", WithApi(fake), WithRetryPolicy(policy), WithInterceptor(recorder), WithInterceptor(tenant))
+
+	a.Nil(client.Insert(&RetryTestModel{Pk: 1, Name: "a"}).Error)
+	a.Equal([]string{"PutRow:retry_test", "tenant"}, calls)
+
+	// 重试的时候拦截器只执行一次
+	calls = nil
+	count := 0
+	fake.SetFault(func(operation string, tableName string, primaryKey *aliTableStore.PrimaryKey) error {
+		if count++; count <= 1 {
+			return tablestoretest.NewError(aliTableStore.SERVER_BUSY, "busy", 503)
+		}
+		return nil
+	})
+	response := client.QueryOne(&RetryTestModel{Pk: 1})
+	a.Nil(response.Error)
+	a.Equal(2, response.Attempts)
+	a.Equal([]string{"GetRow:retry_test", "tenant"}, calls)
+	fake.SetFault(nil)
+
+	calls = nil
+	a.Nil(client.BatchInsert([]*RetryTestModel{{Pk: 2, Name: "b"}, {Pk: 3, Name: "c"}}).Error)
+	rows := []*RetryTestModel{{Pk: 2}, {Pk: 3}}
+	a.Nil(client.QueryAll(&rows).Error)
+	a.Nil(client.QueryRange(&[]*RetryTestModel{}, schema.MinPrimaryKey{}, schema.MaxPrimaryKey{}, 10).Error)
+	a.Equal([]string{"BatchWriteRow:retry_test", "tenant", "BatchGetRow:retry_test", "tenant", "GetRange:retry_test", "tenant"}, calls)
+
+	insertResponse := client.Insert(&TestModel{Pk: 1, ID: 1})
+	a.True(errors.Is(insertResponse.Error, errForbidden))
+
+	// 修改请求
+	a.Nil(New("", "", "", "", WithApi(fake), WithInterceptor(func(ctx context.Context, call *Call, next Handler) (interface{}, error) {
+		if request, ok := call.Request.(*aliTableStore.PutRowRequest); ok {
+			request.PutRowChange.Columns[0].Value = "intercepted"
+		}
+		return next(ctx, call)
+	})).Save(&RetryTestModel{Pk: 4, Name: "d"}).Error)
+	row := &RetryTestModel{Pk: 4}
+	a.Nil(client.QueryOne(row).Error)
+	a.Equal("intercepted", row.Name)
+
+	// 直接返回响应
+	stub := New("", "", "", "", WithApi(fake), WithInterceptor(func(ctx context.Context, call *Call, next Handler) (interface{}, error) {
+		return &aliTableStore.GetRowResponse{Columns: []*aliTableStore.AttributeColumn{{ColumnName: "name", Value: "stub"}}, PrimaryKey: aliTableStore.PrimaryKey{PrimaryKeys: []*aliTableStore.PrimaryKeyColumn{{ColumnName: "pk", Value: int64(5)}}}}, nil
+	}))
+	row = &RetryTestModel{Pk: 5}
+	response = stub.QueryOne(row)
+	a.Nil(response.Error)
+	a.True(response.Exists)
+	a.Equal("stub", row.Name)
+	a.Equal(0, response.Attempts)
+
+	// 响应类型不匹配
+	wrong := New("", "", "", "", WithApi(fake), WithInterceptor(func(ctx context.Context, call *Call, next Handler) (interface{}, error) {
+		return &aliTableStore.PutRowResponse{}, nil
+	}))
+	a.NotNil(wrong.QueryOne(&RetryTestModel{Pk: 1}).Error)
+}
+
+func Test_Client_Telemetry(t *testing.T) {
+	a := assert.New(t)
+
+	recorder := telemetrytest.New()
+	fake := tablestoretest.New()
+	batchConfig := DefaultBatchConfig()
+	batchConfig.MaxRows = 2
+	client := New("", "", "", "", WithApi(fake), WithBatchConfig(batchConfig), WithTelemetry(
+		WithTracerProvider(recorder.TracerProvider),
+		WithMeterProvider(recorder.MeterProvider),
+	))
+
+	a.Nil(client.Insert(&RetryTestModel{Pk: 1, Name: "a"}).Error)
+	a.Nil(client.BatchInsert([]*RetryTestModel{{Pk: 2, Name: "b"}, {Pk: 3, Name: "c"}, {Pk: 4, Name: "d"}}).Error)
+	a.Nil(client.QueryRange(&[]*RetryTestModel{}, schema.MinPrimaryKey{}, schema.MaxPrimaryKey{}, 10).Error)
+
+	// 每一次调用一个span, sdk请求的span是它的子span, 子span先结束
+	spans := recorder.Spans()
+	a.Len(spans, 8)
+	attributes := func(index int) map[attribute.Key]attribute.Value {
+		values := map[attribute.Key]attribute.Value{}
+		for _, kv := range spans[index].Attributes {
+			values[kv.Key] = kv.Value
+		}
+		return values
+	}
+	isChild := func(child int, parent int) bool {
+		return spans[parent].SpanContext.TraceID() == spans[child].Parent.TraceID() && spans[parent].SpanContext.SpanID() == spans[child].Parent.SpanID()
+	}
+
+	a.Equal("tablestore.PutRow", spans[0].Name)
+	a.Equal(trace.SpanKindClient, spans[0].SpanKind)
+	a.Equal("retry_test", attributes(0)[AttributeTable].AsString())
+	a.Equal(OperationPutRow, attributes(0)[AttributeOperation].AsString())
+	a.Equal(int64(1), attributes(0)[AttributeRows].AsInt64())
+	a.Equal(int64(1), attributes(0)[AttributeWriteCapacityUnit].AsInt64())
+
+	a.Equal("tablestore.Insert", spans[1].Name)
+	a.Equal(trace.SpanKindInternal, spans[1].SpanKind)
+	a.False(spans[1].Parent.IsValid())
+	a.True(isChild(0, 1))
+	a.Equal("Insert", attributes(1)[AttributeOperation].AsString())
+	a.Equal(int64(1), attributes(1)[AttributeAttempts].AsInt64())
+
+	// 拆分的多个请求在同一个span下, 汇总请求数, 行数和CU, BatchInsert中的BatchWrite也是一个子span
+	a.Equal("tablestore.BatchWriteRow", spans[2].Name)
+	a.Equal("tablestore.BatchWriteRow", spans[3].Name)
+	a.Equal(int64(3), attributes(2)[AttributeRowsWritten].AsInt64()+attributes(3)[AttributeRowsWritten].AsInt64())
+	a.Equal(int64(3), attributes(2)[AttributeWriteCapacityUnit].AsInt64()+attributes(3)[AttributeWriteCapacityUnit].AsInt64())
+
+	a.Equal("tablestore.BatchWrite", spans[4].Name)
+	a.True(isChild(2, 4))
+	a.True(isChild(3, 4))
+	a.Equal("tablestore.BatchInsert", spans[5].Name)
+	a.True(isChild(4, 5))
+	a.Equal("retry_test", attributes(5)[AttributeTable].AsString())
+	a.Equal(int64(2), attributes(5)[AttributeAttempts].AsInt64())
+	a.Equal(int64(3), attributes(5)[AttributeRowsWritten].AsInt64())
+	a.Equal(int64(3), attributes(5)[AttributeWriteCapacityUnit].AsInt64())
+
+	a.Equal("tablestore.GetRange", spans[6].Name)
+	a.Equal(int64(4), attributes(6)[AttributeRowsRead].AsInt64())
+	a.Equal(int64(4), attributes(6)[AttributeReadCapacityUnit].AsInt64())
+	a.Equal("tablestore.QueryRange", spans[7].Name)
+	a.True(isChild(6, 7))
+	a.Equal(int64(4), attributes(7)[AttributeRowsRead].AsInt64())
+	a.Equal(int64(4), attributes(7)[AttributeReadCapacityUnit].AsInt64())
+
+	table := AttributeTable.String("retry_test")
+	a.Equal(int64(4), recorder.Sum(MetricRowsWritten, table))
+	a.Equal(int64(4), recorder.Sum(MetricWriteCapacityUnit, table))
+	a.Equal(int64(4), recorder.Sum(MetricRowsRead, table, AttributeOperation.String(OperationGetRange)))
+	a.Equal(int64(4), recorder.Sum(MetricReadCapacityUnit, table))
+	a.Equal(uint64(4), recorder.Count(MetricDuration, table))
+
+	// 失败的请求记录错误码
+	recorder.Reset()
+	insertResponse := client.Insert(&RetryTestModel{Pk: 1, Name: "a"})
+	a.True(IsConditionFailed(insertResponse.Error))
+	spans = recorder.Spans()
+	a.Len(spans, 2)
+	a.Equal(codes.Error, spans[0].Status.Code)
+	a.Equal(ConditionCheckFail, attributes(0)[AttributeErrorCode].AsString())
+	a.Equal(codes.Error, spans[1].Status.Code)
+	a.Equal(ConditionCheckFail, attributes(1)[AttributeErrorCode].AsString())
+	a.Equal(uint64(1), recorder.Count(MetricDuration, AttributeErrorCode.String(ConditionCheckFail)))
+
+	// 事务中的调用是事务span的子span
+	recorder.Reset()
+	a.Nil(client.Transaction(context.Background(), &RetryTestModel{Pk: 5}, func(tx *Tx) error {
+		return tx.Insert(&RetryTestModel{Pk: 5, Name: "e"}).Error
+	}))
+	spans = recorder.Spans()
+	a.Equal("tablestore.Transaction", spans[len(spans)-1].Name)
+	for index := range spans[:len(spans)-1] {
+		a.True(spans[index].SpanContext.TraceID() == spans[len(spans)-1].SpanContext.TraceID())
+	}
+}
+
+func Test_Client_CapacityUnit(t *testing.T) {
+	a := assert.New(t)
+
+	fake := tablestoretest.New()
+	batchConfig := DefaultBatchConfig()
+	batchConfig.MaxRows = 2
+	client := New("", "", "", "", WithApi(fake), WithBatchConfig(batchConfig))
+
+	insertResponse := client.Insert(&RetryTestModel{Pk: 1, Name: "a"})
+	a.Nil(insertResponse.Error)
+	a.Equal(CapacityUnit{Write: 1}, insertResponse.CapacityUnit)
+
+	response := client.QueryOne(&RetryTestModel{Pk: 1})
+	a.Nil(response.Error)
+	a.Equal(CapacityUnit{Read: 1}, response.CapacityUnit)
+
+	// 批量写入拆分成多个请求的时候累加
+	batchResponse := client.BatchInsert([]*RetryTestModel{{Pk: 2, Name: "b"}, {Pk: 3, Name: "c"}, {Pk: 4, Name: "d"}})
+	a.Nil(batchResponse.Error)
+	a.Equal(2, batchResponse.Attempts)
+	a.Equal(CapacityUnit{Write: 3}, batchResponse.CapacityUnit)
+	for _, result := range batchResponse.Results {
+		a.Equal(CapacityUnit{Write: 1}, result.CapacityUnit)
+	}
+
+	rows := []*RetryTestModel{{Pk: 1}, {Pk: 2}, {Pk: 3}}
+	queryAllResponse := client.QueryAll(&rows)
+	a.Nil(queryAllResponse.Error)
+	a.Equal(CapacityUnit{Read: 3}, queryAllResponse.CapacityUnit)
+	a.Equal(CapacityUnit{Read: 1}, queryAllResponse.Results[0].CapacityUnit)
+
+	rangeResponse := client.QueryRange(&[]*RetryTestModel{}, schema.MinPrimaryKey{}, schema.MaxPrimaryKey{}, 10)
+	a.Nil(rangeResponse.Error)
+	a.Equal(CapacityUnit{Read: 4}, rangeResponse.CapacityUnit)
+
+	// 翻页的时候累加每一页
+	iterator := NewRangeIterator[RetryTestModel](context.Background(), client, schema.MinPrimaryKey{}, schema.MaxPrimaryKey{}, WithPageSize(3))
+	for iterator.Next() {
+	}
+	a.Nil(iterator.Err())
+	a.Equal(CapacityUnit{Read: 4}, iterator.CapacityUnit())
+
+	// 客户端按照表累计, 派生的客户端共用
+	a.Nil(client.Unscoped().Insert(&RetryTestModel{Pk: 5, Name: "e"}).Error)
+	a.Equal(map[string]CapacityUnit{"retry_test": {Read: 12, Write: 5}}, client.CapacityUnits().Snapshot())
+	a.Equal(CapacityUnit{Read: 12, Write: 5}, client.CapacityUnits().Total())
+	a.Equal(CapacityUnit{Read: 12, Write: 5}, client.CapacityUnits().Reset()["retry_test"])
+	a.Empty(client.CapacityUnits().Snapshot())
+
+	// 多个客户端共用
+	accumulator := NewCapacityAccumulator()
+	first := New("", "", "", "", WithApi(fake), WithCapacityAccumulator(accumulator))
+	second := New("", "", "", "", WithApi(fake), WithCapacityAccumulator(accumulator))
+	a.Nil(first.QueryOne(&RetryTestModel{Pk: 1}).Error)
+	a.Nil(second.QueryOne(&RetryTestModel{Pk: 2}).Error)
+	a.Equal(CapacityUnit{Read: 2}, accumulator.Snapshot()["retry_test"])
+}
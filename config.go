@@ -5,5 +5,9 @@ type Config struct {
 	InstanceName    string
 	AccessKeyId     string
 	AccessKeySecret string
-	Options         []ClientOption
+
+	// 为nil的时候不重试, 也可以使用WithRetryPolicy设置
+	RetryPolicy *RetryPolicy
+
+	Options []ClientOption
 }
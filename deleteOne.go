@@ -1,32 +1,115 @@
 package tablestore
 
 import (
+	"context"
 	aliTableStore "github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
 	"github.com/hughcube-go/tablestore/schema"
 )
 
 type DeleteResponse struct {
-	Error        error
-	Response     *aliTableStore.DeleteRowResponse
+	Error          error
+	Response       *aliTableStore.DeleteRowResponse
+	UpdateResponse *aliTableStore.UpdateRowResponse
+
+	// 软删除时行不存在或者已经软删除为0, 物理删除不检查行是否存在, 成功的时候为1
 	RowsAffected int
+
+	// 发起的DeleteRow请求数, 软删除的时候是UpdateRow请求数, 包含重试
+	Attempts int
+
+	// 删除请求消耗的CU
+	CapacityUnit CapacityUnit
 }
 
+// DeleteOne 存在软删除字段的时候只给软删除字段赋值, 否则物理删除
 func (t *TableStore) DeleteOne(row schema.Tabler) DeleteResponse {
+	return t.DeleteOneCtx(context.Background(), row)
+}
+
+func (t *TableStore) DeleteOneCtx(ctx context.Context, row schema.Tabler) (result DeleteResponse) {
+	ctx, op := t.startOperation(ctx, "DeleteOne")
+	defer func() { result.Attempts, result.CapacityUnit = op.end(result.Error) }()
+
 	tableSchema, err := t.ParseSchema(row)
 	if err != nil {
 		return DeleteResponse{Error: err}
 	}
 
+	if softDeleteField := tableSchema.GetSoftDeleteField(); nil != softDeleteField && !t.unscoped {
+		return t.softDeleteOne(ctx, row, tableSchema, softDeleteField)
+	}
+
+	condition, err := t.buildWriteCondition(tableSchema, aliTableStore.RowExistenceExpectation_IGNORE)
+	if err != nil {
+		return DeleteResponse{Error: err}
+	}
+
+	primaryKey, err := tableSchema.BuildRequestPrimaryKey(row)
+	if err != nil {
+		return DeleteResponse{Error: err}
+	}
+
 	request := new(aliTableStore.DeleteRowRequest)
 	request.DeleteRowChange = new(aliTableStore.DeleteRowChange)
 	request.DeleteRowChange.TableName = row.TableName()
-	request.DeleteRowChange.SetCondition(aliTableStore.RowExistenceExpectation_IGNORE)
-	request.DeleteRowChange.PrimaryKey = tableSchema.BuildRequestPrimaryKey(row)
+	request.DeleteRowChange.Condition = condition
+	request.DeleteRowChange.PrimaryKey = primaryKey
 
-	response, err := t.DeleteRow(request)
+	response, err := t.deleteRow(ctx, request)
 	if err != nil {
 		return DeleteResponse{Error: err, Response: response}
 	}
 
-	return DeleteResponse{Response: response}
+	return DeleteResponse{Response: response, RowsAffected: 1}
+}
+
+// ForceDelete 忽略软删除字段, 物理删除
+func (t *TableStore) ForceDelete(row schema.Tabler) DeleteResponse {
+	return t.ForceDeleteCtx(context.Background(), row)
+}
+
+func (t *TableStore) ForceDeleteCtx(ctx context.Context, row schema.Tabler) DeleteResponse {
+	return t.Unscoped().DeleteOneCtx(ctx, row)
+}
+
+func (t *TableStore) softDeleteOne(ctx context.Context, row schema.Tabler, tableSchema *schema.Schema, softDeleteField *schema.Field) DeleteResponse {
+	// 行不存在的时候不写入, 避免产生只有软删除字段的行, 已经软删除的行不覆盖删除时间
+	condition, err := t.buildWriteCondition(tableSchema, aliTableStore.RowExistenceExpectation_EXPECT_EXIST, tableSchema.BuildNotSoftDeletedFilter())
+	if err != nil {
+		return DeleteResponse{Error: err}
+	}
+
+	deletedAt := softDeleteField.AutoTimeValue(t.Now())
+
+	updateRowChange, directlyColumns, err := tableSchema.BuildRequestUpdateColumns(map[string]interface{}{
+		softDeleteField.DBName: deletedAt,
+	})
+	if err != nil {
+		return DeleteResponse{Error: err}
+	}
+
+	primaryKey, err := tableSchema.BuildRequestPrimaryKey(row)
+	if err != nil {
+		return DeleteResponse{Error: err}
+	}
+
+	request := new(aliTableStore.UpdateRowRequest)
+	request.UpdateRowChange = updateRowChange
+	request.UpdateRowChange.TableName = row.TableName()
+	request.UpdateRowChange.PrimaryKey = primaryKey
+	request.UpdateRowChange.Condition = condition
+
+	// 没有Where条件的时候只有行不存在或者已经软删除才会检查失败, 视为没有删除, 否则无法区分是哪一个条件失败, 返回错误
+	response, err := t.updateRow(ctx, request)
+	if IsConditionFailed(err) && nil == t.where {
+		return DeleteResponse{UpdateResponse: response}
+	} else if err != nil {
+		return DeleteResponse{Error: err, UpdateResponse: response}
+	}
+
+	if err = tableSchema.FillRowColumns(row, directlyColumns); err != nil {
+		return DeleteResponse{Error: err, UpdateResponse: response, RowsAffected: 1}
+	}
+
+	return DeleteResponse{UpdateResponse: response, RowsAffected: 1}
 }
@@ -0,0 +1,140 @@
+package tablestore
+
+import (
+	"errors"
+	aliTableStore "github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+)
+
+const (
+	ConditionCheckFail    = "OTSConditionCheckFail"
+	ObjectNotExist        = "OTSObjectNotExist"
+	CapacityUnitExhausted = "OTSCapacityUnitExhausted"
+)
+
+// 使用errors.Is判断错误的类别
+//
+//	if response := client.Insert(row); errors.Is(response.Error, tablestore.ErrConditionFailed) {
+//		// 行已经存在
+//	}
+var (
+	ErrConditionFailed = errors.New("tablestore: condition check failed")
+	ErrNotFound        = errors.New("tablestore: object not exist")
+	ErrThrottled       = errors.New("tablestore: throttled")
+	ErrRetryable       = errors.New("tablestore: retryable")
+)
+
+// 可以重试的错误码, 其中超时和服务端内部错误的时候请求可能已经执行, 非幂等的请求不能直接重试
+var retryableErrorCodes = map[string]bool{
+	aliTableStore.ROW_OPERATION_CONFLICT:   true,
+	aliTableStore.NOT_ENOUGH_CAPACITY_UNIT: true,
+	aliTableStore.TABLE_NOT_READY:          true,
+	aliTableStore.PARTITION_UNAVAILABLE:    true,
+	aliTableStore.SERVER_BUSY:              true,
+	aliTableStore.STORAGE_SERVER_BUSY:      true,
+	aliTableStore.QUOTA_EXHAUSTED:          true,
+	aliTableStore.STORAGE_TIMEOUT:          true,
+	aliTableStore.SERVER_UNAVAILABLE:       true,
+	aliTableStore.INTERNAL_SERVER_ERROR:    true,
+	CapacityUnitExhausted:                  true,
+}
+
+// 服务端限流的错误码
+var throttledErrorCodes = map[string]bool{
+	aliTableStore.NOT_ENOUGH_CAPACITY_UNIT: true,
+	aliTableStore.SERVER_BUSY:              true,
+	aliTableStore.STORAGE_SERVER_BUSY:      true,
+	aliTableStore.QUOTA_EXHAUSTED:          true,
+	CapacityUnitExhausted:                  true,
+}
+
+func isRetryableErrorCode(code string) bool {
+	return retryableErrorCodes[code]
+}
+
+// Error 表格存储返回的错误, 各个方法请求失败的时候返回的都是*Error, 批量操作中的行错误使用Err()获取
+type Error struct {
+	Code       string
+	Message    string
+	RequestId  string
+	HttpStatus int
+
+	// sdk返回的原始错误, 行级别的错误为nil
+	err error
+}
+
+// 只转换sdk返回的*aliTableStore.OtsError, 其他错误原样返回
+func wrapError(err error) error {
+	var otsError *aliTableStore.OtsError
+	if errors.As(err, &otsError) {
+		return &Error{
+			Code:       otsError.Code,
+			Message:    otsError.Message,
+			RequestId:  otsError.RequestId,
+			HttpStatus: otsError.HttpStatusCode,
+			err:        err,
+		}
+	}
+	return err
+}
+
+// 批量操作中失败的行的错误
+func newRowError(code string, message string, requestId string) *Error {
+	return &Error{Code: code, Message: message, RequestId: requestId}
+}
+
+// Error 和sdk的错误信息保持一致
+func (e *Error) Error() string {
+	return e.Code + " " + e.Message + " " + e.RequestId
+}
+
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// Retryable 相同的请求重试之后可能成功
+func (e *Error) Retryable() bool {
+	return isRetryableErrorCode(e.Code)
+}
+
+func (e *Error) Is(target error) bool {
+	switch target {
+	case ErrConditionFailed:
+		return ConditionCheckFail == e.Code
+	case ErrNotFound:
+		return ObjectNotExist == e.Code
+	case ErrThrottled:
+		return throttledErrorCodes[e.Code]
+	case ErrRetryable:
+		return e.Retryable()
+	}
+	return false
+}
+
+// ErrorCode 返回*Error的错误码, 其他错误返回空字符串
+func ErrorCode(err error) string {
+	var tableStoreError *Error
+	if errors.As(err, &tableStoreError) {
+		return tableStoreError.Code
+	}
+	return ""
+}
+
+// IsConditionFailed 行存在性或者列条件检查失败
+func IsConditionFailed(err error) bool {
+	return errors.Is(err, ErrConditionFailed)
+}
+
+// IsNotFound 表或者索引不存在
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
+
+// IsThrottled 服务端限流, 需要等待之后重试
+func IsThrottled(err error) bool {
+	return errors.Is(err, ErrThrottled)
+}
+
+// IsRetryable 相同的请求重试之后可能成功
+func IsRetryable(err error) bool {
+	return errors.Is(err, ErrRetryable)
+}
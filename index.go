@@ -0,0 +1,104 @@
+package tablestore
+
+import (
+	"context"
+	"errors"
+	aliTableStore "github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/hughcube-go/tablestore/schema"
+)
+
+// ErrIndexFilterWithoutBackFetch 索引表中一般不包含软删除字段和Where中的列, 缺少的列不会被过滤, 需要回表之后在主表上过滤
+var ErrIndexFilterWithoutBackFetch = errors.New("index query with soft delete or where filters requires WithBackFetch")
+
+type indexConfig struct {
+	name      string
+	backFetch bool
+}
+
+type IndexOption func(*indexConfig)
+
+// WithBackFetch 查询索引之后再从主表查询完整的行, 过滤条件在主表上生效, 主表中不存在或者被过滤的行不返回
+func WithBackFetch() IndexOption {
+	return func(config *indexConfig) {
+		config.backFetch = true
+	}
+}
+
+// UseIndex 返回一个使用二级索引查询的客户端, 只影响QueryRange, 范围的主键使用索引的主键列,
+// 模型存在软删除字段或者使用了Where的时候需要WithBackFetch, 否则返回ErrIndexFilterWithoutBackFetch, Unscoped之后不过滤软删除的行
+//
+//	client.UseIndex("idx_name").QueryRange(&list, schema.MinPrimaryKey{"Name": "a"}, schema.MaxPrimaryKey{"Name": "a"}, 100)
+func (t *TableStore) UseIndex(name string, options ...IndexOption) *TableStore {
+	config := &indexConfig{name: name}
+	for _, option := range options {
+		option(config)
+	}
+
+	client := *t
+	client.index = config
+	return &client
+}
+
+func (t *TableStore) withoutIndex() *TableStore {
+	if nil == t.index {
+		return t
+	}
+
+	client := *t
+	client.index = nil
+	return &client
+}
+
+type CreateIndexesResponse struct {
+	Error    error
+	Created  []string
+	Existing []string
+}
+
+// CreateIndexes 创建模型中定义的不存在的二级索引, 已经存在的索引不会修改
+func (t *TableStore) CreateIndexes(model schema.Tabler) CreateIndexesResponse {
+	return t.CreateIndexesCtx(context.Background(), model)
+}
+
+func (t *TableStore) CreateIndexesCtx(ctx context.Context, model schema.Tabler) (result CreateIndexesResponse) {
+	ctx, op := t.startOperation(ctx, "CreateIndexes")
+	defer func() { op.end(result.Error) }()
+
+	tableSchema, err := t.ParseSchema(model)
+	if err != nil {
+		return CreateIndexesResponse{Error: err}
+	}
+
+	describeResponse, err := t.describeTable(ctx, &aliTableStore.DescribeTableRequest{TableName: model.TableName()})
+	if err != nil {
+		return CreateIndexesResponse{Error: err}
+	}
+
+	existing := map[string]bool{}
+	for _, indexMeta := range describeResponse.IndexMetas {
+		existing[indexMeta.IndexName] = true
+	}
+
+	response := CreateIndexesResponse{}
+	for _, index := range tableSchema.GetIndexes(model) {
+		if existing[index.Name] {
+			response.Existing = append(response.Existing, index.Name)
+			continue
+		}
+
+		indexMeta, err := tableSchema.BuildIndexMeta(index)
+		if err != nil {
+			response.Error = err
+			return response
+		}
+
+		request := &aliTableStore.CreateIndexRequest{MainTableName: model.TableName(), IndexMeta: indexMeta, IncludeBaseData: true}
+		if _, err := t.createIndex(ctx, request); err != nil {
+			response.Error = err
+			return response
+		}
+		response.Created = append(response.Created, index.Name)
+	}
+
+	return response
+}
@@ -1,6 +1,7 @@
 package tablestore
 
 import (
+	"context"
 	aliTableStore "github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
 	"github.com/hughcube-go/tablestore/schema"
 )
@@ -9,6 +10,12 @@ type InstallResponse struct {
 	Error    error
 	Response *aliTableStore.PutRowResponse
 	LastId   int64
+
+	// 发起的PutRow请求数, 包含重试
+	Attempts int
+
+	// PutRow请求消耗的CU
+	CapacityUnit CapacityUnit
 }
 
 func (t *TableStore) BuildInsertRequest(row schema.Tabler) (*aliTableStore.PutRowRequest, error) {
@@ -17,13 +24,27 @@ func (t *TableStore) BuildInsertRequest(row schema.Tabler) (*aliTableStore.PutRo
 		return nil, err
 	}
 
+	tableSchema.SetAutoTime(row, t.Now(), true)
+
 	request := new(aliTableStore.PutRowRequest)
-	request.PutRowChange = tableSchema.BuildRequestPutRowChange(row)
+	if request.PutRowChange, err = tableSchema.BuildRequestPutRowChange(row); err != nil {
+		return nil, err
+	}
+	if request.PutRowChange.Condition, err = t.buildWriteCondition(tableSchema, request.PutRowChange.Condition.RowExistenceExpectation); err != nil {
+		return nil, err
+	}
 
 	return request, nil
 }
 
 func (t *TableStore) Insert(row schema.Tabler, options ...func(*aliTableStore.PutRowRequest)) InstallResponse {
+	return t.InsertCtx(context.Background(), row, options...)
+}
+
+func (t *TableStore) InsertCtx(ctx context.Context, row schema.Tabler, options ...func(*aliTableStore.PutRowRequest)) (result InstallResponse) {
+	ctx, op := t.startOperation(ctx, "Insert")
+	defer func() { result.Attempts, result.CapacityUnit = op.end(result.Error) }()
+
 	request, err := t.BuildInsertRequest(row)
 	if err != nil {
 		return InstallResponse{Error: err}
@@ -38,17 +59,26 @@ func (t *TableStore) Insert(row schema.Tabler, options ...func(*aliTableStore.Pu
 		return InstallResponse{Error: err}
 	}
 
-	response, err := t.PutRow(request)
+	response, err := t.putRow(ctx, request)
 	if err != nil {
 		return InstallResponse{Error: err, Response: response}
 	}
 
-	// 如果存在自增键, 结果带上
+	return InstallResponse{LastId: lastInsertId(tableSchema, response), Response: response}
+}
+
+// 写入返回的主键中自增列的值, 没有自增列的时候为0
+func lastInsertId(tableSchema *schema.Schema, response *aliTableStore.PutRowResponse) int64 {
+	autoIncrField := tableSchema.GetAutoIncrField()
+	if nil == autoIncrField || nil == response {
+		return 0
+	}
+
 	for _, v := range response.PrimaryKey.PrimaryKeys {
-		if autoIncrField := tableSchema.GetAutoIncrField(); nil != autoIncrField && autoIncrField.DBName == v.ColumnName {
-			return InstallResponse{LastId: v.Value.(int64), Response: response}
+		if autoIncrField.DBName == v.ColumnName {
+			id, _ := v.Value.(int64)
+			return id
 		}
 	}
-
-	return InstallResponse{Response: response}
+	return 0
 }
@@ -1,63 +1,98 @@
 package tablestore
 
 import (
+	"context"
 	aliTableStore "github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
 	"github.com/hughcube-go/tablestore/schema"
 )
 
 type BatchInstallResponse struct {
-	Error        error
+	Error error
+	// 最先返回的请求的响应, 所有请求的响应在Responses中
 	Response     *aliTableStore.BatchWriteRowResponse
+	Responses    []*aliTableStore.BatchWriteRowResponse
+	Results      []BatchWriteRowResult
 	FailureCount int
+
+	// 发起的BatchWriteRow请求数, 包含按照BatchConfig拆分的请求, 请求的重试和失败的行的重试
+	Attempts int
+
+	// 成功的BatchWriteRow请求返回的CU的和
+	CapacityUnit CapacityUnit
 }
 
-func (t *TableStore) BuildBatchInsertRequest(list interface{}, rowOptions ...func(*aliTableStore.PutRowChange)) (*aliTableStore.BatchWriteRowRequest, error) {
+func (t *TableStore) BuildBatchInsertRowChanges(list interface{}, rowOptions ...func(*aliTableStore.PutRowChange)) ([]aliTableStore.RowChange, error) {
 	rows, err := schema.ToTablerSlice(list, false)
 	if err != nil {
 		return nil, err
 	}
 
-	request := new(aliTableStore.BatchWriteRowRequest)
+	changes := make([]aliTableStore.RowChange, 0, len(rows))
 
+	now := t.Now()
 	for _, row := range rows {
 		tableSchema, err := t.ParseSchema(row)
 		if err != nil {
 			return nil, err
 		}
 
-		putRowChange := tableSchema.BuildRequestPutRowChange(row)
+		tableSchema.SetAutoTime(row, now, true)
+
+		putRowChange, err := tableSchema.BuildRequestPutRowChange(row)
+		if err != nil {
+			return nil, err
+		}
+		if putRowChange.Condition, err = t.buildWriteCondition(tableSchema, putRowChange.Condition.RowExistenceExpectation); err != nil {
+			return nil, err
+		}
 		for _, rowOption := range rowOptions {
 			rowOption(putRowChange)
 		}
-		request.AddRowChange(putRowChange)
+		changes = append(changes, putRowChange)
 	}
 
-	return request, nil
+	return changes, nil
 }
 
-func (t *TableStore) BatchInsert(list interface{}, options ...func(*aliTableStore.BatchWriteRowRequest)) BatchInstallResponse {
-	request, err := t.BuildBatchInsertRequest(list)
+func (t *TableStore) BuildBatchInsertRequest(list interface{}, rowOptions ...func(*aliTableStore.PutRowChange)) (*aliTableStore.BatchWriteRowRequest, error) {
+	changes, err := t.BuildBatchInsertRowChanges(list, rowOptions...)
 	if err != nil {
-		return BatchInstallResponse{Error: err}
+		return nil, err
 	}
 
-	for _, option := range options {
-		option(request)
+	request := new(aliTableStore.BatchWriteRowRequest)
+	for _, change := range changes {
+		request.AddRowChange(change)
 	}
 
-	response, err := t.BatchWriteRow(request)
+	return request, nil
+}
+
+func (t *TableStore) BatchInsert(list interface{}, options ...func(*aliTableStore.BatchWriteRowRequest)) BatchInstallResponse {
+	return t.BatchInsertCtx(context.Background(), list, options...)
+}
+
+// BatchInsertCtx 超过单次请求限制的时候自动拆分, 请求成功但是失败的行按照BatchConfig重试, Results和list中的行一一对应
+func (t *TableStore) BatchInsertCtx(ctx context.Context, list interface{}, options ...func(*aliTableStore.BatchWriteRowRequest)) (result BatchInstallResponse) {
+	ctx, op := t.startOperation(ctx, "BatchInsert")
+	defer func() { result.Attempts, result.CapacityUnit = op.end(result.Error) }()
+
+	changes, err := t.BuildBatchInsertRowChanges(list)
 	if err != nil {
-		return BatchInstallResponse{Error: err, Response: response}
+		return BatchInstallResponse{Error: err}
 	}
 
-	failureCount := 0
-	for _, tableRowResponses := range response.TableToRowsResult {
-		for _, tableRowResponse := range tableRowResponses {
-			if !tableRowResponse.IsSucceed {
-				failureCount++
-			}
-		}
+	response := t.BatchWriteCtx(ctx, changes, options...)
+
+	installResponse := BatchInstallResponse{
+		Error:        response.Error,
+		Responses:    response.Responses,
+		Results:      response.Results,
+		FailureCount: response.FailureCount,
+	}
+	if 0 < len(response.Responses) {
+		installResponse.Response = response.Responses[0]
 	}
 
-	return BatchInstallResponse{Response: response, FailureCount: failureCount}
+	return installResponse
 }
@@ -0,0 +1,139 @@
+package tablestore
+
+import (
+	"context"
+	"fmt"
+	aliTableStore "github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"sort"
+)
+
+// 请求的操作名称, 和sdk的方法名一致
+const (
+	OperationPutRow                = "PutRow"
+	OperationGetRow                = "GetRow"
+	OperationGetRange              = "GetRange"
+	OperationBatchGetRow           = "BatchGetRow"
+	OperationBatchWriteRow         = "BatchWriteRow"
+	OperationUpdateRow             = "UpdateRow"
+	OperationDeleteRow             = "DeleteRow"
+	OperationCreateTable           = "CreateTable"
+	OperationDescribeTable         = "DescribeTable"
+	OperationUpdateTable           = "UpdateTable"
+	OperationAddDefinedColumn      = "AddDefinedColumn"
+	OperationCreateIndex           = "CreateIndex"
+	OperationSearch                = "Search"
+	OperationStartLocalTransaction = "StartLocalTransaction"
+	OperationCommitTransaction     = "CommitTransaction"
+	OperationAbortTransaction      = "AbortTransaction"
+)
+
+// Call 发起的一次sdk请求
+type Call struct {
+	Operation string
+
+	// 请求涉及的表, 批量请求可能有多个, 按照名称排序, 提交和回滚事务时为空
+	TableNames []string
+
+	// sdk的请求, 比如*aliTableStore.PutRowRequest, 拦截器可以修改或者替换成同类型的请求
+	Request interface{}
+}
+
+// TableName 请求涉及的第一个表
+func (c *Call) TableName() string {
+	if 0 == len(c.TableNames) {
+		return ""
+	}
+	return c.TableNames[0]
+}
+
+// Handler 执行请求, 返回sdk的响应, 比如*aliTableStore.PutRowResponse
+type Handler func(ctx context.Context, call *Call) (interface{}, error)
+
+// Interceptor 包装每一次请求, 调用next继续执行, 不调用next的时候直接返回结果, 返回的响应必须和sdk的响应类型一致
+//
+//	func logging(ctx context.Context, call *tablestore.Call, next tablestore.Handler) (interface{}, error) {
+//		start := time.Now()
+//		response, err := next(ctx, call)
+//		log.Printf("%s %v %s %v", call.Operation, call.TableNames, time.Since(start), err)
+//		return response, err
+//	}
+type Interceptor func(ctx context.Context, call *Call, next Handler) (interface{}, error)
+
+// WithInterceptor 添加拦截器, 先添加的在外层, 拦截器包含重试, 每次调用只执行一次
+func WithInterceptor(interceptors ...Interceptor) ClientOption {
+	return func(t *TableStore) {
+		t.interceptors = append(t.interceptors[:len(t.interceptors):len(t.interceptors)], interceptors...)
+	}
+}
+
+// 经过拦截器之后按照RetryPolicy发起请求
+func intercept[R any, T any](ctx context.Context, t *TableStore, call *Call, idempotent func(R) bool, do func(R) (T, error)) (T, error) {
+	var handler Handler = func(ctx context.Context, call *Call) (interface{}, error) {
+		request, ok := call.Request.(R)
+		if !ok {
+			return nil, fmt.Errorf("interceptor replaced %s request with %T", call.Operation, call.Request)
+		}
+		return retryInvoke(ctx, t, idempotent(request), func() (T, error) {
+			return do(request)
+		})
+	}
+
+	for i := len(t.interceptors) - 1; i >= 0; i-- {
+		interceptor, next := t.interceptors[i], handler
+		handler = func(ctx context.Context, call *Call) (interface{}, error) {
+			return interceptor(ctx, call, next)
+		}
+	}
+
+	var zero T
+	response, err := handler(ctx, call)
+	t.recordUsage(ctx, call, response)
+	if nil == response {
+		return zero, err
+	}
+	typed, ok := response.(T)
+	if !ok {
+		return zero, fmt.Errorf("interceptor returned %T for %s, expected %T", response, call.Operation, zero)
+	}
+	return typed, err
+}
+
+func newCall(operation string, request interface{}, tableNames ...string) *Call {
+	return &Call{Operation: operation, TableNames: tableNames, Request: request}
+}
+
+// 幂等性不依赖请求内容的操作
+func idempotentRequest[R any](R) bool {
+	return true
+}
+
+func nonIdempotentRequest[R any](R) bool {
+	return false
+}
+
+func batchGetRowTableNames(request *aliTableStore.BatchGetRowRequest) []string {
+	names := make([]string, 0, len(request.MultiRowQueryCriteria))
+	for _, criteria := range request.MultiRowQueryCriteria {
+		names = append(names, criteria.TableName)
+	}
+	return sortedTableNames(names)
+}
+
+func batchWriteRowTableNames(request *aliTableStore.BatchWriteRowRequest) []string {
+	names := make([]string, 0, len(request.RowChangesGroupByTable))
+	for name := range request.RowChangesGroupByTable {
+		names = append(names, name)
+	}
+	return sortedTableNames(names)
+}
+
+func sortedTableNames(names []string) []string {
+	sort.Strings(names)
+	unique := names[:0]
+	for i, name := range names {
+		if 0 == i || name != names[i-1] {
+			unique = append(unique, name)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,173 @@
+package tablestore
+
+import (
+	"context"
+	"fmt"
+	aliTableStore "github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/hughcube-go/tablestore/schema"
+	"strings"
+)
+
+// MigrateResult 对应一个模型的表
+type MigrateResult struct {
+	TableName string
+	Created   bool
+
+	// 可以在线修改的差异, 比如TTL, 最大版本数, 预留吞吐量, 新增的预定义列
+	Changes []string
+
+	// 无法在线修改的差异, 比如主键, 预定义列的类型, 需要手动处理
+	Incompatible []string
+
+	// 是否已经执行了建表或者Changes中的修改
+	Applied bool
+}
+
+type MigrateResponse struct {
+	Error   error
+	Results []MigrateResult
+}
+
+// AutoMigrate 创建不存在的表, 已经存在的表和模型对比之后应用可以在线修改的差异, 存在无法修改的差异时返回错误
+func (t *TableStore) AutoMigrate(models ...schema.Tabler) MigrateResponse {
+	return t.AutoMigrateCtx(context.Background(), models...)
+}
+
+func (t *TableStore) AutoMigrateCtx(ctx context.Context, models ...schema.Tabler) MigrateResponse {
+	return t.migrate(ctx, "AutoMigrate", true, models)
+}
+
+// PlanMigrate 只对比模型和表的差异, 不做任何修改
+func (t *TableStore) PlanMigrate(models ...schema.Tabler) MigrateResponse {
+	return t.PlanMigrateCtx(context.Background(), models...)
+}
+
+func (t *TableStore) PlanMigrateCtx(ctx context.Context, models ...schema.Tabler) MigrateResponse {
+	return t.migrate(ctx, "PlanMigrate", false, models)
+}
+
+func (t *TableStore) migrate(ctx context.Context, operationName string, apply bool, models []schema.Tabler) (response MigrateResponse) {
+	ctx, op := t.startOperation(ctx, operationName)
+	defer func() { op.end(response.Error) }()
+
+	for _, model := range models {
+		result, err := t.migrateTable(ctx, apply, model)
+		response.Results = append(response.Results, result)
+		if err != nil {
+			response.Error = err
+			return response
+		}
+
+		if apply && 0 < len(result.Incompatible) {
+			response.Error = fmt.Errorf("table %s has incompatible changes: %s", result.TableName, strings.Join(result.Incompatible, "; "))
+			return response
+		}
+	}
+
+	return response
+}
+
+func (t *TableStore) migrateTable(ctx context.Context, apply bool, model schema.Tabler) (MigrateResult, error) {
+	result := MigrateResult{TableName: model.TableName()}
+
+	tableSchema, err := t.ParseSchema(model)
+	if err != nil {
+		return result, err
+	}
+
+	createRequest, err := tableSchema.BuildCreateTableRequest(model)
+	if err != nil {
+		return result, err
+	}
+
+	describeResponse, err := t.describeTable(ctx, &aliTableStore.DescribeTableRequest{TableName: result.TableName})
+	if IsNotFound(err) {
+		result.Created = true
+		if !apply {
+			return result, nil
+		}
+
+		if _, err := t.createTable(ctx, createRequest); err != nil {
+			return result, err
+		}
+		result.Applied = true
+		return result, nil
+	} else if err != nil {
+		return result, err
+	}
+
+	// 主键的名称, 类型, 顺序都不能修改
+	current, expected := describeResponse.TableMeta.SchemaEntry, createRequest.TableMeta.SchemaEntry
+	for index := 0; index < len(current) || index < len(expected); index++ {
+		if index >= len(current) {
+			result.Incompatible = append(result.Incompatible, fmt.Sprintf("missing primary key %s", *expected[index].Name))
+		} else if index >= len(expected) {
+			result.Incompatible = append(result.Incompatible, fmt.Sprintf("unexpected primary key %s", *current[index].Name))
+		} else if *current[index].Name != *expected[index].Name || *current[index].Type != *expected[index].Type {
+			result.Incompatible = append(result.Incompatible, fmt.Sprintf("primary key %d is %s(%d), expected %s(%d)", index+1, *current[index].Name, *current[index].Type, *expected[index].Name, *expected[index].Type))
+		} else if nil != current[index].Option && (nil == expected[index].Option || *current[index].Option != *expected[index].Option) {
+			// DescribeTable不一定返回主键的Option, 返回的时候才对比
+			result.Incompatible = append(result.Incompatible, fmt.Sprintf("primary key %s auto increment does not match", *current[index].Name))
+		}
+	}
+
+	// 新增的预定义列可以直接添加, 类型不能修改
+	currentColumns := map[string]aliTableStore.DefinedColumnType{}
+	for _, column := range describeResponse.TableMeta.DefinedColumns {
+		currentColumns[column.Name] = column.ColumnType
+	}
+	addColumns := []*aliTableStore.DefinedColumnSchema{}
+	for _, column := range createRequest.TableMeta.DefinedColumns {
+		if columnType, ok := currentColumns[column.Name]; !ok {
+			addColumns = append(addColumns, column)
+			result.Changes = append(result.Changes, fmt.Sprintf("add defined column %s(%d)", column.Name, column.ColumnType))
+		} else if columnType != column.ColumnType {
+			result.Incompatible = append(result.Incompatible, fmt.Sprintf("defined column %s is %d, expected %d", column.Name, columnType, column.ColumnType))
+		}
+	}
+
+	updateRequest := &aliTableStore.UpdateTableRequest{TableName: result.TableName}
+	if option := describeResponse.TableOption; nil != option {
+		expectedOption := createRequest.TableOption
+		if option.TimeToAlive != expectedOption.TimeToAlive || option.MaxVersion != expectedOption.MaxVersion {
+			updateRequest.TableOption = &aliTableStore.TableOption{
+				TimeToAlive:               expectedOption.TimeToAlive,
+				MaxVersion:                expectedOption.MaxVersion,
+				DeviationCellVersionInSec: option.DeviationCellVersionInSec,
+			}
+		}
+		if option.TimeToAlive != expectedOption.TimeToAlive {
+			result.Changes = append(result.Changes, fmt.Sprintf("time to alive %d -> %d", option.TimeToAlive, expectedOption.TimeToAlive))
+		}
+		if option.MaxVersion != expectedOption.MaxVersion {
+			result.Changes = append(result.Changes, fmt.Sprintf("max versions %d -> %d", option.MaxVersion, expectedOption.MaxVersion))
+		}
+	}
+	if throughput := describeResponse.ReservedThroughput; nil != throughput {
+		expectedThroughput := createRequest.ReservedThroughput
+		if throughput.Readcap != expectedThroughput.Readcap || throughput.Writecap != expectedThroughput.Writecap {
+			updateRequest.ReservedThroughput = expectedThroughput
+			result.Changes = append(result.Changes, fmt.Sprintf("reserved throughput %d/%d -> %d/%d", throughput.Readcap, throughput.Writecap, expectedThroughput.Readcap, expectedThroughput.Writecap))
+		}
+	}
+
+	// 存在无法修改的差异的时候, 不做任何修改
+	if !apply || 0 < len(result.Incompatible) || 0 == len(result.Changes) {
+		return result, nil
+	}
+
+	if nil != updateRequest.TableOption || nil != updateRequest.ReservedThroughput {
+		if _, err := t.updateTable(ctx, updateRequest); err != nil {
+			return result, err
+		}
+	}
+
+	if 0 < len(addColumns) {
+		if _, err := t.addDefinedColumn(ctx, &aliTableStore.AddDefinedColumnRequest{TableName: result.TableName, DefinedColumns: addColumns}); err != nil {
+			return result, err
+		}
+	}
+
+	result.Applied = true
+	return result, nil
+}
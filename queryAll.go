@@ -1,17 +1,55 @@
 package tablestore
 
 import (
+	"context"
 	"errors"
 	aliTableStore "github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
 	"github.com/hughcube-go/tablestore/schema"
 	"github.com/hughcube-go/utils/msslice"
 	"reflect"
+	"sync"
 )
 
+// BatchGetRowResult 对应输入中的一行, IsSucceed并且!Exists表示行不存在
+type BatchGetRowResult struct {
+	Index     int
+	IsSucceed bool
+	Exists    bool
+	Code      string
+	Message   string
+	RequestId string
+	Attempts  int
+
+	// 这一行每次成功的请求返回的CU的和, 整个请求失败的时候没有CU
+	CapacityUnit CapacityUnit
+}
+
+// Err 失败的行返回*Error, 成功或者行不存在返回nil
+func (r BatchGetRowResult) Err() error {
+	if r.IsSucceed {
+		return nil
+	}
+	return newRowError(r.Code, r.Message, r.RequestId)
+}
+
 type QueryAllResponse struct {
-	Error        error
+	Error error
+
+	// 最先返回的请求的响应
 	Response     *aliTableStore.BatchGetRowResponse
+	Responses    []*aliTableStore.BatchGetRowResponse
+	Results      []BatchGetRowResult
 	RowsAffected int
+
+	// 不存在的行和失败的行在输入中的下标
+	NotFound []int
+	Failed   []int
+
+	// 发起的BatchGetRow请求数, 包含按照BatchConfig拆分的请求, 请求的重试和失败的行的重试
+	Attempts int
+
+	// 成功的BatchGetRow请求返回的CU的和
+	CapacityUnit CapacityUnit
 }
 
 func (t *TableStore) BuildQueryAllRequest(row schema.Tabler) (*aliTableStore.GetRowRequest, error) {
@@ -29,97 +67,180 @@ func (t *TableStore) BuildQueryAllRequest(row schema.Tabler) (*aliTableStore.Get
 	request.SingleRowQueryCriteria = new(aliTableStore.SingleRowQueryCriteria)
 	request.SingleRowQueryCriteria.MaxVersion = 1
 	request.SingleRowQueryCriteria.TableName = row.TableName()
-	request.SingleRowQueryCriteria.PrimaryKey = tableSchema.BuildRequestPrimaryKey(row)
+	if request.SingleRowQueryCriteria.PrimaryKey, err = tableSchema.BuildRequestPrimaryKey(row); err != nil {
+		return nil, err
+	}
 
 	return request, nil
 }
 
 func (t *TableStore) QueryAll(list interface{}, options ...func(*aliTableStore.BatchGetRowRequest)) QueryAllResponse {
+	return t.QueryAllCtx(context.Background(), list, options...)
+}
+
+// QueryAllCtx 按照BatchConfig.MaxGetRows拆分成多个请求并发查询, 失败的行按照错误码重试, list中只保留存在的行并保持输入的顺序
+func (t *TableStore) QueryAllCtx(ctx context.Context, list interface{}, options ...func(*aliTableStore.BatchGetRowRequest)) (result QueryAllResponse) {
+	ctx, op := t.startOperation(ctx, "QueryAll")
+	defer func() { result.Attempts, result.CapacityUnit = op.end(result.Error) }()
+
 	rows, err := schema.ToTablerSlice(list, true)
 	if err != nil {
 		return QueryAllResponse{Error: err}
 	}
 
-	// 组合查询语句
-	request := new(aliTableStore.BatchGetRowRequest)
-	criteria := map[string]*aliTableStore.MultiRowQueryCriteria{}
-	for _, row := range rows {
-		tableSchema, err := t.ParseSchema(row)
-		if err != nil {
+	schemas := make([]*schema.Schema, len(rows))
+	primaryKeys := make([]*aliTableStore.PrimaryKey, len(rows))
+	filters := map[string]aliTableStore.ColumnFilter{}
+	for index, row := range rows {
+		if schemas[index], err = t.ParseSchema(row); err != nil {
 			return QueryAllResponse{Error: err}
 		}
 
+		if primaryKeys[index], err = schemas[index].BuildRequestPrimaryKey(row); err != nil {
+			return QueryAllResponse{Error: err}
+		}
+
+		if _, ok := filters[row.TableName()]; !ok {
+			if filters[row.TableName()], err = t.buildReadFilter(schemas[index]); err != nil {
+				return QueryAllResponse{Error: err}
+			}
+		}
+	}
+
+	config := t.batchConfig
+	response := QueryAllResponse{Results: make([]BatchGetRowResult, len(rows))}
+	for index := range rows {
+		response.Results[index].Index = index
+	}
+
+	var mu sync.Mutex
+	response.Error = runBatch(
+		ctx, config, len(rows),
+		func(pending []int) [][]int {
+			return splitBatchGetChunks(pending, config.MaxGetRows)
+		},
+		func(chunk []int) error {
+			chunkResponse, err := t.batchGetChunk(ctx, rows, schemas, primaryKeys, filters, chunk, response.Results, options...)
+			if nil != chunkResponse {
+				mu.Lock()
+				if nil == response.Response {
+					response.Response = chunkResponse
+				}
+				response.Responses = append(response.Responses, chunkResponse)
+				mu.Unlock()
+			}
+			return err
+		},
+		func(index int) bool {
+			return !response.Results[index].IsSucceed && t.retryPolicy.shouldRetryCode(response.Results[index].Code, true)
+		},
+	)
+
+	resultRows := []schema.Tabler{}
+	for index := range response.Results {
+		result := &response.Results[index]
+		if !result.IsSucceed && "" == result.Code && nil != response.Error {
+			result.Code, result.Message = aliTableStore.OTS_CLIENT_UNKNOWN, response.Error.Error()
+		}
+
+		if !result.IsSucceed {
+			response.Failed = append(response.Failed, index)
+		} else if !result.Exists {
+			response.NotFound = append(response.NotFound, index)
+		} else {
+			resultRows = append(resultRows, rows[index])
+		}
+	}
+
+	resultSlice, err := msslice.MakeSameTypeValue(list, len(resultRows), len(resultRows))
+	if err != nil {
+		response.Error = err
+		return response
+	}
+
+	for index, row := range resultRows {
+		resultSlice.Index(index).Set(reflect.ValueOf(row))
+	}
+	reflect.ValueOf(list).Elem().Set(resultSlice)
+	response.RowsAffected = resultSlice.Len()
+
+	return response
+}
+
+// 发送一个请求, 把每一行的结果写回results并填充存在的行, 请求失败的时候整个chunk都视为失败
+func (t *TableStore) batchGetChunk(ctx context.Context, rows []schema.Tabler, schemas []*schema.Schema, primaryKeys []*aliTableStore.PrimaryKey, filters map[string]aliTableStore.ColumnFilter, chunk []int, results []BatchGetRowResult, options ...func(*aliTableStore.BatchGetRowRequest)) (*aliTableStore.BatchGetRowResponse, error) {
+	request := new(aliTableStore.BatchGetRowRequest)
+	criteria := map[string]*aliTableStore.MultiRowQueryCriteria{}
+	tableIndexes := map[string][]int{}
+	for _, index := range chunk {
+		row := rows[index]
+
 		tableName := row.TableName()
 		if _, ok := criteria[tableName]; !ok {
 			criterion := new(aliTableStore.MultiRowQueryCriteria)
-			criterion.TableName = row.TableName()
+			criterion.TableName = tableName
 			criterion.MaxVersion = 1
+			if filter := filters[tableName]; nil != filter {
+				criterion.SetFilter(filter)
+			}
 			criteria[tableName] = criterion
+			request.MultiRowQueryCriteria = append(request.MultiRowQueryCriteria, criterion)
 		}
-		criteria[tableName].AddRow(tableSchema.BuildRequestPrimaryKey(row))
-	}
-
-	for _, criterion := range criteria {
-		request.MultiRowQueryCriteria = append(request.MultiRowQueryCriteria, criterion)
+		criteria[tableName].AddRow(primaryKeys[index])
+		tableIndexes[tableName] = append(tableIndexes[tableName], index)
+		results[index].Attempts++
 	}
 
 	for _, option := range options {
 		option(request)
 	}
 
-	response, err := t.BatchGetRow(request)
+	response, err := t.batchGetRow(ctx, request)
 	if err != nil {
-		return QueryAllResponse{Response: response, Error: err}
+		code, message, requestId := requestErrorResult(err)
+		for _, index := range chunk {
+			results[index].IsSucceed, results[index].Code, results[index].Message, results[index].RequestId = false, code, message, requestId
+		}
+		return response, err
 	}
 
-	resultRows := []schema.Tabler{}
-	for tableName, tableRows := range response.TableToRowsResult {
-		for _, tableRow := range tableRows {
-			if !tableRow.IsSucceed {
+	for tableName, rowResults := range response.TableToRowsResult {
+		for _, rowResult := range rowResults {
+			if int(rowResult.Index) >= len(tableIndexes[tableName]) {
 				continue
 			}
 
-			if 0 >= len(tableRow.Columns) || 0 >= len(tableRow.PrimaryKey.PrimaryKeys) {
-				continue
-			}
-
-			hitRowIndex := -1
-			for rowIndex, row := range rows {
-				if row.TableName() != tableName {
-					continue
-				}
-				tableSchema, err := t.ParseSchema(row)
-				if err != nil {
-					return QueryAllResponse{Response: response, Error: err}
+			index := tableIndexes[tableName][rowResult.Index]
+			results[index].IsSucceed = rowResult.IsSucceed
+			results[index].Code = rowResult.Error.Code
+			results[index].Message = rowResult.Error.Message
+			results[index].RequestId = response.RequestId
+			results[index].CapacityUnit = results[index].CapacityUnit.Add(rowCapacityUnit(rowResult.ConsumedCapacityUnit))
+
+			// 行不存在的时候服务端返回成功但是没有主键
+			results[index].Exists = rowResult.IsSucceed && nil != rowResult.PrimaryKey.PrimaryKeys && 0 < len(rowResult.PrimaryKey.PrimaryKeys)
+			// 类型不匹配的行视为失败
+			if results[index].Exists {
+				if err := schemas[index].FillRow(rows[index], rowResult.PrimaryKey.PrimaryKeys, rowResult.Columns); err != nil {
+					results[index].IsSucceed, results[index].Code, results[index].Message = false, aliTableStore.OTS_CLIENT_UNKNOWN, err.Error()
 				}
-				tableSchema.FillRow(row, tableRow.PrimaryKey.PrimaryKeys, tableRow.Columns)
-				resultRows = append(resultRows, row)
-				hitRowIndex = rowIndex
-				break
-			}
-
-			if 0 <= hitRowIndex && 1 == len(rows) {
-				rows = []schema.Tabler{}
-			} else if 0 <= hitRowIndex && 0 == hitRowIndex {
-				rows = rows[1:]
-			} else if 0 <= hitRowIndex && ((len(rows) - 1) == hitRowIndex) {
-				rows = rows[:len(rows)-1]
-			} else if 0 <= hitRowIndex {
-				rows = append(rows[:hitRowIndex], rows[hitRowIndex+1:]...)
 			}
 		}
 	}
 
-	resultSlice, err := msslice.MakeSameTypeValue(list, len(resultRows), len(resultRows))
-	if err != nil {
-		return QueryAllResponse{Error: err}
-	}
-
+	return response, nil
+}
 
-	for index, row := range resultRows {
-		resultSlice.Index(index).Set(reflect.ValueOf(row))
+func splitBatchGetChunks(indexes []int, maxRows int) [][]int {
+	chunks := [][]int{}
+	for 0 < len(indexes) {
+		size := len(indexes)
+		if 0 < maxRows && size > maxRows {
+			size = maxRows
+		}
+		chunks = append(chunks, indexes[:size:size])
+		indexes = indexes[size:]
 	}
-	reflect.ValueOf(list).Elem().Set(resultSlice)
 
-	return QueryAllResponse{Response: response, RowsAffected: resultSlice.Len()}
+	return chunks
 }
@@ -1,6 +1,7 @@
 package tablestore
 
 import (
+	"context"
 	"errors"
 	aliTableStore "github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
 	"github.com/hughcube-go/tablestore/schema"
@@ -11,6 +12,12 @@ type QueryOneResponse struct {
 	Error    error
 	Response *aliTableStore.GetRowResponse
 	Exists   bool
+
+	// 发起的GetRow请求数, 包含重试
+	Attempts int
+
+	// GetRow请求消耗的CU
+	CapacityUnit CapacityUnit
 }
 
 func (t *TableStore) BuildQueryOneRequest(row schema.Tabler) (*aliTableStore.GetRowRequest, error) {
@@ -28,12 +35,29 @@ func (t *TableStore) BuildQueryOneRequest(row schema.Tabler) (*aliTableStore.Get
 	request.SingleRowQueryCriteria = new(aliTableStore.SingleRowQueryCriteria)
 	request.SingleRowQueryCriteria.MaxVersion = 1
 	request.SingleRowQueryCriteria.TableName = row.TableName()
-	request.SingleRowQueryCriteria.PrimaryKey = tableSchema.BuildRequestPrimaryKey(row)
+	if request.SingleRowQueryCriteria.PrimaryKey, err = tableSchema.BuildRequestPrimaryKey(row); err != nil {
+		return nil, err
+	}
+
+	filter, err := t.buildReadFilter(tableSchema)
+	if err != nil {
+		return nil, err
+	}
+	if nil != filter {
+		request.SingleRowQueryCriteria.SetFilter(filter)
+	}
 
 	return request, nil
 }
 
 func (t *TableStore) QueryOne(row schema.Tabler, options ...func(*aliTableStore.GetRowRequest)) QueryOneResponse {
+	return t.QueryOneCtx(context.Background(), row, options...)
+}
+
+func (t *TableStore) QueryOneCtx(ctx context.Context, row schema.Tabler, options ...func(*aliTableStore.GetRowRequest)) (result QueryOneResponse) {
+	ctx, op := t.startOperation(ctx, "QueryOne")
+	defer func() { result.Attempts, result.CapacityUnit = op.end(result.Error) }()
+
 	request, err := t.BuildQueryOneRequest(row)
 	if err != nil {
 		return QueryOneResponse{Error: err}
@@ -48,12 +72,14 @@ func (t *TableStore) QueryOne(row schema.Tabler, options ...func(*aliTableStore.
 		option(request)
 	}
 
-	response, err := t.GetRow(request)
+	response, err := t.getRow(ctx, request)
 	if err != nil {
 		return QueryOneResponse{Response: response, Error: err}
 	}
 
-	tableSchema.FillRow(row, response.PrimaryKey.PrimaryKeys, response.Columns)
+	if err = tableSchema.FillRow(row, response.PrimaryKey.PrimaryKeys, response.Columns); err != nil {
+		return QueryOneResponse{Response: response, Error: err}
+	}
 
 	return QueryOneResponse{
 		Response: response,
@@ -1,6 +1,7 @@
 package tablestore
 
 import (
+	"context"
 	aliTableStore "github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
 	"github.com/hughcube-go/tablestore/schema"
 	"github.com/hughcube-go/utils/msslice"
@@ -12,10 +13,42 @@ type QueryRangeResponse struct {
 	Response            *aliTableStore.GetRangeResponse
 	NextStartPrimaryKey *aliTableStore.PrimaryKey
 	HasNext             bool
-	RowCount            int
+
+	// list中的行数, 回表的时候是主表中存在并且没有被过滤的行数, 可能少于索引表返回的行数
+	RowCount int
+
+	// 发起的GetRange请求数, 包含重试, 回表的时候还包含BatchGetRow请求
+	Attempts int
+
+	// GetRange和回表请求消耗的CU的和
+	CapacityUnit CapacityUnit
+}
+
+// WithRangeDirection 指定QueryRange的方向, 翻页时start是上一页返回的主键, 需要指定和第一页相同的方向
+func WithRangeDirection(direction aliTableStore.Direction) func(*aliTableStore.GetRangeRequest) {
+	return func(request *aliTableStore.GetRangeRequest) {
+		request.RangeRowQueryCriteria.Direction = direction
+	}
+}
+
+// 根据start和end判断方向, start可以是上一页返回的主键, 这时根据end判断
+func rangeDirection(start interface{}, end interface{}) aliTableStore.Direction {
+	_, startIsMax := start.(schema.MaxPrimaryKey)
+	_, endIsMin := end.(schema.MinPrimaryKey)
+	if startIsMax || endIsMin {
+		return aliTableStore.BACKWARD
+	}
+	return aliTableStore.FORWARD
 }
 
 func (t *TableStore) QueryRange(list interface{}, start interface{}, end interface{}, limit int, options ...func(*aliTableStore.GetRangeRequest)) QueryRangeResponse {
+	return t.QueryRangeCtx(context.Background(), list, start, end, limit, options...)
+}
+
+func (t *TableStore) QueryRangeCtx(ctx context.Context, list interface{}, start interface{}, end interface{}, limit int, options ...func(*aliTableStore.GetRangeRequest)) (result QueryRangeResponse) {
+	ctx, op := t.startOperation(ctx, "QueryRange")
+	defer func() { result.Attempts, result.CapacityUnit = op.end(result.Error) }()
+
 	listValue := reflect.ValueOf(list)
 	if listValue.Kind() != reflect.Ptr {
 		return QueryRangeResponse{Error: schema.CannotConvertTablerPointerSlice}
@@ -45,17 +78,32 @@ func (t *TableStore) QueryRange(list interface{}, start interface{}, end interfa
 		return QueryRangeResponse{Error: err}
 	}
 
-	startPrimaryKey, startIsMin, err := tableSchema.BuildRequestRangePrimaryKey(start)
+	// 使用二级索引的时候, 查询索引表, 范围使用索引的主键列
+	tableName := dest.TableName()
+	buildRangePrimaryKey := tableSchema.BuildRequestRangePrimaryKey
+	if nil != t.index {
+		index, err := tableSchema.GetIndex(dest, t.index.name)
+		if err != nil {
+			return QueryRangeResponse{Error: err}
+		}
+
+		tableName = index.Name
+		buildRangePrimaryKey = func(condition interface{}) (*aliTableStore.PrimaryKey, bool, error) {
+			return tableSchema.BuildIndexRangePrimaryKey(*index, condition)
+		}
+	}
+
+	startPrimaryKey, startIsMin, err := buildRangePrimaryKey(start)
 	if err != nil {
 		return QueryRangeResponse{Error: err}
 	}
 
-	endPrimaryKey, _, err := tableSchema.BuildRequestRangePrimaryKey(end)
+	endPrimaryKey, _, err := buildRangePrimaryKey(end)
 	if err != nil {
 		return QueryRangeResponse{Error: err}
 	}
 
-	// 根据给出的key, 判断倒序还是顺序
+	// 根据给出的key, 判断倒序还是顺序, start不是MinPrimaryKey的时候倒序, 翻页时使用WithRangeDirection指定方向
 	direction := aliTableStore.FORWARD
 	if !startIsMin {
 		direction = aliTableStore.BACKWARD
@@ -66,15 +114,27 @@ func (t *TableStore) QueryRange(list interface{}, start interface{}, end interfa
 	request.RangeRowQueryCriteria.MaxVersion = 1
 	request.RangeRowQueryCriteria.Limit = int32(limit)
 	request.RangeRowQueryCriteria.Direction = direction
-	request.RangeRowQueryCriteria.TableName = dest.TableName()
+	request.RangeRowQueryCriteria.TableName = tableName
 	request.RangeRowQueryCriteria.StartPrimaryKey = startPrimaryKey
 	request.RangeRowQueryCriteria.EndPrimaryKey = endPrimaryKey
 
+	// 回表的时候在主表上过滤, 索引表中不一定包含过滤的列, 缺少的列不会被过滤, 不回表的时候不允许过滤
+	if nil == t.index || !t.index.backFetch {
+		filter, err := t.buildReadFilter(tableSchema)
+		if err != nil {
+			return QueryRangeResponse{Error: err}
+		}
+		if nil != t.index && nil != filter {
+			return QueryRangeResponse{Error: ErrIndexFilterWithoutBackFetch}
+		}
+		request.RangeRowQueryCriteria.Filter = filter
+	}
+
 	for _, option := range options {
 		option(request)
 	}
 
-	response, err := t.GetRange(request)
+	response, err := t.getRange(ctx, request)
 	if err != nil {
 		return QueryRangeResponse{Error: err}
 	}
@@ -82,11 +142,24 @@ func (t *TableStore) QueryRange(list interface{}, start interface{}, end interfa
 	resultSlice, _ := msslice.MakeSameTypeValue(list, len(response.Rows), len(response.Rows))
 	for index, tableRow := range response.Rows {
 		row := reflect.New(rowType).Interface()
-		tableSchema.FillRow(row, tableRow.PrimaryKey.PrimaryKeys, tableRow.Columns)
+		if err = tableSchema.FillRow(row, tableRow.PrimaryKey.PrimaryKeys, tableRow.Columns); err != nil {
+			return QueryRangeResponse{Error: err, Response: response}
+		}
 		resultSlice.Index(index).Set(reflect.ValueOf(row))
 	}
 	listValue.Elem().Set(resultSlice)
 
+	// 回表失败的行会从list中移除, 这时NextStartPrimaryKey已经越过这些行, 只能返回错误
+	if nil != t.index && t.index.backFetch && 0 < resultSlice.Len() {
+		queryAllResponse := t.withoutIndex().QueryAllCtx(ctx, list)
+		if queryAllResponse.Error != nil {
+			return QueryRangeResponse{Error: queryAllResponse.Error, Response: response}
+		}
+		if 0 < len(queryAllResponse.Failed) {
+			return QueryRangeResponse{Error: queryAllResponse.Results[queryAllResponse.Failed[0]].Err(), Response: response}
+		}
+	}
+
 	return QueryRangeResponse{
 		Response:            response,
 		NextStartPrimaryKey: response.NextStartPrimaryKey,
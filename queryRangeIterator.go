@@ -0,0 +1,195 @@
+package tablestore
+
+import (
+	"context"
+	aliTableStore "github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/hughcube-go/tablestore/schema"
+	"iter"
+)
+
+type rangeIteratorConfig struct {
+	pageSize int
+	maxRows  int
+	prefetch bool
+}
+
+type RangeIteratorOption func(*rangeIteratorConfig)
+
+// WithPageSize 每次请求返回的最大行数, 默认由服务端决定
+func WithPageSize(pageSize int) RangeIteratorOption {
+	return func(config *rangeIteratorConfig) {
+		config.pageSize = pageSize
+	}
+}
+
+// WithMaxRows 最多返回的总行数, 默认不限制
+func WithMaxRows(maxRows int) RangeIteratorOption {
+	return func(config *rangeIteratorConfig) {
+		config.maxRows = maxRows
+	}
+}
+
+// WithPrefetch 在消费当前页的时候后台请求下一页
+func WithPrefetch() RangeIteratorOption {
+	return func(config *rangeIteratorConfig) {
+		config.prefetch = true
+	}
+}
+
+type rangePage[T any] struct {
+	rows         []*T
+	next         *aliTableStore.PrimaryKey
+	capacityUnit CapacityUnit
+	err          error
+}
+
+// RangeIterator 自动跟随NextStartPrimaryKey翻页, 直到范围结束或者达到WithMaxRows
+//
+//	iterator := tablestore.NewRangeIterator[Model](ctx, client, schema.MinPrimaryKey{}, schema.MaxPrimaryKey{})
+//	defer iterator.Close()
+//	for iterator.Next() {
+//		row := iterator.Row()
+//	}
+//	err := iterator.Err()
+type RangeIterator[T any, P schema.TablerPointer[T]] struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	client *TableStore
+	config rangeIteratorConfig
+
+	end       interface{}
+	start     interface{}
+	direction aliTableStore.Direction
+	fetched   int
+	done      bool
+
+	page         []*T
+	index        int
+	row          *T
+	err          error
+	prefetch     chan rangePage[T]
+	capacityUnit CapacityUnit
+}
+
+func NewRangeIterator[T any, P schema.TablerPointer[T]](ctx context.Context, client *TableStore, start interface{}, end interface{}, options ...RangeIteratorOption) *RangeIterator[T, P] {
+	// start是MaxPrimaryKey或者end是MinPrimaryKey的时候倒序, 翻页时保持不变
+	iterator := &RangeIterator[T, P]{client: client, start: start, end: end, direction: rangeDirection(start, end)}
+	iterator.ctx, iterator.cancel = context.WithCancel(ctx)
+
+	for _, option := range options {
+		option(&iterator.config)
+	}
+
+	return iterator
+}
+
+func (it *RangeIterator[T, P]) fetch(start interface{}) rangePage[T] {
+	limit := it.config.pageSize
+	if remaining := it.config.maxRows - it.fetched; 0 < it.config.maxRows && (0 >= limit || remaining < limit) {
+		limit = remaining
+	}
+
+	var rows []*T
+	response := it.client.QueryRangeCtx(it.ctx, &rows, start, it.end, limit, WithRangeDirection(it.direction))
+	if response.Error != nil {
+		return rangePage[T]{err: response.Error, capacityUnit: response.CapacityUnit}
+	}
+
+	return rangePage[T]{rows: rows, next: response.NextStartPrimaryKey, capacityUnit: response.CapacityUnit}
+}
+
+func (it *RangeIterator[T, P]) nextPage() rangePage[T] {
+	var page rangePage[T]
+	if nil != it.prefetch {
+		page = <-it.prefetch
+		it.prefetch = nil
+	} else {
+		page = it.fetch(it.start)
+	}
+
+	it.capacityUnit = it.capacityUnit.Add(page.capacityUnit)
+	if page.err != nil {
+		return page
+	}
+
+	it.fetched += len(page.rows)
+	it.start = page.next
+	it.done = nil == page.next || (0 < it.config.maxRows && it.fetched >= it.config.maxRows)
+
+	if !it.done && it.config.prefetch {
+		prefetch := make(chan rangePage[T], 1)
+		start := it.start
+		go func() {
+			prefetch <- it.fetch(start)
+		}()
+		it.prefetch = prefetch
+	}
+
+	return page
+}
+
+func (it *RangeIterator[T, P]) Next() bool {
+	if it.err != nil {
+		return false
+	}
+
+	for it.index >= len(it.page) {
+		if it.done {
+			return false
+		}
+
+		page := it.nextPage()
+		if page.err != nil {
+			it.err = page.err
+			return false
+		}
+		it.page, it.index = page.rows, 0
+	}
+
+	it.row = it.page[it.index]
+	it.index++
+	return true
+}
+
+func (it *RangeIterator[T, P]) Row() *T {
+	return it.row
+}
+
+// CapacityUnit 已经读取的页消耗的CU的和
+func (it *RangeIterator[T, P]) CapacityUnit() CapacityUnit {
+	return it.capacityUnit
+}
+
+func (it *RangeIterator[T, P]) Err() error {
+	return it.err
+}
+
+// Close 停止后台的预取请求
+func (it *RangeIterator[T, P]) Close() {
+	it.cancel()
+}
+
+// All 以iter.Seq2的方式遍历, 出错时最后一次返回错误
+func (it *RangeIterator[T, P]) All() iter.Seq2[*T, error] {
+	return func(yield func(*T, error) bool) {
+		defer it.Close()
+
+		for it.Next() {
+			if !yield(it.Row(), nil) {
+				return
+			}
+		}
+
+		if err := it.Err(); err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+func (r *Repository[T, P]) Iterator(ctx context.Context, start interface{}, end interface{}, options ...RangeIteratorOption) *RangeIterator[T, P] {
+	return NewRangeIterator[T, P](ctx, r.client, start, end, options...)
+}
+
+func (r *Repository[T, P]) All(ctx context.Context, start interface{}, end interface{}, options ...RangeIteratorOption) iter.Seq2[*T, error] {
+	return r.Iterator(ctx, start, end, options...).All()
+}
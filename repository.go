@@ -0,0 +1,98 @@
+package tablestore
+
+import (
+	"context"
+	aliTableStore "github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/hughcube-go/tablestore/schema"
+)
+
+// Repository 是某一个模型的类型安全的读写入口
+//
+//	repository := tablestore.NewRepository[Model](client)
+//	row, exists, err := repository.Get(ctx, &Model{ID: 1})
+type Repository[T any, P schema.TablerPointer[T]] struct {
+	client *TableStore
+}
+
+func NewRepository[T any, P schema.TablerPointer[T]](client *TableStore) *Repository[T, P] {
+	return &Repository[T, P]{client: client}
+}
+
+func (r *Repository[T, P]) Client() *TableStore {
+	return r.client
+}
+
+func (r *Repository[T, P]) Schema() (*schema.Schema, error) {
+	return r.client.ParseSchema(P(new(T)))
+}
+
+// Get 按照key中的主键查询, key不会被修改
+func (r *Repository[T, P]) Get(ctx context.Context, key *T) (*T, bool, error) {
+	row := new(T)
+	*row = *key
+
+	response := r.client.QueryOneCtx(ctx, P(row))
+	if response.Error != nil || !response.Exists {
+		return nil, false, response.Error
+	}
+
+	return row, true, nil
+}
+
+// Range 查询[start, end)之间的行, start和end可以是schema.MinPrimaryKey, schema.MaxPrimaryKey或者上一页返回的next,
+// start是schema.MaxPrimaryKey或者end是schema.MinPrimaryKey的时候倒序
+func (r *Repository[T, P]) Range(ctx context.Context, start interface{}, end interface{}, limit int) ([]*T, *aliTableStore.PrimaryKey, error) {
+	var rows []*T
+	response := r.client.QueryRangeCtx(ctx, &rows, start, end, limit, WithRangeDirection(rangeDirection(start, end)))
+	if response.Error != nil {
+		return nil, nil, response.Error
+	}
+
+	return rows, response.NextStartPrimaryKey, nil
+}
+
+// BatchGet 按照keys中的主键批量查询, 只返回存在的行, keys不会被修改, 有行查询失败的时候返回第一个失败的行的错误
+func (r *Repository[T, P]) BatchGet(ctx context.Context, keys []*T) ([]*T, error) {
+	rows := make([]P, 0, len(keys))
+	for _, key := range keys {
+		row := new(T)
+		*row = *key
+		rows = append(rows, P(row))
+	}
+
+	response := r.client.QueryAllCtx(ctx, &rows)
+	if response.Error != nil {
+		return nil, response.Error
+	}
+	if 0 < len(response.Failed) {
+		return nil, response.Results[response.Failed[0]].Err()
+	}
+
+	result := make([]*T, 0, len(rows))
+	for _, row := range rows {
+		result = append(result, (*T)(row))
+	}
+	return result, nil
+}
+
+// Put 写入一行, 自增主键会回填到row
+func (r *Repository[T, P]) Put(ctx context.Context, row *T) error {
+	response := r.client.InsertCtx(ctx, P(row))
+	if response.Error != nil {
+		return response.Error
+	}
+
+	tableSchema, err := r.Schema()
+	if err != nil {
+		return err
+	}
+	return tableSchema.FillRow(row, response.Response.PrimaryKey.PrimaryKeys, nil)
+}
+
+func (r *Repository[T, P]) Update(ctx context.Context, row *T, columns map[string]interface{}) error {
+	return r.client.UpdateOneCtx(ctx, P(row), columns).Error
+}
+
+func (r *Repository[T, P]) Delete(ctx context.Context, row *T) error {
+	return r.client.DeleteOneCtx(ctx, P(row)).Error
+}
@@ -0,0 +1,104 @@
+package tablestore
+
+import (
+	"context"
+	aliTableStore "github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/hughcube-go/tablestore/schema"
+	"github.com/hughcube-go/tablestore/tablestoretest"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func Test_Repository(t *testing.T) {
+	a := assert.New(t)
+
+	ctx := context.Background()
+	repository := NewRepository[Model](client_test_client())
+
+	row := &Model{}
+	a.Nil(repository.Put(ctx, row))
+	a.True(row.ID > 0)
+
+	found, exists, err := repository.Get(ctx, &Model{ID: row.ID})
+	a.Nil(err)
+	a.True(exists)
+	a.Equal(row.ID, found.ID)
+	a.True(found.CreatedAt.Valid)
+
+	_, exists, err = repository.Get(ctx, &Model{ID: row.ID + 1})
+	a.Nil(err)
+	a.False(exists)
+
+	other := &Model{}
+	a.Nil(repository.Put(ctx, other))
+
+	rows, next, err := repository.Range(ctx, schema.MinPrimaryKey{}, schema.MaxPrimaryKey{}, 10)
+	a.Nil(err)
+	a.Nil(next)
+	a.Len(rows, 2)
+
+	rows, err = repository.BatchGet(ctx, []*Model{{ID: row.ID}, {ID: other.ID}, {ID: other.ID + 1}})
+	a.Nil(err)
+	a.Len(rows, 2)
+
+	a.Nil(repository.Delete(ctx, row))
+	_, exists, err = repository.Get(ctx, &Model{ID: row.ID})
+	a.Nil(err)
+	a.False(exists)
+}
+
+func Test_Repository_BatchGet_Failed(t *testing.T) {
+	a := assert.New(t)
+
+	ctx := context.Background()
+	fake := tablestoretest.New()
+	repository := NewRepository[Model](New("", "", "", "", WithApi(fake)))
+
+	row := &Model{}
+	a.Nil(repository.Put(ctx, row))
+
+	// 失败的行不能当作不存在
+	fake.SetFault(func(operation string, tableName string, primaryKey *aliTableStore.PrimaryKey) error {
+		if "BatchGetRow" == operation && row.ID == primaryKey.PrimaryKeys[0].Value.(int64) {
+			return tablestoretest.NewError(aliTableStore.SERVER_BUSY, "busy", http.StatusServiceUnavailable)
+		}
+		return nil
+	})
+	rows, err := repository.BatchGet(ctx, []*Model{{ID: row.ID}, {ID: row.ID + 1}})
+	a.Nil(rows)
+	a.Equal(aliTableStore.SERVER_BUSY, ErrorCode(err))
+}
+
+func Test_Repository_Iterator(t *testing.T) {
+	a := assert.New(t)
+
+	ctx := context.Background()
+	repository := NewRepository[Model](client_test_client())
+	for i := 0; i < 5; i++ {
+		a.Nil(repository.Put(ctx, &Model{}))
+	}
+
+	ids := []int64{}
+	for row, err := range repository.All(ctx, schema.MinPrimaryKey{}, schema.MaxPrimaryKey{}, WithPageSize(2), WithPrefetch()) {
+		a.Nil(err)
+		ids = append(ids, row.ID)
+	}
+	a.Equal([]int64{1, 2, 3, 4, 5}, ids)
+
+	iterator := repository.Iterator(ctx, schema.MaxPrimaryKey{}, schema.MinPrimaryKey{}, WithPageSize(2), WithMaxRows(3))
+	defer iterator.Close()
+
+	ids = []int64{}
+	for iterator.Next() {
+		ids = append(ids, iterator.Row().ID)
+	}
+	a.Nil(iterator.Err())
+	a.Equal([]int64{5, 4, 3}, ids)
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	for _, err := range repository.All(canceled, schema.MinPrimaryKey{}, schema.MaxPrimaryKey{}) {
+		a.Equal(context.Canceled, err)
+	}
+}
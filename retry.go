@@ -0,0 +1,228 @@
+package tablestore
+
+import (
+	"context"
+	"errors"
+	aliTableStore "github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RetryPolicy 请求失败之后的重试设置, 作用于TableStore发起的每一个请求,
+// 批量操作中请求成功但是部分行失败的时候, 这些行也按照Codes和RetryNonIdempotent判断是否重试, 次数和等待时间使用BatchConfig,
+// ctx取消或者超时的时候不重试, 已经发出的写入不会被取消, 仍然可能在服务端生效
+type RetryPolicy struct {
+	// 最大尝试次数, 包含第一次, 小于等于1的时候不重试
+	MaxAttempts int
+
+	// 重试的初始等待时间, 每次重试翻倍并加上随机抖动, 不超过MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// 从第一次请求开始的最长时间, 超过之后不再重试, 0表示不限制
+	MaxElapsedTime time.Duration
+
+	// 按照错误码指定是否重试, 优先于默认的规则
+	Codes map[string]bool
+
+	// 非幂等的请求默认只在服务端确定没有执行的时候重试, 比如限流, 为true的时候和幂等的请求一样重试
+	RetryNonIdempotent bool
+}
+
+// DefaultRetryPolicy 推荐的重试设置, 客户端默认不重试
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		Backoff:        50 * time.Millisecond,
+		MaxBackoff:     2 * time.Second,
+		MaxElapsedTime: 10 * time.Second,
+	}
+}
+
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(t *TableStore) {
+		t.retryPolicy = policy
+	}
+}
+
+// 服务端没有执行请求的错误码, 非幂等的请求也可以重试
+var notExecutedErrorCodes = map[string]bool{
+	aliTableStore.ROW_OPERATION_CONFLICT:   true,
+	aliTableStore.NOT_ENOUGH_CAPACITY_UNIT: true,
+	aliTableStore.TABLE_NOT_READY:          true,
+	aliTableStore.PARTITION_UNAVAILABLE:    true,
+	aliTableStore.SERVER_BUSY:              true,
+	aliTableStore.STORAGE_SERVER_BUSY:      true,
+	aliTableStore.QUOTA_EXHAUSTED:          true,
+	CapacityUnitExhausted:                  true,
+}
+
+// 判断失败的请求是否需要重试, ctx结束的时候不重试, 这时invoke返回的ctx.Err()不代表请求没有执行, 重试可能重复写入
+func (p RetryPolicy) shouldRetry(ctx context.Context, err error, idempotent bool) bool {
+	if nil != ctx.Err() {
+		return false
+	}
+
+	var tableStoreError *Error
+	if !errors.As(err, &tableStoreError) {
+		// 网络错误等不能确定服务端是否已经执行
+		return idempotent || p.RetryNonIdempotent
+	}
+
+	return p.shouldRetryCode(tableStoreError.Code, idempotent)
+}
+
+// 按照错误码判断是否重试, 批量操作中失败的行使用相同的规则
+func (p RetryPolicy) shouldRetryCode(code string, idempotent bool) bool {
+	if retry, ok := p.Codes[code]; ok {
+		return retry
+	}
+
+	if !idempotent && !p.RetryNonIdempotent {
+		return notExecutedErrorCodes[code]
+	}
+	return isRetryableErrorCode(code)
+}
+
+// 发起请求, 失败的时候按照RetryPolicy重试
+func retryInvoke[T any](ctx context.Context, t *TableStore, idempotent bool, call func() (T, error)) (T, error) {
+	policy := t.retryPolicy
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		countAttempt(ctx)
+		response, err := invoke(ctx, call)
+		if err == nil || attempt >= policy.MaxAttempts || !policy.shouldRetry(ctx, err, idempotent) {
+			return response, err
+		}
+
+		backoff := backoffDuration(policy.Backoff, policy.MaxBackoff, attempt)
+		if 0 < policy.MaxElapsedTime && time.Since(start)+backoff > policy.MaxElapsedTime {
+			return response, err
+		}
+		if sleepErr := sleepContext(ctx, backoff); sleepErr != nil {
+			return response, err
+		}
+	}
+}
+
+// 统计一次调用中发起的请求数和消耗的CU, 嵌套调用的时候同时累加到外层
+type requestStats struct {
+	count  int64
+	read   int64
+	write  int64
+	parent *requestStats
+
+	// 请求涉及的表和读写的行数, 用于调用的span
+	mu     sync.Mutex
+	tables map[string]bool
+	usage  tableUsage
+}
+
+type requestStatsKey struct{}
+
+func withRequestStats(ctx context.Context) (context.Context, *requestStats) {
+	parent, _ := ctx.Value(requestStatsKey{}).(*requestStats)
+	stats := &requestStats{parent: parent}
+	return context.WithValue(ctx, requestStatsKey{}, stats), stats
+}
+
+func countAttempt(ctx context.Context) {
+	stats, _ := ctx.Value(requestStatsKey{}).(*requestStats)
+	for ; nil != stats; stats = stats.parent {
+		atomic.AddInt64(&stats.count, 1)
+	}
+}
+
+func addCapacityUnit(ctx context.Context, capacityUnit CapacityUnit) {
+	stats, _ := ctx.Value(requestStatsKey{}).(*requestStats)
+	for ; nil != stats; stats = stats.parent {
+		atomic.AddInt64(&stats.read, capacityUnit.Read)
+		atomic.AddInt64(&stats.write, capacityUnit.Write)
+	}
+}
+
+func addCallUsage(ctx context.Context, call *Call, usages map[string]*tableUsage) {
+	stats, _ := ctx.Value(requestStatsKey{}).(*requestStats)
+	for ; nil != stats; stats = stats.parent {
+		stats.mu.Lock()
+		if nil == stats.tables {
+			stats.tables = map[string]bool{}
+		}
+		for _, tableName := range call.TableNames {
+			stats.tables[tableName] = true
+		}
+		for _, usage := range usages {
+			stats.usage.add(usage)
+		}
+		stats.mu.Unlock()
+	}
+}
+
+func (s *requestStats) attempts() int {
+	return int(atomic.LoadInt64(&s.count))
+}
+
+func (s *requestStats) capacityUnit() CapacityUnit {
+	return CapacityUnit{Read: atomic.LoadInt64(&s.read), Write: atomic.LoadInt64(&s.write)}
+}
+
+// 写入条件只有IGNORE的时候重复执行的结果相同
+func isIdempotentCondition(condition *aliTableStore.RowCondition) bool {
+	return nil == condition || (aliTableStore.RowExistenceExpectation_IGNORE == condition.RowExistenceExpectation && nil == condition.ColumnCondition)
+}
+
+func isIdempotentPutRow(change *aliTableStore.PutRowChange) bool {
+	if nil != change.PrimaryKey {
+		for _, primaryKey := range change.PrimaryKey.PrimaryKeys {
+			if aliTableStore.AUTO_INCREMENT == primaryKey.PrimaryKeyOption {
+				return false
+			}
+		}
+	}
+	return isIdempotentCondition(change.Condition)
+}
+
+func isIdempotentPutRowRequest(request *aliTableStore.PutRowRequest) bool {
+	return isIdempotentPutRow(request.PutRowChange)
+}
+
+func isIdempotentUpdateRowRequest(request *aliTableStore.UpdateRowRequest) bool {
+	return isIdempotentUpdateRow(request.UpdateRowChange)
+}
+
+func isIdempotentDeleteRowRequest(request *aliTableStore.DeleteRowRequest) bool {
+	return isIdempotentCondition(request.DeleteRowChange.Condition)
+}
+
+func isIdempotentUpdateRow(change *aliTableStore.UpdateRowChange) bool {
+	for _, column := range change.Columns {
+		if aliTableStore.INCREMENT == column.Type {
+			return false
+		}
+	}
+	return isIdempotentCondition(change.Condition)
+}
+
+func isIdempotentBatchWrite(request *aliTableStore.BatchWriteRowRequest) bool {
+	for _, changes := range request.RowChangesGroupByTable {
+		for _, change := range changes {
+			if !isIdempotentRowChange(change) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func isIdempotentRowChange(change aliTableStore.RowChange) bool {
+	switch change := change.(type) {
+	case *aliTableStore.PutRowChange:
+		return isIdempotentPutRow(change)
+	case *aliTableStore.UpdateRowChange:
+		return isIdempotentUpdateRow(change)
+	case *aliTableStore.DeleteRowChange:
+		return isIdempotentCondition(change.Condition)
+	}
+	return false
+}
@@ -0,0 +1,251 @@
+package tablestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	aliTableStore "github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/hughcube-go/tablestore/schema"
+)
+
+var ErrUpsertAutoIncrement = errors.New("upsert does not support auto increment primary key")
+
+type SaveResponse struct {
+	Error          error
+	Response       *aliTableStore.PutRowResponse
+	UpdateResponse *aliTableStore.UpdateRowResponse
+	LastId         int64
+
+	// InsertOrIgnore时行已经存在为0
+	RowsAffected int
+
+	// 发起的PutRow请求数, Upsert的时候是UpdateRow请求数, 包含重试
+	Attempts int
+
+	// 写入请求消耗的CU
+	CapacityUnit CapacityUnit
+}
+
+type saveConfig struct {
+	expectation    aliTableStore.RowExistenceExpectation
+	filters        []*schema.Filter
+	ignoreExisting bool
+}
+
+type SaveOption func(*saveConfig)
+
+// WithRowExistence 写入时对行是否存在的期望, Save默认是IGNORE
+func WithRowExistence(expectation aliTableStore.RowExistenceExpectation) SaveOption {
+	return func(config *saveConfig) {
+		config.expectation = expectation
+	}
+}
+
+// WithSaveCondition 追加写入条件, 和Where的条件同时生效
+func WithSaveCondition(filters ...*schema.Filter) SaveOption {
+	return func(config *saveConfig) {
+		config.filters = append(config.filters, filters...)
+	}
+}
+
+// Save 使用PutRow写入整个结构体, 行存在的时候覆盖, 存在版本字段的时候要求版本没有被修改, 并且版本加1
+func (t *TableStore) Save(row schema.Tabler, options ...SaveOption) SaveResponse {
+	return t.SaveCtx(context.Background(), row, options...)
+}
+
+func (t *TableStore) SaveCtx(ctx context.Context, row schema.Tabler, options ...SaveOption) SaveResponse {
+	config := &saveConfig{expectation: aliTableStore.RowExistenceExpectation_IGNORE}
+	for _, option := range options {
+		option(config)
+	}
+	return t.save(ctx, "Save", row, config)
+}
+
+// InsertOrIgnore 和Insert相同, 行已经存在的时候不写入, 也不返回错误,
+// 使用了WithSaveCondition, Where或者自增主键的时候无法区分条件失败的原因, 返回原来的错误
+func (t *TableStore) InsertOrIgnore(row schema.Tabler, options ...SaveOption) SaveResponse {
+	return t.InsertOrIgnoreCtx(context.Background(), row, options...)
+}
+
+func (t *TableStore) InsertOrIgnoreCtx(ctx context.Context, row schema.Tabler, options ...SaveOption) SaveResponse {
+	config := &saveConfig{}
+	for _, option := range options {
+		option(config)
+	}
+	config.expectation = aliTableStore.RowExistenceExpectation_EXPECT_NOT_EXIST
+	config.ignoreExisting = true
+	return t.save(ctx, "InsertOrIgnore", row, config)
+}
+
+func (t *TableStore) save(ctx context.Context, operationName string, row schema.Tabler, config *saveConfig) (result SaveResponse) {
+	ctx, op := t.startOperation(ctx, operationName)
+	defer func() { result.Attempts, result.CapacityUnit = op.end(result.Error) }()
+
+	tableSchema, err := t.ParseSchema(row)
+	if err != nil {
+		return SaveResponse{Error: err}
+	}
+
+	if config.ignoreExisting {
+		tableSchema.SetAutoTime(row, t.Now(), true)
+	} else {
+		tableSchema.SetAutoTimeOnSave(row, t.Now())
+	}
+
+	// 可能覆盖已经存在的行的时候检查版本, 版本为0的时候允许写入新行
+	filters := config.filters
+	version, checkVersion := tableSchema.GetVersion(row)
+	checkVersion = checkVersion && aliTableStore.RowExistenceExpectation_EXPECT_NOT_EXIST != config.expectation
+	if checkVersion {
+		versionFilter := tableSchema.BuildVersionFilter(row)
+		if 0 == version {
+			versionFilter = versionFilter.PassIfMissing()
+		}
+		filters = append(filters, versionFilter)
+		tableSchema.SetVersion(row, version+1)
+	}
+
+	change, err := tableSchema.BuildRequestPutRowChange(row)
+	if err != nil {
+		if checkVersion {
+			tableSchema.SetVersion(row, version)
+		}
+		return SaveResponse{Error: err}
+	}
+
+	// 自增主键总是写入新行
+	expectation := config.expectation
+	if nil != tableSchema.GetAutoIncrField() {
+		expectation = change.Condition.RowExistenceExpectation
+	}
+	if change.Condition, err = t.buildWriteCondition(tableSchema, expectation, filters...); err != nil {
+		if checkVersion {
+			tableSchema.SetVersion(row, version)
+		}
+		return SaveResponse{Error: err}
+	}
+
+	response, err := t.putRow(ctx, &aliTableStore.PutRowRequest{PutRowChange: change})
+	if err != nil {
+		if checkVersion {
+			tableSchema.SetVersion(row, version)
+		}
+
+		if IsConditionFailed(err) {
+			// 只有行存在的条件的时候才能确定是行已经存在
+			if config.ignoreExisting && nil == t.where && 0 == len(config.filters) && aliTableStore.RowExistenceExpectation_EXPECT_NOT_EXIST == expectation {
+				return SaveResponse{Response: response}
+			}
+			if checkVersion {
+				onlyVersion := nil == t.where && 0 == len(config.filters) && aliTableStore.RowExistenceExpectation_IGNORE == expectation
+				return SaveResponse{Error: staleObjectError(err, onlyVersion), Response: response}
+			}
+		}
+		return SaveResponse{Error: err, Response: response}
+	}
+
+	return SaveResponse{Response: response, LastId: lastInsertId(tableSchema, response), RowsAffected: 1}
+}
+
+// Upsert 使用UpdateRow写入结构体中的列, 行不存在的时候新建, 不会删除结构体中没有的列,
+// onlyColumns不为空的时候只写入这些列和autoUpdateTime字段, nullable字段的null值删除对应的列,
+// autoCreateTime字段为零值的时候不写入, 避免覆盖已经存在的行的创建时间, 所以Upsert新建的行没有创建时间,
+// 需要创建时间的时候由调用方设置autoCreateTime字段, 或者先使用InsertOrIgnore写入
+func (t *TableStore) Upsert(row schema.Tabler, onlyColumns ...string) SaveResponse {
+	return t.UpsertCtx(context.Background(), row, onlyColumns...)
+}
+
+func (t *TableStore) UpsertCtx(ctx context.Context, row schema.Tabler, onlyColumns ...string) (result SaveResponse) {
+	ctx, op := t.startOperation(ctx, "Upsert")
+	defer func() { result.Attempts, result.CapacityUnit = op.end(result.Error) }()
+
+	tableSchema, err := t.ParseSchema(row)
+	if err != nil {
+		return SaveResponse{Error: err}
+	}
+
+	if nil != tableSchema.GetAutoIncrField() {
+		return SaveResponse{Error: ErrUpsertAutoIncrement}
+	}
+
+	tableSchema.SetAutoTime(row, t.Now(), false)
+	change, err := tableSchema.BuildRequestPutRowChange(row)
+	if err != nil {
+		return SaveResponse{Error: err}
+	}
+
+	only := map[string]bool{}
+	for _, name := range onlyColumns {
+		field, ok := tableSchema.FieldMap[name]
+		if !ok {
+			field, ok = tableSchema.ColumnFieldMap[name]
+		}
+		if !ok {
+			return SaveResponse{Error: fmt.Errorf("field %s not found in %s", name, tableSchema.Name)}
+		}
+		only[field.DBName] = true
+	}
+
+	versionField := tableSchema.GetVersionField()
+	rowChange := new(aliTableStore.UpdateRowChange)
+	written := map[string]bool{}
+	for _, column := range change.Columns {
+		written[column.ColumnName] = true
+		field := tableSchema.ColumnFieldMap[column.ColumnName]
+		if 0 < len(only) && !only[column.ColumnName] && (nil == field || !field.AutoUpdateTime) {
+			continue
+		}
+		if field == versionField {
+			continue
+		}
+		if nil != field && field.AutoCreateTime && field.ZeroOtsValue() == column.Value {
+			continue
+		}
+		rowChange.PutColumn(column.ColumnName, column.Value)
+	}
+
+	// nullable字段的null值没有写入PutRowChange, 需要删除对应的列
+	for _, field := range tableSchema.Fields {
+		if field.IsNullable && !field.IsPrimaryKey && field != versionField && !written[field.DBName] && (0 == len(only) || only[field.DBName]) {
+			rowChange.DeleteColumn(field.DBName)
+		}
+	}
+
+	// 存在版本字段的时候要求版本没有被修改, 版本为0的时候允许写入新行, 并且版本加1
+	var versionFilter *schema.Filter
+	if nil != versionField {
+		versionFilter = tableSchema.BuildVersionFilter(row)
+		if version, _ := tableSchema.GetVersion(row); 0 == version {
+			versionFilter = versionFilter.PassIfMissing()
+		}
+		rowChange.IncrementColumn(versionField.DBName, 1)
+		rowChange.SetReturnIncrementValue()
+		rowChange.AppendIncrementColumnToReturn(versionField.DBName)
+	}
+
+	condition, err := t.buildWriteCondition(tableSchema, aliTableStore.RowExistenceExpectation_IGNORE, versionFilter)
+	if err != nil {
+		return SaveResponse{Error: err}
+	}
+
+	rowChange.TableName = row.TableName()
+	rowChange.PrimaryKey = change.PrimaryKey
+	rowChange.Condition = condition
+
+	if 0 == len(rowChange.Columns) {
+		return SaveResponse{Error: fmt.Errorf("upsert %s has no column to write", tableSchema.Name)}
+	}
+
+	response, err := t.updateRow(ctx, &aliTableStore.UpdateRowRequest{UpdateRowChange: rowChange})
+	if nil != versionFilter && err != nil {
+		return SaveResponse{Error: staleObjectError(err, nil == t.where), UpdateResponse: response}
+	} else if err != nil {
+		return SaveResponse{Error: err, UpdateResponse: response}
+	}
+
+	if err = tableSchema.FillRow(row, ([]*aliTableStore.PrimaryKeyColumn{}), response.Columns); err != nil {
+		return SaveResponse{Error: err, UpdateResponse: response, RowsAffected: 1}
+	}
+
+	return SaveResponse{UpdateResponse: response, RowsAffected: 1}
+}
@@ -0,0 +1,237 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore/search"
+)
+
+// Aggregator 多元索引的统计聚合或者分组, 返回的search.Aggregation和search.GroupBy只有一个不为nil
+//
+//	schema.Avg("avg_age", "Age"), schema.GroupByField("by_status", "Status").SubAggregations(schema.Count("count", "Pk"))
+type Aggregator interface {
+	BuildAggregator(s *Schema) (search.Aggregation, search.GroupBy, error)
+}
+
+// MetricAggregation 统计聚合, 结果按照name获取
+type MetricAggregation struct {
+	aggregationType search.AggregationType
+	name            string
+	field           string
+	missing         interface{}
+}
+
+func newMetricAggregation(aggregationType search.AggregationType, name string, field string) *MetricAggregation {
+	return &MetricAggregation{aggregationType: aggregationType, name: name, field: field}
+}
+
+// Count 字段存在的行数
+func Count(name string, field string) *MetricAggregation {
+	return newMetricAggregation(search.AggregationCountType, name, field)
+}
+
+func Sum(name string, field string) *MetricAggregation {
+	return newMetricAggregation(search.AggregationSumType, name, field)
+}
+
+func Avg(name string, field string) *MetricAggregation {
+	return newMetricAggregation(search.AggregationAvgType, name, field)
+}
+
+func Min(name string, field string) *MetricAggregation {
+	return newMetricAggregation(search.AggregationMinType, name, field)
+}
+
+func Max(name string, field string) *MetricAggregation {
+	return newMetricAggregation(search.AggregationMaxType, name, field)
+}
+
+// DistinctCount 字段不同取值的个数, 结果是近似值
+func DistinctCount(name string, field string) *MetricAggregation {
+	return newMetricAggregation(search.AggregationDistinctCountType, name, field)
+}
+
+// Missing 字段不存在的行使用的默认值, Count不支持
+func (a *MetricAggregation) Missing(value interface{}) *MetricAggregation {
+	a.missing = value
+	return a
+}
+
+func (a *MetricAggregation) BuildAggregator(s *Schema) (search.Aggregation, search.GroupBy, error) {
+	column, field, err := s.resolveSearchField(a.field)
+	if err != nil {
+		return nil, nil, err
+	}
+	missing, err := s.resolveSearchValue(field, a.missing)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch a.aggregationType {
+	case search.AggregationCountType:
+		if nil != a.missing {
+			return nil, nil, fmt.Errorf("count aggregation %s does not support missing value", a.name)
+		}
+		return search.NewCountAggregation(a.name, column), nil, nil
+	case search.AggregationSumType:
+		return &search.SumAggregation{AggName: a.name, Field: column, MissingValue: missing}, nil, nil
+	case search.AggregationAvgType:
+		return &search.AvgAggregation{AggName: a.name, Field: column, MissingValue: missing}, nil, nil
+	case search.AggregationMinType:
+		return &search.MinAggregation{AggName: a.name, Field: column, MissingValue: missing}, nil, nil
+	case search.AggregationMaxType:
+		return &search.MaxAggregation{AggName: a.name, Field: column, MissingValue: missing}, nil, nil
+	case search.AggregationDistinctCountType:
+		return &search.DistinctCountAggregation{AggName: a.name, Field: column, MissingValue: missing}, nil, nil
+	}
+
+	return nil, nil, fmt.Errorf("aggregation %s has unknown type", a.name)
+}
+
+// GroupBy 分组, 每个分组中可以再做统计聚合和分组
+type GroupBy struct {
+	groupByType search.GroupByType
+	name        string
+	field       string
+	size        *int32
+	sorters     []search.GroupBySorter
+	ranges      [][2]float64
+	queries     []Query
+	subs        []Aggregator
+}
+
+// GroupByField 按照字段的取值分组
+func GroupByField(name string, field string) *GroupBy {
+	return &GroupBy{groupByType: search.GroupByFieldType, name: name, field: field}
+}
+
+// GroupByRange 按照字段的取值范围分组, 使用Range添加范围
+func GroupByRange(name string, field string) *GroupBy {
+	return &GroupBy{groupByType: search.GroupByRangeType, name: name, field: field}
+}
+
+// GroupByFilter 每个查询条件一个分组, 分组的顺序和查询条件的顺序相同
+func GroupByFilter(name string, queries ...Query) *GroupBy {
+	return &GroupBy{groupByType: search.GroupByFilterType, name: name, queries: queries}
+}
+
+// Size 返回的分组个数, 只对GroupByField有效
+func (g *GroupBy) Size(size int32) *GroupBy {
+	g.size = &size
+	return g
+}
+
+// Range 添加一个[from, to)的范围, 只对GroupByRange有效
+func (g *GroupBy) Range(from float64, to float64) *GroupBy {
+	g.ranges = append(g.ranges, [2]float64{from, to})
+	return g
+}
+
+// SortByKey 按照分组的值排序, 只对GroupByField有效
+func (g *GroupBy) SortByKey(desc bool) *GroupBy {
+	g.sorters = append(g.sorters, &search.GroupKeyGroupBySort{Order: sortOrder(desc)})
+	return g
+}
+
+// SortByRowCount 按照分组的行数排序, 只对GroupByField有效
+func (g *GroupBy) SortByRowCount(desc bool) *GroupBy {
+	g.sorters = append(g.sorters, &search.RowCountGroupBySort{Order: sortOrder(desc)})
+	return g
+}
+
+// SortBySubAggregation 按照分组中统计聚合的结果排序, 只对GroupByField有效
+func (g *GroupBy) SortBySubAggregation(name string, desc bool) *GroupBy {
+	g.sorters = append(g.sorters, &search.SubAggGroupBySort{Order: sortOrder(desc), SubAggName: name})
+	return g
+}
+
+// SubAggregations 每个分组中的统计聚合或者分组
+func (g *GroupBy) SubAggregations(aggregators ...Aggregator) *GroupBy {
+	g.subs = append(g.subs, aggregators...)
+	return g
+}
+
+func (g *GroupBy) BuildAggregator(s *Schema) (search.Aggregation, search.GroupBy, error) {
+	subAggregations, subGroupBys, err := s.BuildAggregators(g.subs...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if search.GroupByFilterType == g.groupByType {
+		if 0 == len(g.queries) {
+			return nil, nil, fmt.Errorf("group by %s has no filter", g.name)
+		}
+
+		queries, err := s.buildQueries(g.queries)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, &search.GroupByFilter{AggName: g.name, Queries: queries, SubAggList: subAggregations, SubGroupByList: subGroupBys}, nil
+	}
+
+	column, _, err := s.resolveSearchField(g.field)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch g.groupByType {
+	case search.GroupByFieldType:
+		groupBy := &search.GroupByField{AggName: g.name, Field: column, Sz: g.size, Sorters: g.sorters}
+		groupBy.SubAggList, groupBy.SubGroupByList = subAggregations, subGroupBys
+		return nil, groupBy, nil
+	case search.GroupByRangeType:
+		if 0 == len(g.ranges) {
+			return nil, nil, fmt.Errorf("group by %s has no range", g.name)
+		}
+
+		groupBy := search.NewGroupByRange(g.name, column)
+		for _, item := range g.ranges {
+			groupBy.Range(item[0], item[1])
+		}
+		groupBy.SubAggList, groupBy.SubGroupByList = subAggregations, subGroupBys
+		return nil, groupBy, nil
+	}
+
+	return nil, nil, fmt.Errorf("group by %s has unknown type", g.name)
+}
+
+func sortOrder(desc bool) *search.SortOrder {
+	if desc {
+		return search.SortOrder_DESC.Enum()
+	}
+	return search.SortOrder_ASC.Enum()
+}
+
+// BuildAggregators 把统计聚合和分组分开, 名称不能重复
+func (s *Schema) BuildAggregators(aggregators ...Aggregator) ([]search.Aggregation, []search.GroupBy, error) {
+	var aggregations []search.Aggregation
+	var groupBys []search.GroupBy
+
+	names := map[string]bool{}
+	for _, aggregator := range aggregators {
+		if nil == aggregator {
+			return nil, nil, errors.New("aggregator can not be nil")
+		}
+
+		aggregation, groupBy, err := aggregator.BuildAggregator(s)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var name string
+		if nil != aggregation {
+			name = aggregation.GetName()
+			aggregations = append(aggregations, aggregation)
+		} else if nil != groupBy {
+			name = groupBy.GetName()
+			groupBys = append(groupBys, groupBy)
+		}
+
+		if names[name] {
+			return nil, nil, fmt.Errorf("aggregator %s is duplicated", name)
+		}
+		names[name] = true
+	}
+
+	return aggregations, groupBys, nil
+}
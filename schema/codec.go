@@ -0,0 +1,181 @@
+package schema
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// Codec 自定义类型和表格存储中的值的转换, 比如枚举, 小数, UUID和嵌套的结构体
+type Codec interface {
+	// Encode 把字段的值转换成表格存储支持的类型, value是字段基本类型的值, 返回nil的时候写入零值
+	Encode(value interface{}) (interface{}, error)
+
+	// Decode 把表格存储中的值写入dest, dest是字段基本类型的指针
+	Decode(value interface{}, dest interface{}) error
+}
+
+// CodecKind Codec可以实现这个接口声明表格存储中的类型, 用于建表和软删除的零值, 默认是字符串
+type CodecKind interface {
+	OtsKind() reflect.Kind
+}
+
+var (
+	// TextCodec 使用encoding.TextMarshaler和encoding.TextUnmarshaler, 保存为字符串
+	TextCodec Codec = textCodec{}
+
+	// JSONCodec 使用json编码, 保存为字符串, 可以用于嵌套的结构体
+	JSONCodec Codec = jsonCodec{}
+
+	// SQLCodec 使用driver.Valuer和sql.Scanner
+	SQLCodec Codec = sqlCodec{}
+)
+
+// CodecRegistry 按照类型和名称注册的Codec, 字段使用codec:name标签指定名称, 默认注册了json, text和sql
+type CodecRegistry struct {
+	types map[reflect.Type]Codec
+	names map[string]Codec
+}
+
+func NewCodecRegistry() *CodecRegistry {
+	return &CodecRegistry{
+		types: map[reflect.Type]Codec{},
+		names: map[string]Codec{"json": JSONCodec, "text": TextCodec, "sql": SQLCodec},
+	}
+}
+
+var defaultCodecRegistry = NewCodecRegistry()
+
+// Register sample的类型的字段都使用codec, 指针类型使用指向的类型
+func (r *CodecRegistry) Register(sample interface{}, codec Codec) {
+	typ := reflect.TypeOf(sample)
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	r.types[typ] = codec
+}
+
+// RegisterName 注册字段标签中使用的名称
+func (r *CodecRegistry) RegisterName(name string, codec Codec) {
+	r.names[name] = codec
+}
+
+// 字段使用的Codec, 优先使用标签指定的名称和注册的类型, 然后按照实现的接口自动选择,
+// 基本类型是整形, 字符串等的自定义类型实现了接口的时候也使用Codec, 比如实现了MarshalText的type Status int,
+// 时间和sql.Null*按照内置的规则转换, 不会自动使用Codec
+func (r *CodecRegistry) lookup(f *Field) (Codec, error) {
+	if "" != f.CodecName {
+		codec, ok := r.names[f.CodecName]
+		if !ok {
+			return nil, fmt.Errorf("codec %s of field %s is not registered", f.CodecName, f.Name)
+		}
+		return codec, nil
+	}
+
+	if codec, ok := r.types[f.BaseType]; ok {
+		return codec, nil
+	}
+
+	if isBuiltinType(f.BaseType) {
+		return nil, nil
+	}
+
+	ptrType := reflect.PtrTo(f.BaseType)
+	switch {
+	case f.BaseType.Implements(textMarshalerType) && ptrType.Implements(textUnmarshalerType):
+		return TextCodec, nil
+	case f.BaseType.Implements(jsonMarshalerType):
+		return JSONCodec, nil
+	case f.BaseType.Implements(valuerType) && ptrType.Implements(scannerType):
+		return SQLCodec, nil
+	}
+	return nil, nil
+}
+
+var (
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	jsonMarshalerType   = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	valuerType          = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+	scannerType         = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+)
+
+// 表格存储中的字符串和二进制都可以作为文本解码
+func codecBytes(value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case string:
+		return []byte(v), nil
+	case []byte:
+		return v, nil
+	}
+	return nil, fmt.Errorf("can not decode %T as text", value)
+}
+
+type textCodec struct{}
+
+func (textCodec) Encode(value interface{}) (interface{}, error) {
+	marshaler, ok := value.(encoding.TextMarshaler)
+	if !ok {
+		return nil, fmt.Errorf("%T does not implement encoding.TextMarshaler", value)
+	}
+	text, err := marshaler.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+	return string(text), nil
+}
+
+func (textCodec) Decode(value interface{}, dest interface{}) error {
+	unmarshaler, ok := dest.(encoding.TextUnmarshaler)
+	if !ok {
+		return fmt.Errorf("%T does not implement encoding.TextUnmarshaler", dest)
+	}
+	text, err := codecBytes(value)
+	if err != nil {
+		return err
+	}
+	return unmarshaler.UnmarshalText(text)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Encode(value interface{}) (interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (jsonCodec) Decode(value interface{}, dest interface{}) error {
+	data, err := codecBytes(value)
+	if err != nil {
+		return err
+	}
+	// 空字符串是零值
+	if 0 == len(data) {
+		return nil
+	}
+	return json.Unmarshal(data, dest)
+}
+
+type sqlCodec struct{}
+
+func (sqlCodec) Encode(value interface{}) (interface{}, error) {
+	valuer, ok := value.(driver.Valuer)
+	if !ok {
+		return nil, fmt.Errorf("%T does not implement driver.Valuer", value)
+	}
+	return valuer.Value()
+}
+
+func (sqlCodec) Decode(value interface{}, dest interface{}) error {
+	scanner, ok := dest.(sql.Scanner)
+	if !ok {
+		return fmt.Errorf("%T does not implement sql.Scanner", dest)
+	}
+	return scanner.Scan(value)
+}
@@ -0,0 +1,184 @@
+package schema_test
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/hughcube-go/tablestore/schema"
+	"github.com/stretchr/testify/assert"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type CodecTestVersion struct {
+	Major int
+	Minor int
+}
+
+func (v CodecTestVersion) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%d.%d", v.Major, v.Minor)), nil
+}
+
+func (v *CodecTestVersion) UnmarshalText(text []byte) error {
+	_, err := fmt.Sscanf(string(text), "%d.%d", &v.Major, &v.Minor)
+	return err
+}
+
+// 基本类型是整形的枚举, 保存为名称
+type CodecTestStatus int
+
+func (s CodecTestStatus) MarshalText() ([]byte, error) {
+	return []byte([]string{"inactive", "active"}[s]), nil
+}
+
+func (s *CodecTestStatus) UnmarshalText(text []byte) error {
+	*s = map[string]CodecTestStatus{"inactive": 0, "active": 1}[string(text)]
+	return nil
+}
+
+type CodecTestTags []string
+
+func (t CodecTestTags) Value() (driver.Value, error) {
+	return strings.Join(t, ","), nil
+}
+
+func (t *CodecTestTags) Scan(src interface{}) error {
+	value, ok := src.(string)
+	if !ok {
+		return errors.New("tags must be a string")
+	}
+	*t = strings.Split(value, ",")
+	return nil
+}
+
+type CodecTestAddress struct {
+	City   string `json:"city"`
+	Street string `json:"street"`
+}
+
+type CodecTestCents struct {
+	Value int64
+}
+
+// 金额保存为整形的分
+type codecTestCentsCodec struct{}
+
+func (codecTestCentsCodec) Encode(value interface{}) (interface{}, error) {
+	return value.(CodecTestCents).Value, nil
+}
+
+func (codecTestCentsCodec) Decode(value interface{}, dest interface{}) error {
+	cents, ok := value.(int64)
+	if !ok {
+		return errors.New("cents must be an integer")
+	}
+	dest.(*CodecTestCents).Value = cents
+	return nil
+}
+
+func (codecTestCentsCodec) OtsKind() reflect.Kind {
+	return reflect.Int64
+}
+
+// 使用十六进制字符串保存整形
+type codecTestHexCodec struct{}
+
+func (codecTestHexCodec) Encode(value interface{}) (interface{}, error) {
+	return strconv.FormatInt(value.(int64), 16), nil
+}
+
+func (codecTestHexCodec) Decode(value interface{}, dest interface{}) error {
+	i, err := strconv.ParseInt(value.(string), 16, 64)
+	*dest.(*int64) = i
+	return err
+}
+
+type CodecTestModel struct {
+	ID      int64             `tableStore:"primaryKey;column:id;"`
+	Version CodecTestVersion  `tableStore:"column:version;"`
+	Tags    CodecTestTags     `tableStore:"column:tags;"`
+	Address *CodecTestAddress `tableStore:"column:address;codec:json;"`
+	Price   CodecTestCents    `tableStore:"column:price;"`
+	Hex     int64             `tableStore:"column:hex;codec:hex;"`
+	Status  CodecTestStatus   `tableStore:"column:status;"`
+}
+
+func (m *CodecTestModel) TableName() string {
+	return "codec_test"
+}
+
+type UnknownCodecTestModel struct {
+	ID   int64  `tableStore:"primaryKey;column:id;"`
+	Name string `tableStore:"column:name;codec:unknown;"`
+}
+
+func (m *UnknownCodecTestModel) TableName() string {
+	return "unknown_codec_test"
+}
+
+func TestCodec(t *testing.T) {
+	a := assert.New(t)
+
+	codecs := schema.NewCodecRegistry()
+	codecs.Register(&CodecTestCents{}, codecTestCentsCodec{})
+	codecs.RegisterName("hex", codecTestHexCodec{})
+
+	tableSchema, err := schema.ParseWithCodecs(&CodecTestModel{}, nil, codecs)
+	a.Nil(err)
+	a.Equal(schema.TextCodec, tableSchema.FieldMap["Version"].Codec)
+	a.Equal(schema.SQLCodec, tableSchema.FieldMap["Tags"].Codec)
+	a.Equal(schema.JSONCodec, tableSchema.FieldMap["Address"].Codec)
+	a.Equal(reflect.Int64, tableSchema.FieldMap["Price"].OtsKind())
+	a.Equal(reflect.String, tableSchema.FieldMap["Hex"].OtsKind())
+	a.Equal(schema.TextCodec, tableSchema.FieldMap["Status"].Codec)
+
+	// 零值按照Codec声明的类型
+	a.Equal(int64(0), tableSchema.FieldMap["Price"].ZeroOtsValue())
+	a.Equal("", tableSchema.FieldMap["Version"].ZeroOtsValue())
+
+	row := &CodecTestModel{
+		ID:      1,
+		Version: CodecTestVersion{Major: 1, Minor: 2},
+		Tags:    CodecTestTags{"a", "b"},
+		Address: &CodecTestAddress{City: "hz", Street: "wy"},
+		Price:   CodecTestCents{Value: 199},
+		Hex:     255,
+		Status:  1,
+	}
+
+	change, err := tableSchema.BuildRequestPutRowChange(row)
+	a.Nil(err)
+	columns := map[string]interface{}{}
+	for _, column := range change.Columns {
+		columns[column.ColumnName] = column.Value
+	}
+	a.Equal("1.2", columns["version"])
+	a.Equal("a,b", columns["tags"])
+	a.Equal(`{"city":"hz","street":"wy"}`, columns["address"])
+	a.Equal(int64(199), columns["price"])
+	a.Equal("ff", columns["hex"])
+	a.Equal("active", columns["status"])
+
+	result := &CodecTestModel{ID: 1}
+	a.Nil(tableSchema.FillRowColumns(result, columns))
+	a.Equal(row, result)
+
+	// 条件中使用字段类型的值会被编码, 其他类型的值直接使用
+	filter, err := tableSchema.BuildFilter(schema.Eq("Version", CodecTestVersion{Major: 2}))
+	a.Nil(err)
+	a.NotNil(filter)
+	_, _, err = tableSchema.BuildRequestUpdateColumns(map[string]interface{}{"Version": "3.0", "Price": CodecTestCents{Value: 1}})
+	a.Nil(err)
+
+	// 解码失败返回错误
+	a.NotNil(tableSchema.FillRowColumns(&CodecTestModel{}, map[string]interface{}{"price": "1"}))
+	a.NotNil(tableSchema.FillRowColumns(&CodecTestModel{}, map[string]interface{}{"address": "{"}))
+
+	// 没有注册的类型和名称
+	_, err = schema.Parse(&CodecTestModel{}, nil)
+	a.NotNil(err)
+	_, err = schema.Parse(&UnknownCodecTestModel{}, nil)
+	a.NotNil(err)
+}
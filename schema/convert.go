@@ -0,0 +1,356 @@
+package schema
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/hughcube-go/timestamps"
+	"reflect"
+	"time"
+)
+
+// TimeFormat 时间字段在表格存储中的格式, 使用timeFormat标签指定, 默认是RFC3339Nano字符串
+//
+//	LoginAt time.Time `tableStore:"column:login_at;timeFormat:unixMilli;"`
+type TimeFormat string
+
+const (
+	TimeFormatRFC3339Nano TimeFormat = "rfc3339Nano"
+	TimeFormatUnixMilli   TimeFormat = "unixMilli"
+	TimeFormatUnixMicro   TimeFormat = "unixMicro"
+)
+
+var (
+	timeType        = reflect.TypeOf(time.Time{})
+	nullTimeType    = reflect.TypeOf(sql.NullTime{})
+	nullStringType  = reflect.TypeOf(sql.NullString{})
+	nullInt64Type   = reflect.TypeOf(sql.NullInt64{})
+	nullInt32Type   = reflect.TypeOf(sql.NullInt32{})
+	nullInt16Type   = reflect.TypeOf(sql.NullInt16{})
+	nullByteType    = reflect.TypeOf(sql.NullByte{})
+	nullFloat64Type = reflect.TypeOf(sql.NullFloat64{})
+	nullBoolType    = reflect.TypeOf(sql.NullBool{})
+)
+
+func isIntegerKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+func isFloatKind(kind reflect.Kind) bool {
+	return kind == reflect.Float32 || kind == reflect.Float64
+}
+
+func isBytesType(typ reflect.Type) bool {
+	return typ.Kind() == reflect.Slice && typ.Elem().Kind() == reflect.Uint8
+}
+
+// IsTime time.Time和sql.NullTime字段
+func (f *Field) IsTime() bool {
+	return f.BaseType == timeType || f.BaseType == nullTimeType
+}
+
+// OtsKind 字段在表格存储中的类型, sql.Null*和时间字段使用对应的基本类型, 二进制是reflect.Slice,
+// 使用Codec的字段默认是字符串
+func (f *Field) OtsKind() reflect.Kind {
+	if nil != f.Codec {
+		if codecKind, ok := f.Codec.(CodecKind); ok {
+			return codecKind.OtsKind()
+		}
+		return reflect.String
+	}
+	return f.builtinKind()
+}
+
+// 时间和sql.Null*, 虽然实现了Codec对应的接口, 但是按照内置的规则转换
+func isBuiltinType(typ reflect.Type) bool {
+	switch typ {
+	case timeType, nullTimeType, nullStringType, nullInt64Type, nullInt32Type, nullInt16Type, nullByteType, nullFloat64Type, nullBoolType:
+		return true
+	}
+	return false
+}
+
+// 内置支持的类型在表格存储中的类型, 不支持的类型返回reflect.Invalid
+func (f *Field) builtinKind() reflect.Kind {
+	switch f.BaseType {
+	case timeType, nullTimeType:
+		if TimeFormatUnixMilli == f.TimeFormat || TimeFormatUnixMicro == f.TimeFormat {
+			return reflect.Int64
+		}
+		return reflect.String
+	case nullStringType:
+		return reflect.String
+	case nullInt64Type, nullInt32Type, nullInt16Type, nullByteType:
+		return reflect.Int64
+	case nullFloat64Type:
+		return reflect.Float64
+	case nullBoolType:
+		return reflect.Bool
+	}
+
+	kind := f.BaseType.Kind()
+	switch {
+	case isIntegerKind(kind):
+		return reflect.Int64
+	case isFloatKind(kind):
+		return reflect.Float64
+	case kind == reflect.String, kind == reflect.Bool:
+		return kind
+	case isBytesType(f.BaseType):
+		return reflect.Slice
+	}
+	return reflect.Invalid
+}
+
+// 检查字段类型是否可以写入表格存储, 在Parse的时候调用
+func (f *Field) checkType() error {
+	switch f.TimeFormat {
+	case TimeFormatRFC3339Nano, TimeFormatUnixMilli, TimeFormatUnixMicro:
+	default:
+		return fmt.Errorf("field %s has unsupported time format %s", f.Name, f.TimeFormat)
+	}
+
+	if f.OtsKind() == reflect.Invalid {
+		return fmt.Errorf("%w: field %s of type %s", ErrUnsupportedDataType, f.Name, f.Type)
+	}
+
+	if (f.AutoCreateTime || f.AutoUpdateTime) && (nil != f.Codec || (!f.IsTime() && reflect.Int64 != f.OtsKind())) {
+		return fmt.Errorf("%w: auto time field %s of type %s", ErrUnsupportedDataType, f.Name, f.Type)
+	}
+
+	// 软删除字段写入删除时间, 查询时和零值比较
+	if f.IsSoftDelete && (nil != f.Codec || (!f.IsTime() && reflect.Int64 != f.OtsKind())) {
+		return fmt.Errorf("%w: soft delete field %s of type %s", ErrUnsupportedDataType, f.Name, f.Type)
+	}
+
+	if f.IsVersion && (nil != f.Codec || !isIntegerKind(f.BaseType.Kind())) {
+		return fmt.Errorf("%w: version field %s of type %s", ErrUnsupportedDataType, f.Name, f.Type)
+	}
+
+	return nil
+}
+
+func (f *Field) formatTime(t time.Time, valid bool) interface{} {
+	if !valid || t.IsZero() {
+		return f.ZeroOtsValue()
+	}
+
+	switch f.TimeFormat {
+	case TimeFormatUnixMilli:
+		return t.UnixMilli()
+	case TimeFormatUnixMicro:
+		return t.UnixMicro()
+	}
+	return timestamps.FormatRFC3339Nano(sql.NullTime{Time: t, Valid: true})
+}
+
+func (f *Field) parseTime(value interface{}) (time.Time, bool, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, !v.IsZero(), nil
+	case sql.NullTime:
+		return v.Time, v.Valid, nil
+	case string:
+		if "" == v {
+			return time.Time{}, false, nil
+		}
+		t, err := timestamps.ParseRFC3339Nano(v)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("field %s: %w", f.Name, err)
+		}
+		return t.Time, t.Valid, nil
+	case int64:
+		if 0 == v {
+			return time.Time{}, false, nil
+		}
+		switch f.TimeFormat {
+		case TimeFormatUnixMilli:
+			return time.UnixMilli(v), true, nil
+		case TimeFormatUnixMicro:
+			return time.UnixMicro(v), true, nil
+		}
+	}
+	return time.Time{}, false, fmt.Errorf("field %s of type %s can not be set from %T", f.Name, f.Type, value)
+}
+
+// ToOtsValue 转换成表格存储支持的类型: 字符串, 整形, 二进制, 浮点数, 布尔值, 不支持的类型返回错误,
+// 字段类型的值使用字段的Codec编码, 其他类型的值按照基本类型转换, 比如条件中直接使用的字符串
+func (f *Field) ToOtsValue(val interface{}) (interface{}, error) {
+	value := reflect.ValueOf(val)
+	if !value.IsValid() {
+		return nil, fmt.Errorf("field %s can not be nil", f.Name)
+	}
+
+	// 空指针使用零值
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			value = reflect.New(value.Type().Elem()).Elem()
+		} else {
+			value = value.Elem()
+		}
+	}
+
+	if nil != f.Codec && value.Type() == f.BaseType {
+		encoded, err := f.Codec.Encode(value.Interface())
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", f.Name, err)
+		}
+		if nil == encoded {
+			return f.ZeroOtsValue(), nil
+		}
+
+		// Codec可以返回time.Time或者其他整形, 继续按照基本类型转换
+		value = reflect.ValueOf(encoded)
+		for value.Kind() == reflect.Ptr && !value.IsNil() {
+			value = value.Elem()
+		}
+	}
+
+	switch v := value.Interface().(type) {
+	case time.Time:
+		return f.formatTime(v, true), nil
+	case sql.NullTime:
+		return f.formatTime(v.Time, v.Valid), nil
+	case sql.NullString:
+		return v.String, nil
+	case sql.NullInt64:
+		return v.Int64, nil
+	case sql.NullInt32:
+		return int64(v.Int32), nil
+	case sql.NullInt16:
+		return int64(v.Int16), nil
+	case sql.NullByte:
+		return int64(v.Byte), nil
+	case sql.NullFloat64:
+		return v.Float64, nil
+	case sql.NullBool:
+		return v.Bool, nil
+	}
+
+	kind := value.Kind()
+	switch {
+	case kind == reflect.String:
+		return value.String(), nil
+	case isBytesType(value.Type()):
+		return value.Bytes(), nil
+	case kind == reflect.Int, kind == reflect.Int8, kind == reflect.Int16, kind == reflect.Int32, kind == reflect.Int64:
+		return value.Int(), nil
+	case isIntegerKind(kind):
+		return int64(value.Uint()), nil
+	case isFloatKind(kind):
+		return value.Float(), nil
+	case kind == reflect.Bool:
+		return value.Bool(), nil
+	}
+
+	return nil, fmt.Errorf("%w: %T for field %s", ErrUnsupportedDataType, val, f.Name)
+}
+
+// 把表格存储中的值转换成字段的基本类型, sql.Null*的值为Valid
+func (f *Field) convertValue(value reflect.Value) (reflect.Value, error) {
+	if value.Type() == f.BaseType {
+		return value, nil
+	}
+
+	if nil != f.Codec {
+		dest := reflect.New(f.BaseType)
+		if err := f.Codec.Decode(value.Interface(), dest.Interface()); err != nil {
+			return reflect.Value{}, fmt.Errorf("field %s: %w", f.Name, err)
+		}
+		return dest.Elem(), nil
+	}
+
+	switch f.BaseType {
+	case timeType, nullTimeType:
+		t, valid, err := f.parseTime(value.Interface())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if f.BaseType == timeType {
+			if !valid {
+				t = time.Time{}
+			}
+			return reflect.ValueOf(t), nil
+		}
+		return reflect.ValueOf(sql.NullTime{Time: t, Valid: valid}), nil
+	}
+
+	invalid := fmt.Errorf("field %s of type %s can not be set from %s", f.Name, f.Type, value.Type())
+	kind := value.Kind()
+
+	switch f.BaseType {
+	case nullStringType:
+		if kind == reflect.String {
+			return reflect.ValueOf(sql.NullString{String: value.String(), Valid: true}), nil
+		}
+		return reflect.Value{}, invalid
+	case nullInt64Type, nullInt32Type, nullInt16Type, nullByteType:
+		if !isIntegerKind(kind) {
+			return reflect.Value{}, invalid
+		}
+		i := value.Convert(reflect.TypeOf(int64(0))).Int()
+		switch f.BaseType {
+		case nullInt32Type:
+			return reflect.ValueOf(sql.NullInt32{Int32: int32(i), Valid: true}), nil
+		case nullInt16Type:
+			return reflect.ValueOf(sql.NullInt16{Int16: int16(i), Valid: true}), nil
+		case nullByteType:
+			return reflect.ValueOf(sql.NullByte{Byte: byte(i), Valid: true}), nil
+		}
+		return reflect.ValueOf(sql.NullInt64{Int64: i, Valid: true}), nil
+	case nullFloat64Type:
+		if !isFloatKind(kind) && !isIntegerKind(kind) {
+			return reflect.Value{}, invalid
+		}
+		return reflect.ValueOf(sql.NullFloat64{Float64: value.Convert(reflect.TypeOf(float64(0))).Float(), Valid: true}), nil
+	case nullBoolType:
+		if kind != reflect.Bool {
+			return reflect.Value{}, invalid
+		}
+		return reflect.ValueOf(sql.NullBool{Bool: value.Bool(), Valid: true}), nil
+	}
+
+	baseKind := f.BaseType.Kind()
+	switch {
+	case baseKind == reflect.String && kind == reflect.String,
+		baseKind == reflect.Bool && kind == reflect.Bool,
+		isIntegerKind(baseKind) && isIntegerKind(kind),
+		isFloatKind(baseKind) && (isFloatKind(kind) || isIntegerKind(kind)),
+		isBytesType(f.BaseType) && isBytesType(value.Type()):
+		return value.Convert(f.BaseType), nil
+	}
+
+	return reflect.Value{}, invalid
+}
+
+// SetValue 把表格存储中的值赋给字段, nil赋值为零值, 类型不匹配的时候返回错误
+func (f *Field) SetValue(fieldValue reflect.Value, value interface{}) error {
+	// 提取基本value
+	baseValue := reflect.ValueOf(value)
+	for baseValue.Kind() == reflect.Ptr && !baseValue.IsNil() {
+		baseValue = baseValue.Elem()
+	}
+
+	if !baseValue.IsValid() || baseValue.Kind() == reflect.Ptr {
+		fieldValue.Set(reflect.Zero(fieldValue.Type()))
+		return nil
+	}
+
+	trueValue, err := f.convertValue(baseValue)
+	if err != nil {
+		return err
+	}
+
+	// 如果是指针类型, 先初始化, 在用baseValue设置值
+	for i := 1; i <= f.PtrLevel; i++ {
+		pv := reflect.New(trueValue.Type())
+		pv.Elem().Set(trueValue)
+		trueValue = pv
+	}
+
+	fieldValue.Set(trueValue)
+	return nil
+}
@@ -0,0 +1,148 @@
+package schema_test
+
+import (
+	"database/sql"
+	"errors"
+	aliTableStore "github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/hughcube-go/tablestore/schema"
+	"github.com/stretchr/testify/assert"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type ConvertTestModel struct {
+	ID          int64           `tableStore:"primaryKey;column:id;"`
+	CreatedAt   time.Time       `tableStore:"column:created_at;"`
+	LoginAt     *time.Time      `tableStore:"column:login_at;timeFormat:unixMilli;"`
+	ActiveAt    sql.NullTime    `tableStore:"column:active_at;timeFormat:unixMicro;"`
+	Nickname    sql.NullString  `tableStore:"column:nickname;"`
+	Score       sql.NullInt64   `tableStore:"column:score;"`
+	Level       sql.NullInt32   `tableStore:"column:level;"`
+	Rate        sql.NullFloat64 `tableStore:"column:rate;"`
+	Enabled     sql.NullBool    `tableStore:"column:enabled;"`
+	UpdatedTime time.Time       `tableStore:"column:updated_time;timeFormat:unixMilli;autoUpdateTime;"`
+}
+
+func (m *ConvertTestModel) TableName() string {
+	return "convert_test"
+}
+
+type UnsupportedTestModel struct {
+	ID   int64          `tableStore:"primaryKey;column:id;"`
+	Tags map[string]int `tableStore:"column:tags;"`
+}
+
+func (m *UnsupportedTestModel) TableName() string {
+	return "unsupported_test"
+}
+
+type TimeFormatTestModel struct {
+	ID      int64     `tableStore:"primaryKey;column:id;"`
+	LoginAt time.Time `tableStore:"column:login_at;timeFormat:unixSecond;"`
+}
+
+func (m *TimeFormatTestModel) TableName() string {
+	return "time_format_test"
+}
+
+type SoftDeleteTypeTestModel struct {
+	ID      int64 `tableStore:"primaryKey;column:id;"`
+	Deleted bool  `tableStore:"softDelete;column:deleted;"`
+}
+
+func (m *SoftDeleteTypeTestModel) TableName() string {
+	return "soft_delete_type_test"
+}
+
+func TestFieldConvert(t *testing.T) {
+	a := assert.New(t)
+
+	tableSchema, err := schema.Parse(&ConvertTestModel{}, nil)
+	a.Nil(err)
+
+	now := time.Date(2021, 1, 2, 3, 4, 5, 6000, time.UTC)
+	row := &ConvertTestModel{
+		ID:        1,
+		CreatedAt: now,
+		LoginAt:   &now,
+		ActiveAt:  sql.NullTime{Time: now, Valid: true},
+		Nickname:  sql.NullString{String: "a", Valid: true},
+		Score:     sql.NullInt64{Int64: 10, Valid: true},
+		Level:     sql.NullInt32{Int32: 2, Valid: true},
+		Rate:      sql.NullFloat64{Float64: 1.5, Valid: true},
+		Enabled:   sql.NullBool{Bool: true, Valid: true},
+	}
+
+	change, err := tableSchema.BuildRequestPutRowChange(row)
+	a.Nil(err)
+	columns := map[string]interface{}{}
+	for _, column := range change.Columns {
+		columns[column.ColumnName] = column.Value
+	}
+	a.Equal(now.Format(time.RFC3339Nano), columns["created_at"])
+	a.Equal(now.UnixMilli(), columns["login_at"])
+	a.Equal(now.UnixMicro(), columns["active_at"])
+	a.Equal("a", columns["nickname"])
+	a.Equal(int64(10), columns["score"])
+	a.Equal(int64(2), columns["level"])
+	a.Equal(1.5, columns["rate"])
+	a.Equal(true, columns["enabled"])
+	a.Equal(int64(0), columns["updated_time"])
+
+	// 读取的时候转换回字段的类型
+	result := &ConvertTestModel{}
+	a.Nil(tableSchema.FillRowColumns(result, columns))
+	a.True(now.Equal(result.CreatedAt))
+	a.True(now.Truncate(time.Millisecond).Equal(*result.LoginAt))
+	a.True(now.Equal(result.ActiveAt.Time))
+	a.True(result.ActiveAt.Valid)
+	a.Equal(row.Nickname, result.Nickname)
+	a.Equal(row.Score, result.Score)
+	a.Equal(row.Level, result.Level)
+	a.Equal(row.Rate, result.Rate)
+	a.Equal(row.Enabled, result.Enabled)
+	a.True(result.UpdatedTime.IsZero())
+
+	// 无效的sql.Null*写入零值
+	change, err = tableSchema.BuildRequestPutRowChange(&ConvertTestModel{ID: 2})
+	a.Nil(err)
+	for _, column := range change.Columns {
+		columns[column.ColumnName] = column.Value
+	}
+	a.Equal("", columns["created_at"])
+	a.Equal(int64(0), columns["active_at"])
+
+	// 自动时间字段按照TimeFormat写入
+	tableSchema.SetAutoTime(row, now, false)
+	a.True(now.Equal(row.UpdatedTime))
+
+	// 类型不匹配的时候返回错误
+	a.NotNil(tableSchema.FillRowColumns(&ConvertTestModel{}, map[string]interface{}{"score": "a"}))
+	a.NotNil(tableSchema.FillRowColumns(&ConvertTestModel{}, map[string]interface{}{"created_at": "a"}))
+	a.NotNil(tableSchema.FillRowColumns(&ConvertTestModel{}, map[string]interface{}{"created_at": int64(1)}))
+
+	_, err = tableSchema.BuildFilter(schema.Eq("Score", map[string]int{}))
+	a.NotNil(err)
+
+	_, _, err = tableSchema.BuildRequestUpdateColumns(map[string]interface{}{"Score": []string{"a"}})
+	a.NotNil(err)
+
+	// 建表时使用对应的列类型
+	field := tableSchema.FieldMap["LoginAt"]
+	keyType, err := field.PrimaryKeyType()
+	a.Nil(err)
+	a.Equal(aliTableStore.PrimaryKeyType_INTEGER, keyType)
+	a.Equal(reflect.String, tableSchema.FieldMap["CreatedAt"].OtsKind())
+	columnType, err := tableSchema.FieldMap["Rate"].DefinedColumnType()
+	a.Nil(err)
+	a.Equal(aliTableStore.DefinedColumn_DOUBLE, columnType)
+
+	// 不支持的类型在Parse的时候返回错误
+	_, err = schema.Parse(&UnsupportedTestModel{}, nil)
+	a.NotNil(err)
+	_, err = schema.Parse(&TimeFormatTestModel{}, nil)
+	a.NotNil(err)
+	_, err = schema.Parse(&SoftDeleteTypeTestModel{}, nil)
+	a.True(errors.Is(err, schema.ErrUnsupportedDataType))
+}
@@ -2,11 +2,11 @@ package schema
 
 import (
 	"database/sql"
-	"fmt"
-	"github.com/hughcube-go/timestamps"
+	"database/sql/driver"
 	"github.com/hughcube-go/utils/msstruct"
 	"math"
 	"reflect"
+	"time"
 )
 
 type DataType string
@@ -25,6 +25,16 @@ type Field struct {
 	IsPrimaryKey    bool
 	IsAutoIncrement bool
 	IsStatement     bool
+	AutoCreateTime  bool
+	AutoUpdateTime  bool
+	IsSoftDelete    bool
+	IsVersion       bool
+	IsDefinedColumn bool
+	IsNullable      bool
+	TimeFormat      TimeFormat
+	CodecName       string
+	Codec           Codec
+	Indexes         []FieldIndex
 
 	TypeLevel  int
 	ValueLevel int
@@ -51,101 +61,98 @@ func ParseField(structField reflect.StructField) *Field {
 	field.IsPrimaryKey = tag.IsTrue("primaryKey")
 	field.IsAutoIncrement = tag.IsTrue("autoincrement")
 	field.IsStatement = tag.IsTrue("statement")
+	field.AutoCreateTime = tag.IsTrue("autoCreateTime")
+	field.AutoUpdateTime = tag.IsTrue("autoUpdateTime")
+	field.IsSoftDelete = tag.IsTrue("softDelete")
+	field.IsVersion = tag.IsTrue("version")
+	field.IsNullable = tag.IsTrue("nullable") || tag.IsTrue("omitempty")
+	field.Indexes = parseFieldIndexes(tag.Get("index"))
 
-	if sort, err := tag.GetInt("SORT"); err == nil {
-		field.Sort = sort
+	field.CodecName = tag.Get("codec")
+	field.TimeFormat = TimeFormat(tag.Get("timeFormat"))
+	if "" == field.TimeFormat {
+		field.TimeFormat = TimeFormatRFC3339Nano
 	}
 
-	return field
-}
+	// 二级索引中的属性列需要预定义
+	field.IsDefinedColumn = tag.IsTrue("definedColumn") || 0 < len(field.Indexes)
 
-func (f *Field) IsSqlTime() bool {
-	return f.BaseType == reflect.TypeOf(sql.NullTime{})
-}
-
-func (f *Field) SetValue(fieldValue reflect.Value, value interface{}) {
-	// 提取基本value
-	baseValue := reflect.ValueOf(value)
-	for baseValue.Kind() == reflect.Ptr {
-		baseValue = baseValue.Elem()
-	}
-
-	// 时间格式的需要单独处理
-	if baseValue.Kind() == reflect.String && f.IsSqlTime() {
-		if sqlTimeDate, err := timestamps.ParseRFC3339Nano(baseValue.String()); err == nil {
-			baseValue = reflect.ValueOf(sqlTimeDate)
-		}
-	}
-
-	// 数据类型转换
-	trueValue := baseValue.Convert(f.BaseType)
-
-	// 如果是指针类型, 先初始化, 在用baseValue设置值
-	tmpValue := trueValue
-	for i := 1; i <= f.PtrLevel; i++ {
-		pv := reflect.New(tmpValue.Type())
-		pv.Elem().Set(tmpValue)
-		tmpValue = pv
+	if sort, err := tag.GetInt("SORT"); err == nil {
+		field.Sort = sort
 	}
-	trueValue = tmpValue
 
-	fieldValue.Set(trueValue)
+	return field
 }
 
-func (f *Field) ToOtsValue(val interface{}) interface{} {
-	// 表格存储, 支持的类型有   字符串, 整形, 二进制, 浮点数, 布尔值
-	// 其中可能需要做强制转换的有  整形, 浮点, sqlTime
-
+// IsNullValue 空指针, 或者driver.Valuer返回nil, 比如Valid为false的sql.NullString, 视为null
+func (f *Field) IsNullValue(val interface{}) bool {
 	value := reflect.ValueOf(val)
 	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return true
+		}
 		value = value.Elem()
 	}
-	valueKind := value.Kind()
 
-	valueType := reflect.TypeOf(val)
-	for valueType.Kind() == reflect.Ptr {
-		valueType = valueType.Elem()
+	if !value.IsValid() {
+		return true
 	}
-	typeKind := valueType.Kind()
 
-	// 可能存在ZeroValue的情况
-	if typeKind != valueKind {
-		value = reflect.New(valueType).Elem()
-		valueKind = value.Kind()
+	if valuer, ok := value.Interface().(driver.Valuer); ok {
+		if result, err := valuer.Value(); err == nil && nil == result {
+			return true
+		}
 	}
 
-	if val, is := value.Interface().(sql.NullTime); is {
-		return timestamps.FormatRFC3339Nano(val)
-	}
+	return false
+}
 
-	if typeKind == reflect.String {
-		return value.String()
-	}
+func (f *Field) IsSqlTime() bool {
+	return f.BaseType == reflect.TypeOf(sql.NullTime{})
+}
 
-	if valueKind == reflect.Slice && valueType.Elem().Kind() == reflect.Uint8 {
-		return value.Bytes()
+// AutoTimeValue 自动时间字段的值, 整形字段使用秒级时间戳, 时间字段按照TimeFormat写入
+func (f *Field) AutoTimeValue(now time.Time) interface{} {
+	if !f.IsTime() && reflect.Int64 == f.OtsKind() {
+		return now.Unix()
 	}
+	return sql.NullTime{Time: now, Valid: true}
+}
 
-	if valueKind == reflect.Int || valueKind == reflect.Int8 || valueKind == reflect.Int16 || valueKind == reflect.Int32 || valueKind == reflect.Int64 {
-		return value.Int()
-	}
+// ZeroOtsValue 字段零值在表格存储中的值, 用于判断软删除字段是否已被赋值, 使用Codec的字段按照CodecKind声明的类型
+func (f *Field) ZeroOtsValue() interface{} {
+	switch f.OtsKind() {
+	case reflect.Int64:
+		return int64(0)
+	case reflect.Float64:
+		return float64(0)
+	case reflect.Bool:
+		return false
+	case reflect.Slice:
+		return []byte{}
+	}
+	return ""
+}
 
-	if valueKind == reflect.Uint || valueKind == reflect.Uint8 || valueKind == reflect.Uint16 || valueKind == reflect.Uint32 || valueKind == reflect.Uint64 {
-		return int64(value.Uint())
+// IsZeroValue 空指针, Valid为false的sql.Null*和零值都视为零值
+func (f *Field) IsZeroValue(val interface{}) bool {
+	value := reflect.ValueOf(val)
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return true
+		}
+		value = value.Elem()
 	}
 
-	if valueKind == reflect.Float32 || valueKind == reflect.Float64 {
-		return value.Float()
+	if !value.IsValid() {
+		return true
 	}
 
-	if valueKind == reflect.Bool {
-		return value.Bool()
+	if valuer, ok := value.Interface().(driver.Valuer); ok {
+		if result, err := valuer.Value(); err == nil && nil == result {
+			return true
+		}
 	}
 
-	// 无可用被识别的类型, 直接抛出错误
-	panic(fmt.Sprintf(
-		"field.ToOtsValue A cast failure requires that the %s give an %s",
-		reflect.TypeOf(value).Kind().String(),
-		f.StructField.Type.Kind().String(),
-	))
+	return value.IsZero()
 }
@@ -0,0 +1,235 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	aliTableStore "github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+)
+
+type filterKind int
+
+const (
+	filterColumn filterKind = iota
+	filterAnd
+	filterOr
+	filterNot
+	filterExistsRow
+	filterNotExistsRow
+)
+
+// Filter 条件表达式, 字段名可以是结构体字段名或者列名, 构建请求时通过Schema转换成列名和表格存储的类型
+//
+//	schema.Eq("Status", 1).And(schema.Gt("UpdatedAt", at))
+//	schema.Or(schema.Eq("Status", 1), schema.Not(schema.Eq("Name", "")))
+type Filter struct {
+	kind          filterKind
+	name          string
+	comparator    aliTableStore.ComparatorType
+	value         interface{}
+	passIfMissing bool
+	filters       []*Filter
+}
+
+func newColumnFilter(name string, comparator aliTableStore.ComparatorType, value interface{}) *Filter {
+	return &Filter{kind: filterColumn, name: name, comparator: comparator, value: value}
+}
+
+func Eq(name string, value interface{}) *Filter {
+	return newColumnFilter(name, aliTableStore.CT_EQUAL, value)
+}
+
+func Ne(name string, value interface{}) *Filter {
+	return newColumnFilter(name, aliTableStore.CT_NOT_EQUAL, value)
+}
+
+func Gt(name string, value interface{}) *Filter {
+	return newColumnFilter(name, aliTableStore.CT_GREATER_THAN, value)
+}
+
+func Ge(name string, value interface{}) *Filter {
+	return newColumnFilter(name, aliTableStore.CT_GREATER_EQUAL, value)
+}
+
+func Lt(name string, value interface{}) *Filter {
+	return newColumnFilter(name, aliTableStore.CT_LESS_THAN, value)
+}
+
+func Le(name string, value interface{}) *Filter {
+	return newColumnFilter(name, aliTableStore.CT_LESS_EQUAL, value)
+}
+
+// And 忽略nil, 只有一个条件的时候直接返回这个条件
+func And(filters ...*Filter) *Filter {
+	return combineFilters(filterAnd, filters)
+}
+
+func Or(filters ...*Filter) *Filter {
+	return combineFilters(filterOr, filters)
+}
+
+func Not(filter *Filter) *Filter {
+	return &Filter{kind: filterNot, filters: []*Filter{filter}}
+}
+
+// ExistsRow 写入时要求行存在, 只能出现在最外层或者最外层的And中
+func ExistsRow() *Filter {
+	return &Filter{kind: filterExistsRow}
+}
+
+// NotExistsRow 写入时要求行不存在, 只能出现在最外层或者最外层的And中
+func NotExistsRow() *Filter {
+	return &Filter{kind: filterNotExistsRow}
+}
+
+func combineFilters(kind filterKind, filters []*Filter) *Filter {
+	list := []*Filter{}
+	for _, filter := range filters {
+		// 相同的逻辑运算展开, 保证ExistsRow在最外层的And中
+		if nil != filter && kind == filter.kind {
+			list = append(list, filter.filters...)
+		} else if nil != filter {
+			list = append(list, filter)
+		}
+	}
+
+	if 0 == len(list) {
+		return nil
+	}
+
+	if 1 == len(list) {
+		return list[0]
+	}
+
+	return &Filter{kind: kind, filters: list}
+}
+
+func (f *Filter) And(filters ...*Filter) *Filter {
+	return And(append([]*Filter{f}, filters...)...)
+}
+
+func (f *Filter) Or(filters ...*Filter) *Filter {
+	return Or(append([]*Filter{f}, filters...)...)
+}
+
+// PassIfMissing 列不存在的时候认为满足条件, 默认列不存在的行不满足条件
+func (f *Filter) PassIfMissing() *Filter {
+	filter := *f
+	filter.passIfMissing = true
+	return &filter
+}
+
+// BuildFilter 转换成读取时的过滤器, filter为nil的时候返回nil
+func (s *Schema) BuildFilter(filter *Filter) (aliTableStore.ColumnFilter, error) {
+	if nil == filter {
+		return nil, nil
+	}
+
+	return s.buildColumnFilter(filter)
+}
+
+// BuildCondition 转换成写入时的条件, 没有ExistsRow或者NotExistsRow的时候使用expectation
+func (s *Schema) BuildCondition(filter *Filter, expectation aliTableStore.RowExistenceExpectation) (*aliTableStore.RowCondition, error) {
+	condition := &aliTableStore.RowCondition{RowExistenceExpectation: expectation}
+	if nil == filter {
+		return condition, nil
+	}
+
+	filters := []*Filter{filter}
+	if filterAnd == filter.kind {
+		filters = filter.filters
+	}
+
+	columnFilters := []*Filter{}
+	for _, item := range filters {
+		switch item.kind {
+		case filterExistsRow:
+			condition.RowExistenceExpectation = aliTableStore.RowExistenceExpectation_EXPECT_EXIST
+		case filterNotExistsRow:
+			condition.RowExistenceExpectation = aliTableStore.RowExistenceExpectation_EXPECT_NOT_EXIST
+		default:
+			columnFilters = append(columnFilters, item)
+		}
+	}
+
+	columnFilter, err := s.BuildFilter(And(columnFilters...))
+	if err != nil {
+		return nil, err
+	}
+	condition.ColumnCondition = columnFilter
+
+	return condition, nil
+}
+
+func (s *Schema) buildColumnFilter(filter *Filter) (aliTableStore.ColumnFilter, error) {
+	switch filter.kind {
+	case filterColumn:
+		field := s.FieldMap[filter.name]
+		if nil == field {
+			field = s.ColumnFieldMap[filter.name]
+		}
+		if nil == field {
+			return nil, fmt.Errorf("field %s not found in %s", filter.name, s.Name)
+		}
+
+		value := field.ZeroOtsValue()
+		if nil != filter.value {
+			var err error
+			if value, err = field.ToOtsValue(filter.value); err != nil {
+				return nil, err
+			}
+		}
+
+		condition := aliTableStore.NewSingleColumnCondition(field.DBName, filter.comparator, value)
+		condition.FilterIfMissing = !filter.passIfMissing
+		condition.LatestVersionOnly = true
+		return condition, nil
+
+	case filterAnd, filterOr, filterNot:
+		operator := aliTableStore.LO_AND
+		if filterOr == filter.kind {
+			operator = aliTableStore.LO_OR
+		} else if filterNot == filter.kind {
+			operator = aliTableStore.LO_NOT
+		}
+
+		composite := aliTableStore.NewCompositeColumnCondition(operator)
+		for _, item := range filter.filters {
+			if nil == item {
+				return nil, errors.New("filter can not be nil")
+			}
+
+			columnFilter, err := s.buildColumnFilter(item)
+			if err != nil {
+				return nil, err
+			}
+			composite.AddFilter(columnFilter)
+		}
+		return composite, nil
+	}
+
+	return nil, errors.New("ExistsRow and NotExistsRow can only be used as the outermost write condition")
+}
+
+// AndColumnFilter 合并多个过滤器, 忽略nil
+func AndColumnFilter(filters ...aliTableStore.ColumnFilter) aliTableStore.ColumnFilter {
+	list := []aliTableStore.ColumnFilter{}
+	for _, filter := range filters {
+		if nil != filter {
+			list = append(list, filter)
+		}
+	}
+
+	if 0 == len(list) {
+		return nil
+	}
+
+	if 1 == len(list) {
+		return list[0]
+	}
+
+	composite := aliTableStore.NewCompositeColumnCondition(aliTableStore.LO_AND)
+	for _, filter := range list {
+		composite.AddFilter(filter)
+	}
+	return composite
+}
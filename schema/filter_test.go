@@ -0,0 +1,37 @@
+package schema_test
+
+import (
+	aliTableStore "github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/hughcube-go/tablestore/schema"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestBuildCondition(t *testing.T) {
+	a := assert.New(t)
+
+	tableSchema, err := schema.Parse(&TestModel{}, nil)
+	a.Nil(err)
+
+	condition, err := tableSchema.BuildCondition(schema.ExistsRow().And(schema.Eq("ID", 1)), aliTableStore.RowExistenceExpectation_IGNORE)
+	a.Nil(err)
+	a.Equal(aliTableStore.RowExistenceExpectation_EXPECT_EXIST, condition.RowExistenceExpectation)
+	single, ok := condition.ColumnCondition.(*aliTableStore.SingleColumnCondition)
+	a.True(ok)
+	a.Equal("id", *single.ColumnName)
+	a.Equal(int64(1), single.ColumnValue)
+	a.True(single.FilterIfMissing)
+
+	filter, err := tableSchema.BuildFilter(schema.Or(schema.Eq("id", 1), schema.Not(schema.Lt("ID", 2).PassIfMissing())))
+	a.Nil(err)
+	composite, ok := filter.(*aliTableStore.CompositeColumnValueFilter)
+	a.True(ok)
+	a.Equal(aliTableStore.LO_OR, composite.Operator)
+	a.Len(composite.Filters, 2)
+
+	_, err = tableSchema.BuildFilter(schema.Or(schema.ExistsRow(), schema.Eq("ID", 1)))
+	a.NotNil(err)
+
+	_, err = tableSchema.BuildFilter(schema.Eq("Unknown", 1))
+	a.NotNil(err)
+}
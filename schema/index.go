@@ -0,0 +1,183 @@
+package schema
+
+import (
+	"fmt"
+	aliTableStore "github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// IndexDefinition 二级索引的定义, 字段可以是结构体字段名或者列名
+type IndexDefinition struct {
+	Name string
+
+	// 本地二级索引的第一个主键列必须和主表的第一个主键列相同
+	Local bool
+
+	// 索引的主键列, 主表的主键列会按照顺序自动追加到最后
+	PrimaryKey []string
+
+	// 索引中包含的属性列
+	DefinedColumns []string
+}
+
+// Indexer 模型实现之后使用模型返回的二级索引定义, 和index标签定义的同名索引以Indexes为准
+type Indexer interface {
+	Indexes() []IndexDefinition
+}
+
+// FieldIndex 对应index标签, index:idx_name,1 表示字段是索引idx_name的第1个主键列, 多个索引用|分隔
+type FieldIndex struct {
+	Name  string
+	Order int
+}
+
+func parseFieldIndexes(value string) []FieldIndex {
+	indexes := []FieldIndex{}
+	for _, item := range strings.Split(value, "|") {
+		parts := strings.Split(item, ",")
+		name := strings.TrimSpace(parts[0])
+		if "" == name {
+			continue
+		}
+
+		index := FieldIndex{Name: name}
+		if 1 < len(parts) {
+			index.Order, _ = strconv.Atoi(strings.TrimSpace(parts[1]))
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes
+}
+
+// GetIndexes 合并index标签和Indexer的二级索引定义, 按照索引名排序
+func (s *Schema) GetIndexes(row Tabler) []IndexDefinition {
+	type indexColumn struct {
+		field *Field
+		order int
+	}
+
+	columns := map[string][]indexColumn{}
+	for _, field := range s.Fields {
+		for _, index := range field.Indexes {
+			columns[index.Name] = append(columns[index.Name], indexColumn{field: field, order: index.Order})
+		}
+	}
+
+	definitions := map[string]IndexDefinition{}
+	for name, list := range columns {
+		sort.SliceStable(list, func(i, j int) bool {
+			if list[i].order != list[j].order {
+				return list[i].order < list[j].order
+			}
+			return list[i].field.DBName < list[j].field.DBName
+		})
+
+		definition := IndexDefinition{Name: name}
+		for _, column := range list {
+			definition.PrimaryKey = append(definition.PrimaryKey, column.field.DBName)
+		}
+		definitions[name] = definition
+	}
+
+	if indexer, ok := row.(Indexer); ok {
+		for _, definition := range indexer.Indexes() {
+			definitions[definition.Name] = definition
+		}
+	}
+
+	indexes := []IndexDefinition{}
+	for _, definition := range definitions {
+		indexes = append(indexes, definition)
+	}
+	sort.Slice(indexes, func(i, j int) bool {
+		return indexes[i].Name < indexes[j].Name
+	})
+
+	return indexes
+}
+
+func (s *Schema) GetIndex(row Tabler, name string) (*IndexDefinition, error) {
+	for _, definition := range s.GetIndexes(row) {
+		if definition.Name == name {
+			return &definition, nil
+		}
+	}
+	return nil, fmt.Errorf("index %s not found in %s", name, s.Name)
+}
+
+func (s *Schema) lookupField(name string) (*Field, error) {
+	if field, ok := s.FieldMap[name]; ok {
+		return field, nil
+	}
+	if field, ok := s.ColumnFieldMap[name]; ok {
+		return field, nil
+	}
+	return nil, fmt.Errorf("field %s not found in %s", name, s.Name)
+}
+
+// IndexPrimaryKeyFields 索引的主键列, 包括自动追加的主表主键列
+func (s *Schema) IndexPrimaryKeyFields(index IndexDefinition) ([]*Field, error) {
+	fields := []*Field{}
+	exists := map[*Field]bool{}
+	for _, name := range index.PrimaryKey {
+		field, err := s.lookupField(name)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field)
+		exists[field] = true
+	}
+
+	for _, field := range s.PrimaryKeyFields() {
+		if !exists[field] {
+			fields = append(fields, field)
+		}
+	}
+
+	return fields, nil
+}
+
+func (s *Schema) BuildIndexMeta(index IndexDefinition) (*aliTableStore.IndexMeta, error) {
+	meta := &aliTableStore.IndexMeta{IndexName: index.Name, IndexType: aliTableStore.IT_GLOBAL_INDEX}
+	if index.Local {
+		meta.IndexType = aliTableStore.IT_LOCAL_INDEX
+	}
+
+	for _, name := range index.PrimaryKey {
+		field, err := s.lookupField(name)
+		if err != nil {
+			return nil, err
+		}
+		meta.AddPrimaryKeyColumn(field.DBName)
+	}
+
+	for _, name := range index.DefinedColumns {
+		field, err := s.lookupField(name)
+		if err != nil {
+			return nil, err
+		}
+		meta.AddDefinedColumn(field.DBName)
+	}
+
+	if 0 == len(meta.Primarykey) {
+		return nil, fmt.Errorf("index %s has no primary key", index.Name)
+	}
+
+	return meta, nil
+}
+
+// BuildIndexRangePrimaryKey 和BuildRequestRangePrimaryKey相同, 使用索引的主键列
+func (s *Schema) BuildIndexRangePrimaryKey(index IndexDefinition, condition interface{}) (*aliTableStore.PrimaryKey, bool, error) {
+	conditionMap, isMin, primaryKey, err := parseRangeCondition(condition)
+	if nil != primaryKey || err != nil {
+		return primaryKey, false, err
+	}
+
+	fields, err := s.IndexPrimaryKeyFields(index)
+	if err != nil {
+		return nil, false, err
+	}
+	return buildRangePrimaryKey(fields, conditionMap, isMin)
+}
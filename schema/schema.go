@@ -8,6 +8,7 @@ import (
 	"reflect"
 	"sort"
 	"sync"
+	"time"
 )
 
 // ErrUnsupportedDataType unsupported data type
@@ -72,6 +73,11 @@ func getDestElemType(dest interface{}) (reflect.Type, error) {
 }
 
 func Parse(dest interface{}, cache *sync.Map) (*Schema, error) {
+	return ParseWithCodecs(dest, cache, nil)
+}
+
+// ParseWithCodecs 使用codecs中注册的Codec解析, codecs为nil的时候只使用默认注册的名称
+func ParseWithCodecs(dest interface{}, cache *sync.Map, codecs *CodecRegistry) (*Schema, error) {
 	modelType, err := getDestElemType(dest)
 	if err != nil {
 		return nil, err
@@ -130,6 +136,18 @@ func Parse(dest interface{}, cache *sync.Map) (*Schema, error) {
 
 	sort.Sort(tableSchema.Fields)
 
+	if nil == codecs {
+		codecs = defaultCodecRegistry
+	}
+	for _, field := range tableSchema.Fields {
+		if field.Codec, err = codecs.lookup(field); err != nil {
+			return nil, err
+		}
+		if err := field.checkType(); err != nil {
+			return nil, err
+		}
+	}
+
 	if nil != cache {
 		cache.Store(modelType, tableSchema)
 	}
@@ -173,6 +191,116 @@ func (s *Schema) GetAutoIncrField() *Field {
 	return nil
 }
 
+func (s *Schema) GetSoftDeleteField() *Field {
+	for _, field := range s.Fields {
+		if field.IsSoftDelete && !field.IsPrimaryKey {
+			return field
+		}
+	}
+	return nil
+}
+
+func (s *Schema) GetVersionField() *Field {
+	for _, field := range s.Fields {
+		if field.IsVersion && !field.IsPrimaryKey {
+			return field
+		}
+	}
+	return nil
+}
+
+// BuildVersionFilter 乐观锁的条件, 版本字段等于row中的当前值, 没有版本字段的时候返回nil
+func (s *Schema) BuildVersionFilter(row Tabler) *Filter {
+	field := s.GetVersionField()
+	if nil == field {
+		return nil
+	}
+
+	var filter *Filter
+	s.eachField(row, func(item *Field, value reflect.Value) {
+		if item == field {
+			filter = Eq(field.DBName, value.Interface())
+		}
+	}, 0)
+
+	return filter
+}
+
+// WithVersionIncrement 版本字段加1, columns中已经指定版本字段的时候不修改
+func (s *Schema) WithVersionIncrement(columns map[string]interface{}) map[string]interface{} {
+	field := s.GetVersionField()
+	if nil == field {
+		return columns
+	}
+
+	_, hasColumn := columns[field.DBName]
+	_, hasField := columns[field.Name]
+	if hasColumn || hasField {
+		return columns
+	}
+
+	result := map[string]interface{}{field.DBName: IncrementValue(1)}
+	for name, value := range columns {
+		result[name] = value
+	}
+	return result
+}
+
+// GetVersion 返回行中版本字段的值, 没有版本字段的时候ok为false
+func (s *Schema) GetVersion(row Tabler) (version int64, ok bool) {
+	field := s.GetVersionField()
+	if nil == field {
+		return 0, false
+	}
+
+	s.eachField(row, func(item *Field, value reflect.Value) {
+		if item == field {
+			if otsValue, err := field.ToOtsValue(value.Interface()); err == nil {
+				version, ok = otsValue.(int64)
+			}
+		}
+	}, 0)
+
+	return version, ok
+}
+
+// SetVersion 给行中的版本字段赋值, 没有版本字段的时候不做任何修改
+func (s *Schema) SetVersion(row Tabler, version int64) {
+	field := s.GetVersionField()
+	if nil == field {
+		return
+	}
+
+	s.eachField(row, func(item *Field, value reflect.Value) {
+		// 版本字段在Parse时已经检查过是整形
+		if item == field && value.CanSet() {
+			_ = field.SetValue(value, version)
+		}
+	}, 0)
+}
+
+// BuildSoftDeleteFilter 过滤掉已经软删除的行, 软删除字段不存在或者为零值的行才会返回
+func (s *Schema) BuildSoftDeleteFilter() aliTableStore.ColumnFilter {
+	field := s.GetSoftDeleteField()
+	if nil == field {
+		return nil
+	}
+
+	filter := aliTableStore.NewSingleColumnCondition(field.DBName, aliTableStore.CT_EQUAL, field.ZeroOtsValue())
+	filter.FilterIfMissing = false
+	filter.LatestVersionOnly = true
+	return filter
+}
+
+// BuildNotSoftDeletedFilter 软删除字段不存在或者为零值, 软删除时作为写入条件, 没有软删除字段的时候返回nil
+func (s *Schema) BuildNotSoftDeletedFilter() *Filter {
+	field := s.GetSoftDeleteField()
+	if nil == field {
+		return nil
+	}
+	return Eq(field.Name, nil).PassIfMissing()
+}
+
 func (s *Schema) eachField(row interface{}, callback func(field *Field, value reflect.Value), level int) {
 	rowValue := reflect.ValueOf(row)
 	rowType := reflect.TypeOf(row)
@@ -201,76 +329,189 @@ func (s *Schema) eachField(row interface{}, callback func(field *Field, value re
 	}
 }
 
-func (s *Schema) EachSetRequestColumn(row Tabler, callback func(field *Field, value interface{})) {
+// EachSetRequestColumn 转换失败的字段不会调用callback, 返回第一个错误
+func (s *Schema) EachSetRequestColumn(row Tabler, callback func(field *Field, value interface{})) error {
+	var err error
 	setRequestColumnCallback := func(field *Field, columnValue reflect.Value) {
-		callback(field, field.ToOtsValue(columnValue.Interface()))
+		value, convertErr := field.ToOtsValue(columnValue.Interface())
+		if convertErr != nil {
+			if err == nil {
+				err = convertErr
+			}
+			return
+		}
+		callback(field, value)
 	}
 	s.eachField(row, setRequestColumnCallback, 0)
+	return err
 }
 
-func (s *Schema) BuildRequestPrimaryKey(row Tabler) *aliTableStore.PrimaryKey {
-	primaryKeys := new(aliTableStore.PrimaryKey)
-	s.EachSetRequestColumn(row, func(field *Field, value interface{}) {
+// BuildRequestPrimaryKey 主键列按照PrimaryKeyFields的顺序, 和建表时的顺序一致
+func (s *Schema) BuildRequestPrimaryKey(row Tabler) (*aliTableStore.PrimaryKey, error) {
+	values := map[*Field]interface{}{}
+	err := s.EachSetRequestColumn(row, func(field *Field, value interface{}) {
 		if field.IsPrimaryKey {
-			primaryKeys.AddPrimaryKeyColumn(field.DBName, value)
+			values[field] = value
 		}
 	})
-	return primaryKeys
+	if err != nil {
+		return nil, err
+	}
+
+	primaryKeys := new(aliTableStore.PrimaryKey)
+	for _, field := range s.PrimaryKeyFields() {
+		if value, ok := values[field]; ok {
+			primaryKeys.AddPrimaryKeyColumn(field.DBName, value)
+		}
+	}
+	return primaryKeys, nil
 }
 
-func (s *Schema) BuildRequestPutRowChange(row Tabler) *aliTableStore.PutRowChange {
+func (s *Schema) BuildRequestPutRowChange(row Tabler) (*aliTableStore.PutRowChange, error) {
 	putRowChange := new(aliTableStore.PutRowChange)
 	putRowChange.TableName = row.TableName()
 	putRowChange.PrimaryKey = new(aliTableStore.PrimaryKey)
 	putRowChange.SetCondition(aliTableStore.RowExistenceExpectation_EXPECT_NOT_EXIST)
 
-	s.EachSetRequestColumn(row, func(field *Field, value interface{}) {
-		if field.IsPrimaryKey && !field.IsAutoIncrement {
-			putRowChange.PrimaryKey.AddPrimaryKeyColumn(field.DBName, value)
-		} else if field.IsPrimaryKey && field.IsAutoIncrement {
-			putRowChange.PrimaryKey.AddPrimaryKeyColumnWithAutoIncrement(field.DBName)
+	var err error
+	primaryKeyValues := map[*Field]interface{}{}
+	s.eachField(row, func(field *Field, fieldValue reflect.Value) {
+		if err != nil {
+			return
+		}
+
+		// 未删除的行不写入软删除字段
+		if field.IsSoftDelete && !field.IsPrimaryKey && field.IsZeroValue(fieldValue.Interface()) {
+			return
+		}
+
+		// nullable字段的null值不写入
+		if field.IsNullable && !field.IsPrimaryKey && field.IsNullValue(fieldValue.Interface()) {
+			return
+		}
+
+		var value interface{}
+		if value, err = field.ToOtsValue(fieldValue.Interface()); err != nil {
+			return
+		}
+
+		if field.IsPrimaryKey {
+			primaryKeyValues[field] = value
 		} else {
 			putRowChange.AddColumn(field.DBName, value)
 		}
+	}, 0)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// 主键列按照PrimaryKeyFields的顺序
+	for _, field := range s.PrimaryKeyFields() {
+		value, ok := primaryKeyValues[field]
+		if !ok {
+			continue
+		}
 
 		if field.IsAutoIncrement {
+			putRowChange.PrimaryKey.AddPrimaryKeyColumnWithAutoIncrement(field.DBName)
 			putRowChange.SetCondition(aliTableStore.RowExistenceExpectation_IGNORE)
 			putRowChange.SetReturnPk()
+		} else {
+			putRowChange.PrimaryKey.AddPrimaryKeyColumn(field.DBName, value)
 		}
-	})
+	}
 
-	return putRowChange
+	return putRowChange, nil
 }
 
-func (s *Schema) BuildRequestRangePrimaryKey(condition interface{}) (*aliTableStore.PrimaryKey, bool, error) {
+// SetAutoTime 给自动时间字段赋值, 写入时autoCreateTime和autoUpdateTime都会被赋值,
+// 自动时间字段在Parse时已经检查过是时间或者整形, 赋值不会失败
+func (s *Schema) SetAutoTime(row Tabler, now time.Time, isCreate bool) {
+	s.eachField(row, func(field *Field, fieldValue reflect.Value) {
+		if (isCreate && field.AutoCreateTime) || field.AutoUpdateTime {
+			if fieldValue.CanSet() {
+				_ = field.SetValue(fieldValue, field.AutoTimeValue(now))
+			}
+		}
+	}, 0)
+}
 
+// SetAutoTimeOnSave 覆盖写入时使用, autoUpdateTime字段总是赋值, autoCreateTime字段只在零值的时候赋值
+func (s *Schema) SetAutoTimeOnSave(row Tabler, now time.Time) {
+	s.eachField(row, func(field *Field, fieldValue reflect.Value) {
+		if !fieldValue.CanSet() {
+			return
+		}
+
+		if field.AutoUpdateTime || (field.AutoCreateTime && field.IsZeroValue(fieldValue.Interface())) {
+			_ = field.SetValue(fieldValue, field.AutoTimeValue(now))
+		}
+	}, 0)
+}
+
+// WithAutoUpdateTime 返回追加了autoUpdateTime字段的更新列, 已经指定的列不会被覆盖
+func (s *Schema) WithAutoUpdateTime(columns map[string]interface{}, now time.Time) map[string]interface{} {
+	result := map[string]interface{}{}
+	for name, value := range columns {
+		result[name] = value
+	}
+
+	for _, field := range s.Fields {
+		if !field.AutoUpdateTime || field.IsPrimaryKey {
+			continue
+		}
+
+		_, hasColumn := result[field.DBName]
+		_, hasField := result[field.Name]
+		if !hasColumn && !hasField {
+			result[field.DBName] = field.AutoTimeValue(now)
+		}
+	}
+
+	return result
+}
+
+// 解析范围查询的条件, 已经是*aliTableStore.PrimaryKey的时候直接返回
+func parseRangeCondition(condition interface{}) (RangePrimaryKey, bool, *aliTableStore.PrimaryKey, error) {
 	if primaryKey, ok := condition.(*aliTableStore.PrimaryKey); ok {
-		return primaryKey, false, nil
+		return nil, false, primaryKey, nil
 	}
 
-	var conditionMap RangePrimaryKey
-	var isMin bool
+	if conditionMap, ok := condition.(MaxPrimaryKey); ok {
+		return RangePrimaryKey(conditionMap), false, nil, nil
+	}
+
+	if conditionMap, ok := condition.(MinPrimaryKey); ok {
+		return RangePrimaryKey(conditionMap), true, nil, nil
+	}
+
+	return nil, false, nil, errors.New("The type must be MaxPrimaryKey or MinPrimaryKey")
+}
 
-	if _, ok := condition.(MaxPrimaryKey); ok {
-		isMin = false
-		conditionMap = RangePrimaryKey(condition.(MaxPrimaryKey))
-	} else if _, ok := condition.(MinPrimaryKey); ok {
-		isMin = true
-		conditionMap = RangePrimaryKey(condition.(MinPrimaryKey))
-	} else {
-		return nil, false, errors.New("The type must be MaxPrimaryKey or MinPrimaryKey")
+func (s *Schema) BuildRequestRangePrimaryKey(condition interface{}) (*aliTableStore.PrimaryKey, bool, error) {
+	conditionMap, isMin, primaryKey, err := parseRangeCondition(condition)
+	if nil != primaryKey || err != nil {
+		return primaryKey, false, err
 	}
 
+	return buildRangePrimaryKey(s.PrimaryKeyFields(), conditionMap, isMin)
+}
+
+func buildRangePrimaryKey(fields []*Field, conditionMap RangePrimaryKey, isMin bool) (*aliTableStore.PrimaryKey, bool, error) {
 	primaryKeys := new(aliTableStore.PrimaryKey)
-	for _, field := range s.Fields {
-		if !field.IsPrimaryKey {
-			continue
+	for _, field := range fields {
+		value, ok := conditionMap[field.DBName]
+		if !ok || nil == value {
+			value, ok = conditionMap[field.Name]
 		}
 
-		if value, ok := conditionMap[field.DBName]; ok && nil != value {
-			primaryKeys.AddPrimaryKeyColumn(field.DBName, field.ToOtsValue(value))
-		} else if value, ok := conditionMap[field.Name]; ok && nil != value {
-			primaryKeys.AddPrimaryKeyColumn(field.DBName, field.ToOtsValue(value))
+		if ok && nil != value {
+			otsValue, err := field.ToOtsValue(value)
+			if err != nil {
+				return nil, false, err
+			}
+			primaryKeys.AddPrimaryKeyColumn(field.DBName, otsValue)
 		} else if isMin {
 			primaryKeys.AddPrimaryKeyColumnWithMinValue(field.DBName)
 		} else {
@@ -281,7 +522,7 @@ func (s *Schema) BuildRequestRangePrimaryKey(condition interface{}) (*aliTableSt
 	return primaryKeys, isMin, nil
 }
 
-func (s *Schema) BuildRequestUpdateColumns(columns map[string]interface{}) (*aliTableStore.UpdateRowChange, map[string]interface{}) {
+func (s *Schema) BuildRequestUpdateColumns(columns map[string]interface{}) (*aliTableStore.UpdateRowChange, map[string]interface{}, error) {
 	rowChange := new(aliTableStore.UpdateRowChange)
 	rowChange.SetReturnIncrementValue()
 
@@ -300,20 +541,32 @@ func (s *Schema) BuildRequestUpdateColumns(columns map[string]interface{}) (*ali
 			continue
 		}
 
-		if value, ok := columnValue.(IncrementValue); ok {
+		// nullable字段的null值删除对应的列
+		if field.IsNullable && field.IsNullValue(columnValue) {
+			columnValue = DeleteColumn
+		}
+
+		if value, ok := columnValue.(DeleteColumnValue); ok && 0 == value.Timestamp {
+			rowChange.DeleteColumn(field.DBName)
+		} else if ok {
+			rowChange.DeleteColumnWithTimestamp(field.DBName, value.Timestamp)
+		} else if value, ok := columnValue.(IncrementValue); ok {
 			rowChange.IncrementColumn(field.DBName, int64(value))
 			rowChange.AppendIncrementColumnToReturn(field.DBName)
 		} else {
-			otsValue := field.ToOtsValue(columnValue)
+			otsValue, err := field.ToOtsValue(columnValue)
+			if err != nil {
+				return nil, nil, err
+			}
 			rowChange.PutColumn(field.DBName, otsValue)
 			directlyColumns[field.DBName] = otsValue
 		}
 	}
 
-	return rowChange, directlyColumns
+	return rowChange, directlyColumns, nil
 }
 
-func (s *Schema) FillRow(row interface{}, primaryKeys []*aliTableStore.PrimaryKeyColumn, columns []*aliTableStore.AttributeColumn) {
+func (s *Schema) FillRow(row interface{}, primaryKeys []*aliTableStore.PrimaryKeyColumn, columns []*aliTableStore.AttributeColumn) error {
 	columnMap := map[string]interface{}{}
 	for _, primaryKey := range primaryKeys {
 		columnMap[primaryKey.ColumnName] = primaryKey.Value
@@ -323,14 +576,19 @@ func (s *Schema) FillRow(row interface{}, primaryKeys []*aliTableStore.PrimaryKe
 		columnMap[column.ColumnName] = column.Value
 	}
 
-	s.FillRowColumns(row, columnMap)
+	return s.FillRowColumns(row, columnMap)
 }
 
-func (s *Schema) FillRowColumns(row interface{}, columns map[string]interface{}) {
+// FillRowColumns 类型不匹配的列返回第一个错误, 其他的列仍然会赋值
+func (s *Schema) FillRowColumns(row interface{}, columns map[string]interface{}) error {
+	var err error
 	setRowFieldCallback := func(field *Field, fieldValue reflect.Value) {
 		if value, ok := columns[field.DBName]; ok && fieldValue.CanSet() {
-			field.SetValue(fieldValue, value)
+			if setErr := field.SetValue(fieldValue, value); setErr != nil && err == nil {
+				err = setErr
+			}
 		}
 	}
 	s.eachField(row, setRowFieldCallback, 0)
+	return err
 }
@@ -2,6 +2,7 @@ package schema_test
 
 import (
 	"database/sql"
+	aliTableStore "github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
 	"github.com/hughcube-go/tablestore/schema"
 	"github.com/stretchr/testify/assert"
 	"testing"
@@ -44,3 +45,53 @@ func TestSchemaParse(t *testing.T) {
 	a.Nil(err)
 	a.IsType(tableSchema, &schema.Schema{})
 }
+
+type SortTestModel struct {
+	OrderId int64  `tableStore:"primaryKey;column:order_id;sort:2;"`
+	UserId  int64  `tableStore:"primaryKey;column:user_id;sort:1;"`
+	Name    string `tableStore:"column:name;"`
+}
+
+func (m *SortTestModel) TableName() string {
+	return "sort_test"
+}
+
+func TestPrimaryKeyOrder(t *testing.T) {
+	a := assert.New(t)
+
+	tableSchema, err := schema.Parse(&SortTestModel{}, nil)
+	a.Nil(err)
+
+	// 建表和读写请求中的主键列都按照sort排序, 和声明的顺序无关
+	meta, err := tableSchema.BuildTableMeta("sort_test")
+	a.Nil(err)
+	a.Len(meta.SchemaEntry, 2)
+	a.Equal("user_id", *meta.SchemaEntry[0].Name)
+	a.Equal("order_id", *meta.SchemaEntry[1].Name)
+
+	names := func(primaryKey *aliTableStore.PrimaryKey) []string {
+		result := []string{}
+		for _, column := range primaryKey.PrimaryKeys {
+			result = append(result, column.ColumnName)
+		}
+		return result
+	}
+
+	row := &SortTestModel{OrderId: 2, UserId: 1}
+	primaryKey, err := tableSchema.BuildRequestPrimaryKey(row)
+	a.Nil(err)
+	a.Equal([]string{"user_id", "order_id"}, names(primaryKey))
+	a.Equal(int64(1), primaryKey.PrimaryKeys[0].Value)
+
+	change, err := tableSchema.BuildRequestPutRowChange(row)
+	a.Nil(err)
+	a.Equal([]string{"user_id", "order_id"}, names(change.PrimaryKey))
+
+	rangeKey, _, err := tableSchema.BuildRequestRangePrimaryKey(schema.MinPrimaryKey{"OrderId": 2})
+	a.Nil(err)
+	a.Equal([]string{"user_id", "order_id"}, names(rangeKey))
+
+	partitionKey, err := tableSchema.BuildPartitionKey(row)
+	a.Nil(err)
+	a.Equal([]string{"user_id"}, names(partitionKey))
+}
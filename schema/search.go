@@ -0,0 +1,399 @@
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore/search"
+	"strings"
+)
+
+// Query 多元索引的查询条件, 字段名可以是结构体字段名或者列名, 嵌套字段使用 字段名.子字段名
+//
+//	schema.Bool().Must(schema.Term("Status", 1), schema.Range("Age").Gte(18)).MustNot(schema.Prefix("Name", "test"))
+type Query interface {
+	BuildQuery(s *Schema) (search.Query, error)
+}
+
+type queryFunc func(s *Schema) (search.Query, error)
+
+func (f queryFunc) BuildQuery(s *Schema) (search.Query, error) {
+	return f(s)
+}
+
+// 解析多元索引中的字段名, 嵌套字段只转换第一段, 返回nil的field表示不做值的转换
+func (s *Schema) resolveSearchField(name string) (string, *Field, error) {
+	if field, err := s.lookupField(name); err == nil {
+		return field.DBName, field, nil
+	}
+
+	if index := strings.Index(name, "."); 0 < index {
+		field, err := s.lookupField(name[:index])
+		if err != nil {
+			return "", nil, err
+		}
+		return field.DBName + name[index:], nil, nil
+	}
+
+	return "", nil, fmt.Errorf("field %s not found in %s", name, s.Name)
+}
+
+func (s *Schema) resolveSearchValue(field *Field, value interface{}) (interface{}, error) {
+	if nil == field || nil == value {
+		return value, nil
+	}
+	return field.ToOtsValue(value)
+}
+
+func MatchAll() Query {
+	return queryFunc(func(s *Schema) (search.Query, error) {
+		return &search.MatchAllQuery{}, nil
+	})
+}
+
+func Term(name string, value interface{}) Query {
+	return queryFunc(func(s *Schema) (search.Query, error) {
+		column, field, err := s.resolveSearchField(name)
+		if err != nil {
+			return nil, err
+		}
+		term, err := s.resolveSearchValue(field, value)
+		if err != nil {
+			return nil, err
+		}
+		return &search.TermQuery{FieldName: column, Term: term}, nil
+	})
+}
+
+func Terms(name string, values ...interface{}) Query {
+	return queryFunc(func(s *Schema) (search.Query, error) {
+		column, field, err := s.resolveSearchField(name)
+		if err != nil {
+			return nil, err
+		}
+
+		query := &search.TermsQuery{FieldName: column}
+		for _, value := range values {
+			term, err := s.resolveSearchValue(field, value)
+			if err != nil {
+				return nil, err
+			}
+			query.Terms = append(query.Terms, term)
+		}
+		return query, nil
+	})
+}
+
+func Prefix(name string, prefix string) Query {
+	return queryFunc(func(s *Schema) (search.Query, error) {
+		column, _, err := s.resolveSearchField(name)
+		if err != nil {
+			return nil, err
+		}
+		return &search.PrefixQuery{FieldName: column, Prefix: prefix}, nil
+	})
+}
+
+// Wildcard *匹配任意多个字符, ?匹配一个字符
+func Wildcard(name string, value string) Query {
+	return queryFunc(func(s *Schema) (search.Query, error) {
+		column, _, err := s.resolveSearchField(name)
+		if err != nil {
+			return nil, err
+		}
+		return &search.WildcardQuery{FieldName: column, Value: value}, nil
+	})
+}
+
+func Exists(name string) Query {
+	return queryFunc(func(s *Schema) (search.Query, error) {
+		column, _, err := s.resolveSearchField(name)
+		if err != nil {
+			return nil, err
+		}
+		return &search.ExistsQuery{FieldName: column}, nil
+	})
+}
+
+// MatchQuery 分词之后匹配, 默认任意一个词匹配即可
+type MatchQuery struct {
+	name   string
+	text   string
+	and    bool
+	phrase bool
+}
+
+func Match(name string, text string) *MatchQuery {
+	return &MatchQuery{name: name, text: text}
+}
+
+// MatchPhrase 分词之后按照顺序完整匹配
+func MatchPhrase(name string, text string) *MatchQuery {
+	return &MatchQuery{name: name, text: text, phrase: true}
+}
+
+// MatchAllTerms 所有的词都需要匹配
+func (q *MatchQuery) MatchAllTerms() *MatchQuery {
+	q.and = true
+	return q
+}
+
+func (q *MatchQuery) BuildQuery(s *Schema) (search.Query, error) {
+	column, _, err := s.resolveSearchField(q.name)
+	if err != nil {
+		return nil, err
+	}
+
+	if q.phrase {
+		return &search.MatchPhraseQuery{FieldName: column, Text: q.text}, nil
+	}
+
+	query := &search.MatchQuery{FieldName: column, Text: q.text}
+	if q.and {
+		query.Operator = search.QueryOperator_AND.Enum()
+	}
+	return query, nil
+}
+
+type RangeQuery struct {
+	name         string
+	from         interface{}
+	to           interface{}
+	includeLower bool
+	includeUpper bool
+}
+
+func Range(name string) *RangeQuery {
+	return &RangeQuery{name: name}
+}
+
+func (q *RangeQuery) Gt(value interface{}) *RangeQuery {
+	q.from, q.includeLower = value, false
+	return q
+}
+
+func (q *RangeQuery) Gte(value interface{}) *RangeQuery {
+	q.from, q.includeLower = value, true
+	return q
+}
+
+func (q *RangeQuery) Lt(value interface{}) *RangeQuery {
+	q.to, q.includeUpper = value, false
+	return q
+}
+
+func (q *RangeQuery) Lte(value interface{}) *RangeQuery {
+	q.to, q.includeUpper = value, true
+	return q
+}
+
+func (q *RangeQuery) BuildQuery(s *Schema) (search.Query, error) {
+	column, field, err := s.resolveSearchField(q.name)
+	if err != nil {
+		return nil, err
+	}
+
+	if nil == q.from && nil == q.to {
+		return nil, fmt.Errorf("range query of %s has no bound", q.name)
+	}
+
+	query := &search.RangeQuery{FieldName: column, IncludeLower: q.includeLower, IncludeUpper: q.includeUpper}
+	if query.From, err = s.resolveSearchValue(field, q.from); err != nil {
+		return nil, err
+	}
+	if query.To, err = s.resolveSearchValue(field, q.to); err != nil {
+		return nil, err
+	}
+	return query, nil
+}
+
+type BoolQuery struct {
+	must               []Query
+	mustNot            []Query
+	filter             []Query
+	should             []Query
+	minimumShouldMatch *int32
+}
+
+func Bool() *BoolQuery {
+	return &BoolQuery{}
+}
+
+func (q *BoolQuery) Must(queries ...Query) *BoolQuery {
+	q.must = append(q.must, queries...)
+	return q
+}
+
+func (q *BoolQuery) MustNot(queries ...Query) *BoolQuery {
+	q.mustNot = append(q.mustNot, queries...)
+	return q
+}
+
+// Filter 和Must相同, 但是不参与相关性打分
+func (q *BoolQuery) Filter(queries ...Query) *BoolQuery {
+	q.filter = append(q.filter, queries...)
+	return q
+}
+
+func (q *BoolQuery) Should(queries ...Query) *BoolQuery {
+	q.should = append(q.should, queries...)
+	return q
+}
+
+func (q *BoolQuery) MinimumShouldMatch(count int32) *BoolQuery {
+	q.minimumShouldMatch = &count
+	return q
+}
+
+func (q *BoolQuery) BuildQuery(s *Schema) (search.Query, error) {
+	query := &search.BoolQuery{MinimumShouldMatch: q.minimumShouldMatch}
+
+	var err error
+	if query.MustQueries, err = s.buildQueries(q.must); err != nil {
+		return nil, err
+	}
+	if query.MustNotQueries, err = s.buildQueries(q.mustNot); err != nil {
+		return nil, err
+	}
+	if query.FilterQueries, err = s.buildQueries(q.filter); err != nil {
+		return nil, err
+	}
+	if query.ShouldQueries, err = s.buildQueries(q.should); err != nil {
+		return nil, err
+	}
+
+	return query, nil
+}
+
+func (s *Schema) buildQueries(queries []Query) ([]search.Query, error) {
+	result := []search.Query{}
+	for _, query := range queries {
+		if nil == query {
+			return nil, errors.New("query can not be nil")
+		}
+
+		item, err := query.BuildQuery(s)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, item)
+	}
+	return result, nil
+}
+
+// Nested 查询嵌套类型的字段, query中的字段名需要带上path, 比如 Tags.name
+func Nested(path string, query Query) Query {
+	return queryFunc(func(s *Schema) (search.Query, error) {
+		column, _, err := s.resolveSearchField(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if nil == query {
+			return nil, errors.New("query can not be nil")
+		}
+
+		item, err := query.BuildQuery(s)
+		if err != nil {
+			return nil, err
+		}
+		return &search.NestedQuery{Path: column, Query: item, ScoreMode: search.ScoreMode_Avg}, nil
+	})
+}
+
+// GeoDistance 和中心点的距离在distance米以内, 坐标的格式是 纬度,经度
+func GeoDistance(name string, center string, distance float64) Query {
+	return queryFunc(func(s *Schema) (search.Query, error) {
+		column, _, err := s.resolveSearchField(name)
+		if err != nil {
+			return nil, err
+		}
+		return &search.GeoDistanceQuery{FieldName: column, CenterPoint: center, DistanceInMeter: distance}, nil
+	})
+}
+
+func GeoBoundingBox(name string, topLeft string, bottomRight string) Query {
+	return queryFunc(func(s *Schema) (search.Query, error) {
+		column, _, err := s.resolveSearchField(name)
+		if err != nil {
+			return nil, err
+		}
+		return &search.GeoBoundingBoxQuery{FieldName: column, TopLeft: topLeft, BottomRight: bottomRight}, nil
+	})
+}
+
+func GeoPolygon(name string, points ...string) Query {
+	return queryFunc(func(s *Schema) (search.Query, error) {
+		column, _, err := s.resolveSearchField(name)
+		if err != nil {
+			return nil, err
+		}
+		return &search.GeoPolygonQuery{FieldName: column, Points: points}, nil
+	})
+}
+
+// Sorter 多元索引的排序
+type Sorter struct {
+	name       string
+	desc       bool
+	primaryKey bool
+	score      bool
+}
+
+func Asc(name string) *Sorter {
+	return &Sorter{name: name}
+}
+
+func Desc(name string) *Sorter {
+	return &Sorter{name: name, desc: true}
+}
+
+func SortByPrimaryKey(desc bool) *Sorter {
+	return &Sorter{primaryKey: true, desc: desc}
+}
+
+func SortByScore(desc bool) *Sorter {
+	return &Sorter{score: true, desc: desc}
+}
+
+func (s *Schema) BuildSort(sorters ...*Sorter) (*search.Sort, error) {
+	if 0 == len(sorters) {
+		return nil, nil
+	}
+
+	sort := &search.Sort{}
+	for _, sorter := range sorters {
+		order := search.SortOrder_ASC
+		if sorter.desc {
+			order = search.SortOrder_DESC
+		}
+
+		switch {
+		case sorter.primaryKey:
+			sort.Sorters = append(sort.Sorters, &search.PrimaryKeySort{Order: order.Enum()})
+		case sorter.score:
+			sort.Sorters = append(sort.Sorters, &search.ScoreSort{Order: order.Enum()})
+		default:
+			column, _, err := s.resolveSearchField(sorter.name)
+			if err != nil {
+				return nil, err
+			}
+			sort.Sorters = append(sort.Sorters, search.NewFieldSort(column, order))
+		}
+	}
+
+	return sort, nil
+}
+
+// BuildSoftDeleteQuery 排除已经软删除的行, 多元索引中需要包含软删除字段, 没有软删除字段的时候返回nil
+func (s *Schema) BuildSoftDeleteQuery() search.Query {
+	field := s.GetSoftDeleteField()
+	if nil == field {
+		return nil
+	}
+
+	deleted := &search.BoolQuery{
+		MustQueries:    []search.Query{&search.ExistsQuery{FieldName: field.DBName}},
+		MustNotQueries: []search.Query{&search.TermQuery{FieldName: field.DBName, Term: field.ZeroOtsValue()}},
+	}
+	return &search.BoolQuery{MustNotQueries: []search.Query{deleted}}
+}
@@ -0,0 +1,80 @@
+package schema_test
+
+import (
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore/search"
+	"github.com/hughcube-go/tablestore/schema"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestBuildQuery(t *testing.T) {
+	a := assert.New(t)
+
+	tableSchema, err := schema.Parse(&TestModel{}, nil)
+	a.Nil(err)
+
+	query, err := schema.Bool().
+		Must(schema.Term("ID", 1), schema.Range("id").Gt(1).Lte(10)).
+		Should(schema.Terms("ID", 1, 2), schema.Match("DeletedAt.text", "a b").MatchAllTerms()).
+		BuildQuery(tableSchema)
+	a.Nil(err)
+
+	boolQuery, ok := query.(*search.BoolQuery)
+	a.True(ok)
+	a.Len(boolQuery.MustQueries, 2)
+	a.Equal(&search.TermQuery{FieldName: "id", Term: int64(1)}, boolQuery.MustQueries[0])
+	a.Equal(&search.RangeQuery{FieldName: "id", From: int64(1), To: int64(10), IncludeUpper: true}, boolQuery.MustQueries[1])
+	a.Equal(&search.TermsQuery{FieldName: "id", Terms: []interface{}{int64(1), int64(2)}}, boolQuery.ShouldQueries[0])
+	a.Equal("deleted_at.text", boolQuery.ShouldQueries[1].(*search.MatchQuery).FieldName)
+
+	nested, err := schema.Nested("DeletedAt", schema.Wildcard("DeletedAt.name", "a*")).BuildQuery(tableSchema)
+	a.Nil(err)
+	a.Equal("deleted_at", nested.(*search.NestedQuery).Path)
+
+	sort, err := tableSchema.BuildSort(schema.Desc("ID"), schema.SortByPrimaryKey(false))
+	a.Nil(err)
+	a.Len(sort.Sorters, 2)
+	a.Equal("id", sort.Sorters[0].(*search.FieldSort).FieldName)
+
+	_, err = schema.Term("Unknown", 1).BuildQuery(tableSchema)
+	a.NotNil(err)
+
+	_, err = schema.Range("ID").BuildQuery(tableSchema)
+	a.NotNil(err)
+}
+
+func TestBuildAggregators(t *testing.T) {
+	a := assert.New(t)
+
+	tableSchema, err := schema.Parse(&TestModel{}, nil)
+	a.Nil(err)
+
+	aggregations, groupBys, err := tableSchema.BuildAggregators(
+		schema.Avg("avg_id", "ID").Missing(0),
+		schema.Count("count", "id"),
+		schema.GroupByField("by_id", "ID").Size(10).SortByRowCount(true).SubAggregations(schema.Max("max_id", "ID")),
+		schema.GroupByRange("range_id", "ID").Range(0, 10).Range(10, 20),
+		schema.GroupByFilter("filter_id", schema.Term("ID", 1), schema.Range("ID").Gt(1)),
+	)
+	a.Nil(err)
+	a.Len(aggregations, 2)
+	a.Equal(&search.AvgAggregation{AggName: "avg_id", Field: "id", MissingValue: int64(0)}, aggregations[0])
+	a.Equal("id", aggregations[1].(*search.CountAggregation).Field)
+
+	a.Len(groupBys, 3)
+	byField := groupBys[0].(*search.GroupByField)
+	a.Equal("id", byField.Field)
+	a.Equal(int32(10), *byField.Sz)
+	a.Equal("id", byField.SubAggList[0].(*search.MaxAggregation).Field)
+	a.Len(groupBys[1].(*search.GroupByRange).RangeList, 2)
+	a.Len(groupBys[2].(*search.GroupByFilter).Queries, 2)
+
+	_, _, err = tableSchema.BuildAggregators(schema.Sum("sum", "Unknown"))
+	a.NotNil(err)
+
+	_, _, err = tableSchema.BuildAggregators(schema.Sum("sum", "ID"), schema.GroupByField("sum", "ID"))
+	a.NotNil(err)
+
+	_, _, err = tableSchema.BuildAggregators(schema.GroupByRange("range_id", "ID"))
+	a.NotNil(err)
+}
@@ -0,0 +1,195 @@
+package schema
+
+import (
+	"fmt"
+	aliTableStore "github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"reflect"
+	"sort"
+)
+
+// TableOptions 建表和迁移时使用的表属性
+type TableOptions struct {
+	// 数据的过期时间, 单位秒, -1表示永不过期
+	TimeToAlive int
+	MaxVersions int
+
+	// 预留读写吞吐量
+	ReservedRead  int
+	ReservedWrite int
+}
+
+func DefaultTableOptions() TableOptions {
+	return TableOptions{TimeToAlive: -1, MaxVersions: 1}
+}
+
+// TableOptioner 模型实现之后, 建表和迁移时使用模型返回的表属性
+type TableOptioner interface {
+	TableOptions() TableOptions
+}
+
+func GetTableOptions(row Tabler) TableOptions {
+	if optioner, ok := row.(TableOptioner); ok {
+		return optioner.TableOptions()
+	}
+	return DefaultTableOptions()
+}
+
+func (f *Field) PrimaryKeyType() (aliTableStore.PrimaryKeyType, error) {
+	switch f.OtsKind() {
+	case reflect.Int64:
+		return aliTableStore.PrimaryKeyType_INTEGER, nil
+	case reflect.String:
+		return aliTableStore.PrimaryKeyType_STRING, nil
+	case reflect.Slice:
+		return aliTableStore.PrimaryKeyType_BINARY, nil
+	}
+
+	return 0, fmt.Errorf("field %s of type %s can not be a primary key", f.Name, f.Type)
+}
+
+func (f *Field) DefinedColumnType() (aliTableStore.DefinedColumnType, error) {
+	switch f.OtsKind() {
+	case reflect.Int64:
+		return aliTableStore.DefinedColumn_INTEGER, nil
+	case reflect.Float64:
+		return aliTableStore.DefinedColumn_DOUBLE, nil
+	case reflect.Bool:
+		return aliTableStore.DefinedColumn_BOOLEAN, nil
+	case reflect.String:
+		return aliTableStore.DefinedColumn_STRING, nil
+	case reflect.Slice:
+		return aliTableStore.DefinedColumn_BINARY, nil
+	}
+
+	return 0, fmt.Errorf("field %s of type %s can not be a defined column", f.Name, f.Type)
+}
+
+// PrimaryKeyFields 按照sort排序的主键字段, sort相同的时候按照声明的顺序, 建表和读写请求中的主键列都使用这个顺序
+func (s *Schema) PrimaryKeyFields() []*Field {
+	fields := []*Field{}
+	for _, field := range s.Fields {
+		if field.IsPrimaryKey {
+			fields = append(fields, field)
+		}
+	}
+
+	sort.SliceStable(fields, func(i, j int) bool {
+		if fields[i].Sort != fields[j].Sort {
+			return fields[i].Sort < fields[j].Sort
+		}
+		if fields[i].ValueLevel != fields[j].ValueLevel {
+			return fields[i].ValueLevel < fields[j].ValueLevel
+		}
+		return fields[i].StructField.Index[0] < fields[j].StructField.Index[0]
+	})
+
+	return fields
+}
+
+// BuildPartitionKey 只包含分区键的主键, 分区键是第一个主键列, 用于开启局部事务
+func (s *Schema) BuildPartitionKey(row Tabler) (*aliTableStore.PrimaryKey, error) {
+	fields := s.PrimaryKeyFields()
+	if 0 == len(fields) {
+		return nil, fmt.Errorf("%s has no primary key", s.Name)
+	}
+
+	primaryKey := new(aliTableStore.PrimaryKey)
+	err := s.EachSetRequestColumn(row, func(field *Field, value interface{}) {
+		if field == fields[0] {
+			primaryKey.AddPrimaryKeyColumn(field.DBName, value)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return primaryKey, nil
+}
+
+// DefinedColumnFields 需要在表中预定义的属性列
+func (s *Schema) DefinedColumnFields() []*Field {
+	fields := []*Field{}
+	for _, field := range s.Fields {
+		if field.IsDefinedColumn && !field.IsPrimaryKey {
+			fields = append(fields, field)
+		}
+	}
+
+	sort.SliceStable(fields, func(i, j int) bool {
+		return fields[i].DBName < fields[j].DBName
+	})
+
+	return fields
+}
+
+func (s *Schema) BuildTableMeta(tableName string) (*aliTableStore.TableMeta, error) {
+	meta := new(aliTableStore.TableMeta)
+	meta.TableName = tableName
+
+	for _, field := range s.PrimaryKeyFields() {
+		keyType, err := field.PrimaryKeyType()
+		if err != nil {
+			return nil, err
+		}
+
+		if field.IsAutoIncrement {
+			meta.AddPrimaryKeyColumnOption(field.DBName, keyType, aliTableStore.AUTO_INCREMENT)
+		} else {
+			meta.AddPrimaryKeyColumn(field.DBName, keyType)
+		}
+	}
+
+	if 0 == len(meta.SchemaEntry) {
+		return nil, fmt.Errorf("%s has no primary key", s.Name)
+	}
+
+	for _, field := range s.DefinedColumnFields() {
+		columnType, err := field.DefinedColumnType()
+		if err != nil {
+			return nil, err
+		}
+		meta.AddDefinedColumn(field.DBName, columnType)
+	}
+
+	return meta, nil
+}
+
+// BuildCreateTableRequest 根据模型的字段和表属性生成建表请求
+func (s *Schema) BuildCreateTableRequest(row Tabler) (*aliTableStore.CreateTableRequest, error) {
+	meta, err := s.BuildTableMeta(row.TableName())
+	if err != nil {
+		return nil, err
+	}
+
+	// Indexer中使用的属性列需要预定义
+	for _, index := range s.GetIndexes(row) {
+		for _, name := range append(append([]string{}, index.PrimaryKey...), index.DefinedColumns...) {
+			field, err := s.lookupField(name)
+			if err != nil {
+				return nil, err
+			}
+
+			defined := field.IsPrimaryKey
+			for _, column := range meta.DefinedColumns {
+				defined = defined || column.Name == field.DBName
+			}
+			if defined {
+				continue
+			}
+
+			columnType, err := field.DefinedColumnType()
+			if err != nil {
+				return nil, err
+			}
+			meta.AddDefinedColumn(field.DBName, columnType)
+		}
+	}
+
+	options := GetTableOptions(row)
+
+	request := new(aliTableStore.CreateTableRequest)
+	request.TableMeta = meta
+	request.TableOption = &aliTableStore.TableOption{TimeToAlive: options.TimeToAlive, MaxVersion: options.MaxVersions}
+	request.ReservedThroughput = &aliTableStore.ReservedThroughput{Readcap: options.ReservedRead, Writecap: options.ReservedWrite}
+
+	return request, nil
+}
@@ -29,7 +29,13 @@ func ToTablerSlice(list interface{}, usedModify bool) ([]Tabler, error) {
 
 	rows := []Tabler{}
 	for i := 0; i < listValue.Len(); i++ {
-		if usedModify && listValue.Index(i).Elem().Kind() != reflect.Ptr {
+		// []interface{} 的元素需要取出实际的值
+		elemValue := listValue.Index(i)
+		if elemValue.Kind() == reflect.Interface {
+			elemValue = elemValue.Elem()
+		}
+
+		if usedModify && elemValue.Kind() != reflect.Ptr {
 			return nil, CannotConvertTablerPointerSlice
 		}
 
@@ -42,3 +48,9 @@ func ToTablerSlice(list interface{}, usedModify bool) ([]Tabler, error) {
 
 	return rows, nil
 }
+
+// TablerPointer 约束指针类型实现Tabler, 用于泛型中从结构体类型推导出指针类型
+type TablerPointer[T any] interface {
+	*T
+	Tabler
+}
@@ -0,0 +1,111 @@
+package schema
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// DeleteColumnValue 更新时删除列, Timestamp为0的时候删除所有的版本, 否则只删除指定的版本
+type DeleteColumnValue struct {
+	Timestamp int64
+}
+
+// DeleteColumn 更新时删除列的所有版本, map中的nil会被忽略, 需要删除的时候使用DeleteColumn
+//
+//	client.UpdateOne(row, map[string]interface{}{"Nickname": schema.DeleteColumn})
+var DeleteColumn = DeleteColumnValue{}
+
+// DeleteColumnVersion 更新时只删除列的指定版本, timestamp是毫秒级的版本号
+func DeleteColumnVersion(timestamp int64) DeleteColumnValue {
+	return DeleteColumnValue{Timestamp: timestamp}
+}
+
+// 空指针字段和nullable字段的null值在更新时删除对应的列, 其他的转换成表格存储中的值
+func fieldUpdateValue(field *Field, fieldValue reflect.Value) (interface{}, error) {
+	for value := fieldValue; value.Kind() == reflect.Ptr; value = value.Elem() {
+		if value.IsNil() {
+			return DeleteColumn, nil
+		}
+	}
+
+	if field.IsNullable && field.IsNullValue(fieldValue.Interface()) {
+		return DeleteColumn, nil
+	}
+	return field.ToOtsValue(fieldValue.Interface())
+}
+
+// BuildFieldColumns 从结构体中取出更新的列, 结果可以直接传给UpdateOne, 空指针字段删除对应的列,
+// names为空的时候使用所有的属性列, 但是跳过版本字段, autoUpdateTime字段和零值的软删除字段, 由UpdateOne处理
+func (s *Schema) BuildFieldColumns(row Tabler, names ...string) (map[string]interface{}, error) {
+	selected := map[*Field]bool{}
+	for _, name := range names {
+		field, err := s.lookupField(name)
+		if err != nil {
+			return nil, err
+		}
+		if field.IsPrimaryKey {
+			return nil, fmt.Errorf("primary key %s can not be updated", name)
+		}
+		selected[field] = true
+	}
+
+	var err error
+	columns := map[string]interface{}{}
+	s.eachField(row, func(field *Field, fieldValue reflect.Value) {
+		if err != nil || field.IsPrimaryKey || (0 < len(selected) && !selected[field]) {
+			return
+		}
+
+		if 0 == len(selected) && (field.IsVersion || field.AutoUpdateTime || (field.IsSoftDelete && field.IsZeroValue(fieldValue.Interface()))) {
+			return
+		}
+
+		columns[field.DBName], err = fieldUpdateValue(field, fieldValue)
+	}, 0)
+
+	if err != nil {
+		return nil, err
+	}
+	return columns, nil
+}
+
+// ChangedFields 对比同一个模型的两个结构体, 返回值不同的字段名, 主键不同的时候返回错误
+func (s *Schema) ChangedFields(original Tabler, modified Tabler) ([]string, error) {
+	if reflect.TypeOf(original) != reflect.TypeOf(modified) {
+		return nil, fmt.Errorf("can not compare %T with %T", original, modified)
+	}
+
+	var err error
+	values := map[*Field]interface{}{}
+	s.eachField(original, func(field *Field, fieldValue reflect.Value) {
+		if err == nil {
+			values[field], err = fieldUpdateValue(field, fieldValue)
+		}
+	}, 0)
+
+	names := []string{}
+	s.eachField(modified, func(field *Field, fieldValue reflect.Value) {
+		if err != nil {
+			return
+		}
+
+		value, convertErr := fieldUpdateValue(field, fieldValue)
+		if convertErr != nil {
+			err = convertErr
+			return
+		}
+		if reflect.DeepEqual(values[field], value) {
+			return
+		}
+
+		if field.IsPrimaryKey {
+			err = fmt.Errorf("primary key %s is changed", field.Name)
+		}
+		names = append(names, field.Name)
+	}, 0)
+
+	if err != nil {
+		return nil, err
+	}
+	return names, nil
+}
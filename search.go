@@ -0,0 +1,238 @@
+package tablestore
+
+import (
+	"context"
+	aliTableStore "github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/aliyun/aliyun-tablestore-go-sdk/tablestore/search"
+	"github.com/hughcube-go/tablestore/schema"
+	"github.com/hughcube-go/utils/msslice"
+	"reflect"
+)
+
+type searchConfig struct {
+	sorters    []*schema.Sorter
+	offset     int32
+	limit      int32
+	token      []byte
+	totalCount bool
+	columns    []string
+	options    []func(*aliTableStore.SearchRequest)
+
+	// 统计聚合和分组, 由Aggregate设置
+	aggregators []schema.Aggregator
+}
+
+type SearchOption func(*searchConfig)
+
+func WithSearchSort(sorters ...*schema.Sorter) SearchOption {
+	return func(config *searchConfig) {
+		config.sorters = append(config.sorters, sorters...)
+	}
+}
+
+func WithSearchOffset(offset int) SearchOption {
+	return func(config *searchConfig) {
+		config.offset = int32(offset)
+	}
+}
+
+func WithSearchLimit(limit int) SearchOption {
+	return func(config *searchConfig) {
+		config.limit = int32(limit)
+	}
+}
+
+// WithSearchToken 使用上一次返回的NextToken翻页, 翻页时不能修改排序
+func WithSearchToken(token []byte) SearchOption {
+	return func(config *searchConfig) {
+		config.token = token
+	}
+}
+
+func WithSearchTotalCount() SearchOption {
+	return func(config *searchConfig) {
+		config.totalCount = true
+	}
+}
+
+// WithSearchColumns 只返回指定的列, 默认返回所有的列
+func WithSearchColumns(names ...string) SearchOption {
+	return func(config *searchConfig) {
+		config.columns = append(config.columns, names...)
+	}
+}
+
+// WithSearchRequest 在请求发出之前修改sdk的请求
+func WithSearchRequest(option func(*aliTableStore.SearchRequest)) SearchOption {
+	return func(config *searchConfig) {
+		config.options = append(config.options, option)
+	}
+}
+
+// WithSearchIndexWithoutSoftDelete 指定没有索引软删除字段的多元索引, 查询这些索引时不排除软删除的行
+func WithSearchIndexWithoutSoftDelete(indexNames ...string) ClientOption {
+	return func(t *TableStore) {
+		indexes := map[string]bool{}
+		for name := range t.searchIndexesWithoutSoftDelete {
+			indexes[name] = true
+		}
+		for _, name := range indexNames {
+			indexes[name] = true
+		}
+		t.searchIndexesWithoutSoftDelete = indexes
+	}
+}
+
+type SearchResponse struct {
+	Error      error
+	Response   *aliTableStore.SearchResponse
+	TotalCount int64
+	NextToken  []byte
+	HasNext    bool
+	RowCount   int
+
+	// 发起的Search请求数, 包含重试
+	Attempts int
+
+	// Search请求消耗的CU
+	CapacityUnit CapacityUnit
+}
+
+// Search 使用多元索引查询, 结果按照模型填充到list, list是模型指针的slice的指针,
+// 模型有软删除字段的时候会排除已经软删除的行, 多元索引中必须包含软删除字段, 否则服务端返回错误,
+// 没有包含的索引使用WithSearchIndexWithoutSoftDelete声明, 或者使用Unscoped查询
+//
+//	var list []*Model
+//	client.Search(&list, "model_index", schema.Bool().Must(schema.Term("Status", 1)), tablestore.WithSearchSort(schema.Desc("CreatedAt")))
+func (t *TableStore) Search(list interface{}, indexName string, query schema.Query, options ...SearchOption) SearchResponse {
+	return t.SearchCtx(context.Background(), list, indexName, query, options...)
+}
+
+func (t *TableStore) SearchCtx(ctx context.Context, list interface{}, indexName string, query schema.Query, options ...SearchOption) (result SearchResponse) {
+	ctx, op := t.startOperation(ctx, "Search")
+	defer func() { result.Attempts, result.CapacityUnit = op.end(result.Error) }()
+
+	listValue := reflect.ValueOf(list)
+	if listValue.Kind() != reflect.Ptr {
+		return SearchResponse{Error: schema.CannotConvertTablerPointerSlice}
+	}
+
+	rowType, err := msslice.GetElemType(list, true)
+	if err != nil {
+		return SearchResponse{Error: schema.CannotConvertTablerPointerSlice}
+	}
+
+	dest, ok := reflect.New(rowType).Interface().(schema.Tabler)
+	if !ok {
+		return SearchResponse{Error: schema.CannotConvertTablerPointerSlice}
+	}
+
+	tableSchema, err := t.ParseSchema(dest)
+	if err != nil {
+		return SearchResponse{Error: err}
+	}
+
+	config := &searchConfig{offset: -1, limit: -1}
+	for _, option := range options {
+		option(config)
+	}
+
+	request, err := t.buildSearchRequest(tableSchema, dest.TableName(), indexName, query, config)
+	if err != nil {
+		return SearchResponse{Error: err}
+	}
+
+	response, err := t.search(ctx, request)
+	if err != nil {
+		return SearchResponse{Error: err, Response: response}
+	}
+
+	resultSlice, _ := msslice.MakeSameTypeValue(list, len(response.Rows), len(response.Rows))
+	for index, searchRow := range response.Rows {
+		row := reflect.New(rowType).Interface()
+		var primaryKeys []*aliTableStore.PrimaryKeyColumn
+		if nil != searchRow.PrimaryKey {
+			primaryKeys = searchRow.PrimaryKey.PrimaryKeys
+		}
+		if err = tableSchema.FillRow(row, primaryKeys, searchRow.Columns); err != nil {
+			return SearchResponse{Error: err, Response: response}
+		}
+		resultSlice.Index(index).Set(reflect.ValueOf(row))
+	}
+	listValue.Elem().Set(resultSlice)
+
+	return SearchResponse{
+		Response:   response,
+		TotalCount: response.TotalCount,
+		NextToken:  response.NextToken,
+		HasNext:    0 < len(response.NextToken),
+		RowCount:   resultSlice.Len(),
+	}
+}
+
+// 排除软删除的行的查询, Unscoped或者索引没有软删除字段的时候返回nil
+func (t *TableStore) buildSearchSoftDeleteQuery(tableSchema *schema.Schema, indexName string) search.Query {
+	if t.unscoped || t.searchIndexesWithoutSoftDelete[indexName] {
+		return nil
+	}
+	return tableSchema.BuildSoftDeleteQuery()
+}
+
+func (t *TableStore) buildSearchRequest(tableSchema *schema.Schema, tableName string, indexName string, query schema.Query, config *searchConfig) (*aliTableStore.SearchRequest, error) {
+	if nil == query {
+		query = schema.MatchAll()
+	}
+
+	searchQuery, err := query.BuildQuery(tableSchema)
+	if err != nil {
+		return nil, err
+	}
+
+	if softDeleteQuery := t.buildSearchSoftDeleteQuery(tableSchema, indexName); nil != softDeleteQuery {
+		searchQuery = &search.BoolQuery{MustQueries: []search.Query{searchQuery}, FilterQueries: []search.Query{softDeleteQuery}}
+	}
+
+	sort, err := tableSchema.BuildSort(config.sorters...)
+	if err != nil {
+		return nil, err
+	}
+
+	builder := search.NewSearchQuery().SetQuery(searchQuery).SetOffset(config.offset).SetLimit(config.limit).SetGetTotalCount(config.totalCount)
+	if nil != sort && nil == config.token {
+		builder.SetSort(sort)
+	}
+	if nil != config.token {
+		builder.SetToken(config.token)
+	}
+
+	aggregations, groupBys, err := tableSchema.BuildAggregators(config.aggregators...)
+	if err != nil {
+		return nil, err
+	}
+	if 0 < len(aggregations) {
+		builder.Aggregation(aggregations...)
+	}
+	if 0 < len(groupBys) {
+		builder.GroupBy(groupBys...)
+	}
+
+	columnsToGet := &aliTableStore.ColumnsToGet{ReturnAll: 0 == len(config.columns)}
+	for _, name := range config.columns {
+		if field, ok := tableSchema.FieldMap[name]; ok {
+			name = field.DBName
+		}
+		columnsToGet.Columns = append(columnsToGet.Columns, name)
+	}
+
+	request := new(aliTableStore.SearchRequest)
+	request.SetTableName(tableName)
+	request.SetIndexName(indexName)
+	request.SetSearchQuery(builder)
+	request.SetColumnsToGet(columnsToGet)
+
+	for _, option := range config.options {
+		option(request)
+	}
+
+	return request, nil
+}
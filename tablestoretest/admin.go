@@ -0,0 +1,289 @@
+package tablestoretest
+
+import (
+	"fmt"
+	aliTableStore "github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"net/http"
+)
+
+const (
+	ObjectNotExist     = "OTSObjectNotExist"
+	ObjectAlreadyExist = "OTSObjectAlreadyExist"
+)
+
+func newObjectNotExistError(tableName string) *aliTableStore.OtsError {
+	return newError(ObjectNotExist, fmt.Sprintf("Requested table %s does not exist.", tableName), http.StatusNotFound)
+}
+
+// 通过CreateTable创建的表, 写入时自动创建的表视为不存在
+func (f *Fake) describedTable(tableName string) *table {
+	if current, ok := f.tables[tableName]; ok && nil != current.meta {
+		return current
+	}
+	return nil
+}
+
+func (f *Fake) CreateTable(request *aliTableStore.CreateTableRequest) (*aliTableStore.CreateTableResponse, error) {
+	if nil == request {
+		return nil, newEmptyRequestError()
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.call("CreateTable")
+
+	if nil == request.TableMeta || 0 == len(request.TableMeta.SchemaEntry) {
+		return &aliTableStore.CreateTableResponse{}, newParameterInvalidError("The number of primary key columns must be in range: [1, 4].")
+	}
+
+	tableName := request.TableMeta.TableName
+	if err := f.checkFault("CreateTable", tableName, nil); err != nil {
+		return &aliTableStore.CreateTableResponse{}, err
+	}
+
+	if nil != f.describedTable(tableName) {
+		return &aliTableStore.CreateTableResponse{}, newError(ObjectAlreadyExist, fmt.Sprintf("Requested table %s already exists.", tableName), http.StatusConflict)
+	}
+
+	current := f.table(tableName)
+	current.meta = copyTableMeta(request.TableMeta)
+	current.option = aliTableStore.TableOption{TimeToAlive: -1, MaxVersion: 1}
+	if nil != request.TableOption {
+		current.option = *request.TableOption
+	}
+	current.throughput = aliTableStore.ReservedThroughput{}
+	if nil != request.ReservedThroughput {
+		current.throughput = *request.ReservedThroughput
+	}
+
+	return &aliTableStore.CreateTableResponse{ResponseInfo: aliTableStore.ResponseInfo{RequestId: newRequestId()}}, nil
+}
+
+func (f *Fake) DescribeTable(request *aliTableStore.DescribeTableRequest) (*aliTableStore.DescribeTableResponse, error) {
+	if nil == request {
+		return nil, newEmptyRequestError()
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.call("DescribeTable")
+
+	if err := f.checkFault("DescribeTable", request.TableName, nil); err != nil {
+		return &aliTableStore.DescribeTableResponse{}, err
+	}
+
+	current := f.describedTable(request.TableName)
+	if nil == current {
+		return &aliTableStore.DescribeTableResponse{}, newObjectNotExistError(request.TableName)
+	}
+
+	option, throughput := current.option, current.throughput
+	response := &aliTableStore.DescribeTableResponse{
+		TableMeta:          copyTableMeta(current.meta),
+		TableOption:        &option,
+		ReservedThroughput: &throughput,
+		ResponseInfo:       aliTableStore.ResponseInfo{RequestId: newRequestId()},
+	}
+	for _, index := range current.indexes {
+		response.IndexMetas = append(response.IndexMetas, copyIndexMeta(index))
+	}
+
+	return response, nil
+}
+
+func (f *Fake) UpdateTable(request *aliTableStore.UpdateTableRequest) (*aliTableStore.UpdateTableResponse, error) {
+	if nil == request {
+		return nil, newEmptyRequestError()
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.call("UpdateTable")
+
+	if err := f.checkFault("UpdateTable", request.TableName, nil); err != nil {
+		return &aliTableStore.UpdateTableResponse{}, err
+	}
+
+	current := f.describedTable(request.TableName)
+	if nil == current {
+		return &aliTableStore.UpdateTableResponse{}, newObjectNotExistError(request.TableName)
+	}
+
+	if nil != request.TableOption {
+		current.option = *request.TableOption
+	}
+	if nil != request.ReservedThroughput {
+		current.throughput = *request.ReservedThroughput
+	}
+
+	option, throughput := current.option, current.throughput
+	return &aliTableStore.UpdateTableResponse{
+		TableOption:        &option,
+		ReservedThroughput: &throughput,
+		ResponseInfo:       aliTableStore.ResponseInfo{RequestId: newRequestId()},
+	}, nil
+}
+
+func (f *Fake) AddDefinedColumn(request *aliTableStore.AddDefinedColumnRequest) (*aliTableStore.AddDefinedColumnResponse, error) {
+	if nil == request {
+		return nil, newEmptyRequestError()
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.call("AddDefinedColumn")
+
+	if err := f.checkFault("AddDefinedColumn", request.TableName, nil); err != nil {
+		return &aliTableStore.AddDefinedColumnResponse{}, err
+	}
+
+	current := f.describedTable(request.TableName)
+	if nil == current {
+		return &aliTableStore.AddDefinedColumnResponse{}, newObjectNotExistError(request.TableName)
+	}
+
+	for _, column := range request.DefinedColumns {
+		for _, exist := range current.meta.DefinedColumns {
+			if exist.Name == column.Name {
+				return &aliTableStore.AddDefinedColumnResponse{}, newParameterInvalidError(fmt.Sprintf("Defined column %s already exists.", column.Name))
+			}
+		}
+	}
+
+	for _, column := range request.DefinedColumns {
+		current.meta.AddDefinedColumn(column.Name, column.ColumnType)
+	}
+
+	return &aliTableStore.AddDefinedColumnResponse{ResponseInfo: aliTableStore.ResponseInfo{RequestId: newRequestId()}}, nil
+}
+
+func (f *Fake) CreateIndex(request *aliTableStore.CreateIndexRequest) (*aliTableStore.CreateIndexResponse, error) {
+	if nil == request {
+		return nil, newEmptyRequestError()
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.call("CreateIndex")
+
+	if err := f.checkFault("CreateIndex", request.MainTableName, nil); err != nil {
+		return nil, err
+	}
+
+	current := f.describedTable(request.MainTableName)
+	if nil == current {
+		return nil, newObjectNotExistError(request.MainTableName)
+	}
+
+	if nil == request.IndexMeta || 0 == len(request.IndexMeta.Primarykey) {
+		return nil, newParameterInvalidError("The index primary key is empty.")
+	}
+
+	if nil != f.describedTable(request.IndexMeta.IndexName) || nil != f.indexOf(request.IndexMeta.IndexName) {
+		return nil, newError(ObjectAlreadyExist, fmt.Sprintf("Requested index %s already exists.", request.IndexMeta.IndexName), http.StatusConflict)
+	}
+
+	// 索引的列需要是主键列或者预定义列
+	for _, name := range append(append([]string{}, request.IndexMeta.Primarykey...), request.IndexMeta.DefinedColumns...) {
+		found := false
+		for _, entry := range current.meta.SchemaEntry {
+			found = found || *entry.Name == name
+		}
+		for _, column := range current.meta.DefinedColumns {
+			found = found || column.Name == name
+		}
+		if !found {
+			return nil, newParameterInvalidError(fmt.Sprintf("Column %s is not a primary key or defined column.", name))
+		}
+	}
+
+	current.indexes = append(current.indexes, copyIndexMeta(request.IndexMeta))
+
+	return &aliTableStore.CreateIndexResponse{ResponseInfo: aliTableStore.ResponseInfo{RequestId: newRequestId()}}, nil
+}
+
+type fakeIndex struct {
+	meta  *aliTableStore.IndexMeta
+	table *table
+}
+
+func (f *Fake) indexOf(name string) *fakeIndex {
+	for _, current := range f.tables {
+		for _, index := range current.indexes {
+			if index.IndexName == name {
+				return &fakeIndex{meta: index, table: current}
+			}
+		}
+	}
+	return nil
+}
+
+// 范围查询的行, 索引表的数据根据主表实时生成, 缺少索引主键列的行不在索引中
+func (f *Fake) rangeRows(tableName string) []*row {
+	index := f.indexOf(tableName)
+	if nil == index {
+		return f.table(tableName).rows
+	}
+
+	primaryKeyNames := append([]string{}, index.meta.Primarykey...)
+	for _, entry := range index.table.meta.SchemaEntry {
+		if !containsString(primaryKeyNames, *entry.Name) {
+			primaryKeyNames = append(primaryKeyNames, *entry.Name)
+		}
+	}
+
+	indexTable := &table{}
+	for _, current := range index.table.rows {
+		values := map[string]interface{}{}
+		for _, primaryKey := range current.primaryKeys {
+			values[primaryKey.ColumnName] = primaryKey.Value
+		}
+		for name, column := range current.columns {
+			values[name] = column.Value
+		}
+
+		indexRow := &row{columns: map[string]*aliTableStore.AttributeColumn{}}
+		for _, name := range primaryKeyNames {
+			if value, ok := values[name]; ok {
+				indexRow.primaryKeys = append(indexRow.primaryKeys, &aliTableStore.PrimaryKeyColumn{ColumnName: name, Value: value})
+			}
+		}
+		if len(indexRow.primaryKeys) != len(primaryKeyNames) {
+			continue
+		}
+
+		for _, name := range index.meta.DefinedColumns {
+			if column, ok := current.columns[name]; ok {
+				copied := *column
+				indexRow.columns[name] = &copied
+			}
+		}
+		indexTable.set(indexRow)
+	}
+
+	return indexTable.rows
+}
+
+func copyIndexMeta(meta *aliTableStore.IndexMeta) *aliTableStore.IndexMeta {
+	return &aliTableStore.IndexMeta{
+		IndexName:      meta.IndexName,
+		Primarykey:     append([]string{}, meta.Primarykey...),
+		DefinedColumns: append([]string{}, meta.DefinedColumns...),
+		IndexType:      meta.IndexType,
+	}
+}
+
+func copyTableMeta(meta *aliTableStore.TableMeta) *aliTableStore.TableMeta {
+	result := &aliTableStore.TableMeta{TableName: meta.TableName}
+	for _, entry := range meta.SchemaEntry {
+		if nil != entry.Option {
+			result.AddPrimaryKeyColumnOption(*entry.Name, *entry.Type, *entry.Option)
+		} else {
+			result.AddPrimaryKeyColumn(*entry.Name, *entry.Type)
+		}
+	}
+	for _, column := range meta.DefinedColumns {
+		result.AddDefinedColumn(column.Name, column.ColumnType)
+	}
+	return result
+}
@@ -0,0 +1,586 @@
+package tablestoretest
+
+import (
+	"fmt"
+	aliTableStore "github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	ConditionCheckFail = "OTSConditionCheckFail"
+	ParameterInvalid   = "OTSParameterInvalid"
+
+	// 服务端对单次请求的限制
+	MaxBatchGetRowCount   = 100
+	MaxBatchWriteRowCount = 200
+	MaxGetRangeRowCount   = 5000
+)
+
+var requestSequence int64
+
+func newRequestId() string {
+	return fmt.Sprintf("fake-%016d", atomic.AddInt64(&requestSequence, 1))
+}
+
+// NewError 构造一个服务端返回的错误, 可以在Fault中使用
+func NewError(code string, message string, httpStatusCode int) *aliTableStore.OtsError {
+	return newError(code, message, httpStatusCode)
+}
+
+func newError(code string, message string, httpStatusCode int) *aliTableStore.OtsError {
+	return &aliTableStore.OtsError{Code: code, Message: message, RequestId: newRequestId(), HttpStatusCode: httpStatusCode}
+}
+
+func newParameterInvalidError(message string) *aliTableStore.OtsError {
+	return newError(ParameterInvalid, message, http.StatusBadRequest)
+}
+
+func newEmptyRequestError() *aliTableStore.OtsError {
+	return newParameterInvalidError("The request is empty.")
+}
+
+func newConditionCheckFailError() *aliTableStore.OtsError {
+	return newError(ConditionCheckFail, "Condition check failed.", http.StatusForbidden)
+}
+
+// 批量操作中单行的错误
+func toRowError(err error) aliTableStore.Error {
+	if otsError, ok := err.(*aliTableStore.OtsError); ok {
+		return aliTableStore.Error{Code: otsError.Code, Message: otsError.Message}
+	}
+	return aliTableStore.Error{Code: aliTableStore.OTS_CLIENT_UNKNOWN, Message: err.Error()}
+}
+
+// Fake 是一个进程内的表格存储, 实现了tablestore.Api, 用于脱离真实实例的测试
+//
+//	client := tablestore.New("", "", "", "", tablestore.WithApi(tablestoretest.New()))
+type Fake struct {
+	mu           sync.Mutex
+	tables       map[string]*table
+	calls        map[string]int
+	fault        Fault
+	transactions map[string]*transaction
+}
+
+// Fault 用于模拟失败, 返回非nil的时候对应的操作失败, 批量操作中每一行单独调用, 表管理和结束事务的操作primaryKey为nil
+type Fault func(operation string, tableName string, primaryKey *aliTableStore.PrimaryKey) error
+
+func New() *Fake {
+	return &Fake{tables: map[string]*table{}, calls: map[string]int{}, transactions: map[string]*transaction{}}
+}
+
+// SetFault 设置模拟失败的方法, nil表示不再模拟
+func (f *Fake) SetFault(fault Fault) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.fault = fault
+}
+
+// Calls 返回sdk方法被调用的次数, operation是方法名, 比如 BatchWriteRow
+func (f *Fake) Calls(operation string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.calls[operation]
+}
+
+// 调用计数, 调用时需要持有锁
+func (f *Fake) call(operation string) {
+	f.calls[operation]++
+}
+
+func (f *Fake) checkFault(operation string, tableName string, primaryKey *aliTableStore.PrimaryKey) error {
+	if nil == f.fault {
+		return nil
+	}
+	return f.fault(operation, tableName, primaryKey)
+}
+
+// 表不存在时自动创建
+func (f *Fake) table(name string) *table {
+	if _, ok := f.tables[name]; !ok {
+		f.tables[name] = &table{}
+	}
+	return f.tables[name]
+}
+
+// RowCount 返回表中的行数
+func (f *Fake) RowCount(tableName string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return len(f.table(tableName).rows)
+}
+
+// Reset 清空所有的表
+func (f *Fake) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.tables = map[string]*table{}
+	f.calls = map[string]int{}
+	f.transactions = map[string]*transaction{}
+}
+
+func (f *Fake) checkCondition(condition *aliTableStore.RowCondition, current *row) error {
+	if nil == condition {
+		return nil
+	}
+
+	if aliTableStore.RowExistenceExpectation_EXPECT_EXIST == condition.RowExistenceExpectation && nil == current {
+		return newConditionCheckFailError()
+	}
+
+	if aliTableStore.RowExistenceExpectation_EXPECT_NOT_EXIST == condition.RowExistenceExpectation && nil != current {
+		return newConditionCheckFailError()
+	}
+
+	if !matchFilter(condition.ColumnCondition, current) {
+		return newConditionCheckFailError()
+	}
+
+	return nil
+}
+
+func (f *Fake) checkPrimaryKey(primaryKey *aliTableStore.PrimaryKey, allowAutoIncrement bool) error {
+	if nil == primaryKey || 0 == len(primaryKey.PrimaryKeys) {
+		return newParameterInvalidError("The primary key is empty.")
+	}
+
+	for _, column := range primaryKey.PrimaryKeys {
+		if allowAutoIncrement && aliTableStore.AUTO_INCREMENT == column.PrimaryKeyOption {
+			continue
+		}
+
+		if aliTableStore.NONE != column.PrimaryKeyOption {
+			return newParameterInvalidError(fmt.Sprintf("Invalid primary key option of %s.", column.ColumnName))
+		}
+
+		if err := checkPrimaryKeyValue(column); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (f *Fake) putRow(change *aliTableStore.PutRowChange) (*aliTableStore.PrimaryKey, error) {
+	if err := f.checkPrimaryKey(change.PrimaryKey, true); err != nil {
+		return nil, err
+	}
+
+	for _, column := range change.Columns {
+		if err := checkColumnValue(column.ColumnName, column.Value); err != nil {
+			return nil, err
+		}
+	}
+
+	tableStore, err := f.transactionTable(change.TableName, change.TransactionId, change.PrimaryKey.PrimaryKeys, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// 自增列由服务端生成
+	primaryKeys := copyPrimaryKeyColumns(change.PrimaryKey.PrimaryKeys)
+	for _, column := range primaryKeys {
+		if aliTableStore.AUTO_INCREMENT == column.PrimaryKeyOption {
+			tableStore.autoIncrement++
+			column.Value = tableStore.autoIncrement
+			column.PrimaryKeyOption = aliTableStore.NONE
+		}
+	}
+
+	if err := f.checkCondition(change.Condition, tableStore.get(primaryKeys)); err != nil {
+		return nil, err
+	}
+
+	timestamp := time.Now().UnixNano() / int64(time.Millisecond)
+	newRow := &row{primaryKeys: primaryKeys, columns: map[string]*aliTableStore.AttributeColumn{}}
+	for _, column := range change.Columns {
+		newRow.columns[column.ColumnName] = &aliTableStore.AttributeColumn{ColumnName: column.ColumnName, Value: column.Value, Timestamp: timestamp}
+	}
+	tableStore.set(newRow)
+
+	if aliTableStore.ReturnType_RT_PK == change.ReturnType {
+		return newRow.primaryKey(), nil
+	}
+	return &aliTableStore.PrimaryKey{}, nil
+}
+
+func (f *Fake) updateRow(change *aliTableStore.UpdateRowChange) ([]*aliTableStore.AttributeColumn, error) {
+	if err := f.checkPrimaryKey(change.PrimaryKey, false); err != nil {
+		return nil, err
+	}
+
+	tableStore, err := f.transactionTable(change.TableName, change.TransactionId, change.PrimaryKey.PrimaryKeys, true)
+	if err != nil {
+		return nil, err
+	}
+
+	current := tableStore.get(change.PrimaryKey.PrimaryKeys)
+	if err := f.checkCondition(change.Condition, current); err != nil {
+		return nil, err
+	}
+
+	newRow := &row{primaryKeys: copyPrimaryKeyColumns(change.PrimaryKey.PrimaryKeys), columns: map[string]*aliTableStore.AttributeColumn{}}
+	if nil != current {
+		for name, column := range current.columns {
+			newRow.columns[name] = column
+		}
+	}
+
+	timestamp := time.Now().UnixNano() / int64(time.Millisecond)
+	for _, column := range change.Columns {
+		if !column.HasType {
+			if err := checkColumnValue(column.ColumnName, column.Value); err != nil {
+				return nil, err
+			}
+			newRow.columns[column.ColumnName] = &aliTableStore.AttributeColumn{ColumnName: column.ColumnName, Value: column.Value, Timestamp: timestamp}
+			continue
+		}
+
+		switch column.Type {
+		case aliTableStore.DELETE_ALL_VERSION, aliTableStore.DELETE_ONE_VERSION:
+			delete(newRow.columns, column.ColumnName)
+		case aliTableStore.INCREMENT:
+			increment, ok := column.Value.(int64)
+			if !ok {
+				return nil, newParameterInvalidError(fmt.Sprintf("Invalid increment value of %s.", column.ColumnName))
+			}
+
+			var value int64
+			if old, ok := newRow.columns[column.ColumnName]; ok {
+				if value, ok = old.Value.(int64); !ok {
+					return nil, newParameterInvalidError(fmt.Sprintf("Column %s is not integer.", column.ColumnName))
+				}
+			}
+			newRow.columns[column.ColumnName] = &aliTableStore.AttributeColumn{ColumnName: column.ColumnName, Value: value + increment, Timestamp: timestamp}
+		default:
+			return nil, newParameterInvalidError(fmt.Sprintf("Invalid update type of %s.", column.ColumnName))
+		}
+	}
+
+	// 没有属性列的行视为不存在
+	if 0 == len(newRow.columns) {
+		tableStore.remove(newRow.primaryKeys)
+	} else {
+		tableStore.set(newRow)
+	}
+
+	columns := []*aliTableStore.AttributeColumn{}
+	if aliTableStore.ReturnType_RT_AFTER_MODIFY == change.ReturnType && 0 < len(change.ColumnNamesToReturn) {
+		columns = newRow.attributeColumns(change.ColumnNamesToReturn)
+	}
+	return columns, nil
+}
+
+func (f *Fake) deleteRow(change *aliTableStore.DeleteRowChange) error {
+	if err := f.checkPrimaryKey(change.PrimaryKey, false); err != nil {
+		return err
+	}
+
+	tableStore, err := f.transactionTable(change.TableName, change.TransactionId, change.PrimaryKey.PrimaryKeys, true)
+	if err != nil {
+		return err
+	}
+
+	if err := f.checkCondition(change.Condition, tableStore.get(change.PrimaryKey.PrimaryKeys)); err != nil {
+		return err
+	}
+
+	tableStore.remove(change.PrimaryKey.PrimaryKeys)
+	return nil
+}
+
+func (f *Fake) PutRow(request *aliTableStore.PutRowRequest) (*aliTableStore.PutRowResponse, error) {
+	if nil == request || nil == request.PutRowChange {
+		return nil, newEmptyRequestError()
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.call("PutRow")
+	if err := f.checkFault("PutRow", request.PutRowChange.TableName, request.PutRowChange.PrimaryKey); err != nil {
+		return nil, err
+	}
+
+	primaryKey, err := f.putRow(request.PutRowChange)
+	if err != nil {
+		return nil, err
+	}
+
+	response := new(aliTableStore.PutRowResponse)
+	response.RequestId = newRequestId()
+	response.PrimaryKey = *primaryKey
+	response.ConsumedCapacityUnit = &aliTableStore.ConsumedCapacityUnit{Write: 1}
+	return response, nil
+}
+
+func (f *Fake) GetRow(request *aliTableStore.GetRowRequest) (*aliTableStore.GetRowResponse, error) {
+	if nil == request || nil == request.SingleRowQueryCriteria {
+		return nil, newEmptyRequestError()
+	}
+
+	criteria := request.SingleRowQueryCriteria
+	if err := f.checkPrimaryKey(criteria.PrimaryKey, false); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.call("GetRow")
+	if err := f.checkFault("GetRow", criteria.TableName, criteria.PrimaryKey); err != nil {
+		return nil, err
+	}
+
+	tableStore, err := f.transactionTable(criteria.TableName, criteria.TransactionId, criteria.PrimaryKey.PrimaryKeys, false)
+	if err != nil {
+		return nil, err
+	}
+
+	response := new(aliTableStore.GetRowResponse)
+	response.RequestId = newRequestId()
+	response.ConsumedCapacityUnit = &aliTableStore.ConsumedCapacityUnit{Read: 1}
+
+	if current := tableStore.get(criteria.PrimaryKey.PrimaryKeys); nil != current && matchFilter(criteria.Filter, current) {
+		response.PrimaryKey = *current.primaryKey()
+		response.Columns = current.attributeColumns(criteria.ColumnsToGet)
+	}
+
+	return response, nil
+}
+
+func (f *Fake) GetRange(request *aliTableStore.GetRangeRequest) (*aliTableStore.GetRangeResponse, error) {
+	if nil == request || nil == request.RangeRowQueryCriteria {
+		return nil, newEmptyRequestError()
+	}
+
+	criteria := request.RangeRowQueryCriteria
+	if nil == criteria.StartPrimaryKey || nil == criteria.EndPrimaryKey {
+		return nil, newParameterInvalidError("The start or end primary key is empty.")
+	}
+
+	start, end := criteria.StartPrimaryKey.PrimaryKeys, criteria.EndPrimaryKey.PrimaryKeys
+	forward := aliTableStore.FORWARD == criteria.Direction
+	if forward && 0 <= comparePrimaryKey(start, end) {
+		return nil, newParameterInvalidError("Begin key must less than end key in FORWARD.")
+	}
+	if !forward && 0 >= comparePrimaryKey(start, end) {
+		return nil, newParameterInvalidError("Begin key must more than end key in BACKWARD.")
+	}
+
+	limit := int(criteria.Limit)
+	if 0 >= limit || MaxGetRangeRowCount < limit {
+		limit = MaxGetRangeRowCount
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.call("GetRange")
+	if err := f.checkFault("GetRange", criteria.TableName, criteria.StartPrimaryKey); err != nil {
+		return nil, err
+	}
+
+	// 开始主键包含在内, 结束主键不包含在内
+	rows := f.rangeRows(criteria.TableName)
+	matched := []*row{}
+	for i := range rows {
+		current := rows[i]
+		if !forward {
+			current = rows[len(rows)-1-i]
+		}
+
+		if !matchFilter(criteria.Filter, current) {
+			continue
+		}
+
+		if forward && 0 <= comparePrimaryKey(current.primaryKeys, start) && 0 > comparePrimaryKey(current.primaryKeys, end) {
+			matched = append(matched, current)
+		} else if !forward && 0 >= comparePrimaryKey(current.primaryKeys, start) && 0 < comparePrimaryKey(current.primaryKeys, end) {
+			matched = append(matched, current)
+		}
+	}
+
+	response := new(aliTableStore.GetRangeResponse)
+	response.RequestId = newRequestId()
+	if limit < len(matched) {
+		response.NextStartPrimaryKey = matched[limit].primaryKey()
+		matched = matched[:limit]
+	}
+
+	for _, current := range matched {
+		response.Rows = append(response.Rows, &aliTableStore.Row{
+			PrimaryKey: current.primaryKey(),
+			Columns:    current.attributeColumns(criteria.ColumnsToGet),
+		})
+	}
+
+	readCount := int32(len(response.Rows))
+	if 0 == readCount {
+		readCount = 1
+	}
+	response.ConsumedCapacityUnit = &aliTableStore.ConsumedCapacityUnit{Read: readCount}
+
+	return response, nil
+}
+
+func (f *Fake) BatchGetRow(request *aliTableStore.BatchGetRowRequest) (*aliTableStore.BatchGetRowResponse, error) {
+	if nil == request {
+		return nil, newEmptyRequestError()
+	}
+
+	rowCount := 0
+	for _, criteria := range request.MultiRowQueryCriteria {
+		if nil == criteria {
+			return nil, newEmptyRequestError()
+		}
+		rowCount += len(criteria.PrimaryKey)
+	}
+	if MaxBatchGetRowCount < rowCount {
+		return nil, newParameterInvalidError(fmt.Sprintf("Rows count exceeds the upper limit: %d.", MaxBatchGetRowCount))
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.call("BatchGetRow")
+
+	response := new(aliTableStore.BatchGetRowResponse)
+	response.RequestId = newRequestId()
+	response.TableToRowsResult = map[string][]aliTableStore.RowResult{}
+
+	for _, criteria := range request.MultiRowQueryCriteria {
+		for index, primaryKey := range criteria.PrimaryKey {
+			result := aliTableStore.RowResult{TableName: criteria.TableName, Index: int32(index)}
+
+			if err := f.checkPrimaryKey(primaryKey, false); err != nil {
+				result.Error = toRowError(err)
+			} else if err := f.checkFault("BatchGetRow", criteria.TableName, primaryKey); err != nil {
+				result.Error = toRowError(err)
+			} else {
+				result.IsSucceed = true
+				result.ConsumedCapacityUnit = &aliTableStore.ConsumedCapacityUnit{Read: 1}
+				if current := f.table(criteria.TableName).get(primaryKey.PrimaryKeys); nil != current && matchFilter(criteria.Filter, current) {
+					result.PrimaryKey = *current.primaryKey()
+					result.Columns = current.attributeColumns(criteria.ColumnsToGet)
+				}
+			}
+
+			response.TableToRowsResult[criteria.TableName] = append(response.TableToRowsResult[criteria.TableName], result)
+		}
+	}
+
+	return response, nil
+}
+
+func (f *Fake) BatchWriteRow(request *aliTableStore.BatchWriteRowRequest) (*aliTableStore.BatchWriteRowResponse, error) {
+	if nil == request {
+		return nil, newEmptyRequestError()
+	}
+
+	rowCount := 0
+	for _, changes := range request.RowChangesGroupByTable {
+		rowCount += len(changes)
+	}
+	if MaxBatchWriteRowCount < rowCount {
+		return nil, newParameterInvalidError(fmt.Sprintf("Rows count exceeds the upper limit: %d.", MaxBatchWriteRowCount))
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.call("BatchWriteRow")
+
+	response := new(aliTableStore.BatchWriteRowResponse)
+	response.RequestId = newRequestId()
+	response.TableToRowsResult = map[string][]aliTableStore.RowResult{}
+
+	for tableName, changes := range request.RowChangesGroupByTable {
+		for index, change := range changes {
+			var err error
+			switch rowChange := change.(type) {
+			case *aliTableStore.PutRowChange:
+				if err = f.checkFault("BatchWriteRow", tableName, rowChange.PrimaryKey); err == nil {
+					_, err = f.putRow(rowChange)
+				}
+			case *aliTableStore.UpdateRowChange:
+				if err = f.checkFault("BatchWriteRow", tableName, rowChange.PrimaryKey); err == nil {
+					_, err = f.updateRow(rowChange)
+				}
+			case *aliTableStore.DeleteRowChange:
+				if err = f.checkFault("BatchWriteRow", tableName, rowChange.PrimaryKey); err == nil {
+					err = f.deleteRow(rowChange)
+				}
+			default:
+				err = newParameterInvalidError(fmt.Sprintf("Invalid row change type %T.", change))
+			}
+
+			result := aliTableStore.RowResult{TableName: tableName, Index: int32(index)}
+			if err != nil {
+				result.Error = toRowError(err)
+			} else {
+				result.IsSucceed = true
+				result.ConsumedCapacityUnit = &aliTableStore.ConsumedCapacityUnit{Write: 1}
+			}
+
+			response.TableToRowsResult[tableName] = append(response.TableToRowsResult[tableName], result)
+		}
+	}
+
+	return response, nil
+}
+
+func (f *Fake) UpdateRow(request *aliTableStore.UpdateRowRequest) (*aliTableStore.UpdateRowResponse, error) {
+	if nil == request || nil == request.UpdateRowChange {
+		return nil, newEmptyRequestError()
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.call("UpdateRow")
+	if err := f.checkFault("UpdateRow", request.UpdateRowChange.TableName, request.UpdateRowChange.PrimaryKey); err != nil {
+		return nil, err
+	}
+
+	columns, err := f.updateRow(request.UpdateRowChange)
+	if err != nil {
+		return nil, err
+	}
+
+	response := new(aliTableStore.UpdateRowResponse)
+	response.RequestId = newRequestId()
+	response.Columns = columns
+	response.ConsumedCapacityUnit = &aliTableStore.ConsumedCapacityUnit{Write: 1}
+	return response, nil
+}
+
+func (f *Fake) DeleteRow(request *aliTableStore.DeleteRowRequest) (*aliTableStore.DeleteRowResponse, error) {
+	if nil == request || nil == request.DeleteRowChange {
+		return nil, newEmptyRequestError()
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.call("DeleteRow")
+	if err := f.checkFault("DeleteRow", request.DeleteRowChange.TableName, request.DeleteRowChange.PrimaryKey); err != nil {
+		return nil, err
+	}
+
+	if err := f.deleteRow(request.DeleteRowChange); err != nil {
+		return nil, err
+	}
+
+	response := new(aliTableStore.DeleteRowResponse)
+	response.RequestId = newRequestId()
+	response.ConsumedCapacityUnit = &aliTableStore.ConsumedCapacityUnit{Write: 1}
+	return response, nil
+}
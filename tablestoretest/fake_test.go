@@ -0,0 +1,114 @@
+package tablestoretest_test
+
+import (
+	aliTableStore "github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/hughcube-go/tablestore/tablestoretest"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func fake_test_put(fake *tablestoretest.Fake, pk int64, expectation aliTableStore.RowExistenceExpectation) (*aliTableStore.PutRowResponse, error) {
+	request := new(aliTableStore.PutRowRequest)
+	request.PutRowChange = new(aliTableStore.PutRowChange)
+	request.PutRowChange.TableName = "fake"
+	request.PutRowChange.PrimaryKey = new(aliTableStore.PrimaryKey)
+	request.PutRowChange.PrimaryKey.AddPrimaryKeyColumn("pk", pk)
+	request.PutRowChange.PrimaryKey.AddPrimaryKeyColumnWithAutoIncrement("id")
+	request.PutRowChange.AddColumn("name", "fake")
+	request.PutRowChange.SetCondition(expectation)
+	request.PutRowChange.SetReturnPk()
+	return fake.PutRow(request)
+}
+
+func Test_Fake_PutRow(t *testing.T) {
+	a := assert.New(t)
+
+	fake := tablestoretest.New()
+
+	response, err := fake_test_put(fake, 1, aliTableStore.RowExistenceExpectation_IGNORE)
+	a.Nil(err)
+	a.Equal(int64(1), response.PrimaryKey.PrimaryKeys[1].Value)
+
+	response, err = fake_test_put(fake, 1, aliTableStore.RowExistenceExpectation_IGNORE)
+	a.Nil(err)
+	a.Equal(int64(2), response.PrimaryKey.PrimaryKeys[1].Value)
+	a.Equal(2, fake.RowCount("fake"))
+
+	_, err = fake_test_put(fake, 1, aliTableStore.RowExistenceExpectation_EXPECT_EXIST)
+	a.IsType(&aliTableStore.OtsError{}, err)
+	a.Equal(tablestoretest.ConditionCheckFail, err.(*aliTableStore.OtsError).Code)
+}
+
+func Test_Fake_GetRange(t *testing.T) {
+	a := assert.New(t)
+
+	fake := tablestoretest.New()
+	for pk := int64(1); pk <= 5; pk++ {
+		_, err := fake_test_put(fake, pk, aliTableStore.RowExistenceExpectation_IGNORE)
+		a.Nil(err)
+	}
+
+	request := new(aliTableStore.GetRangeRequest)
+	request.RangeRowQueryCriteria = new(aliTableStore.RangeRowQueryCriteria)
+	request.RangeRowQueryCriteria.TableName = "fake"
+	request.RangeRowQueryCriteria.MaxVersion = 1
+	request.RangeRowQueryCriteria.Limit = 2
+	request.RangeRowQueryCriteria.Direction = aliTableStore.BACKWARD
+	request.RangeRowQueryCriteria.StartPrimaryKey = new(aliTableStore.PrimaryKey)
+	request.RangeRowQueryCriteria.StartPrimaryKey.AddPrimaryKeyColumnWithMaxValue("pk")
+	request.RangeRowQueryCriteria.StartPrimaryKey.AddPrimaryKeyColumnWithMaxValue("id")
+	request.RangeRowQueryCriteria.EndPrimaryKey = new(aliTableStore.PrimaryKey)
+	request.RangeRowQueryCriteria.EndPrimaryKey.AddPrimaryKeyColumn("pk", int64(2))
+	request.RangeRowQueryCriteria.EndPrimaryKey.AddPrimaryKeyColumnWithMaxValue("id")
+
+	response, err := fake.GetRange(request)
+	a.Nil(err)
+	a.Len(response.Rows, 2)
+	a.Equal(int64(5), response.Rows[0].PrimaryKey.PrimaryKeys[0].Value)
+	a.Equal(int64(4), response.Rows[1].PrimaryKey.PrimaryKeys[0].Value)
+	a.Equal(int64(3), response.NextStartPrimaryKey.PrimaryKeys[0].Value)
+
+	request.RangeRowQueryCriteria.StartPrimaryKey = response.NextStartPrimaryKey
+	response, err = fake.GetRange(request)
+	a.Nil(err)
+	a.Len(response.Rows, 1)
+	a.Nil(response.NextStartPrimaryKey)
+
+	request.RangeRowQueryCriteria.Direction = aliTableStore.FORWARD
+	_, err = fake.GetRange(request)
+	a.NotNil(err)
+}
+
+func Test_Fake_EmptyRequest(t *testing.T) {
+	a := assert.New(t)
+
+	fake := tablestoretest.New()
+
+	// 空请求返回参数错误, 不返回空的响应
+	errs := []error{}
+	_, err := fake.PutRow(nil)
+	errs = append(errs, err)
+	_, err = fake.PutRow(&aliTableStore.PutRowRequest{})
+	errs = append(errs, err)
+	_, err = fake.GetRow(&aliTableStore.GetRowRequest{})
+	errs = append(errs, err)
+	_, err = fake.GetRange(&aliTableStore.GetRangeRequest{})
+	errs = append(errs, err)
+	_, err = fake.BatchGetRow(nil)
+	errs = append(errs, err)
+	_, err = fake.BatchWriteRow(nil)
+	errs = append(errs, err)
+	_, err = fake.UpdateRow(&aliTableStore.UpdateRowRequest{})
+	errs = append(errs, err)
+	_, err = fake.DeleteRow(&aliTableStore.DeleteRowRequest{})
+	errs = append(errs, err)
+	_, err = fake.CreateTable(nil)
+	errs = append(errs, err)
+	_, err = fake.CommitTransaction(nil)
+	errs = append(errs, err)
+
+	for _, err := range errs {
+		a.IsType(&aliTableStore.OtsError{}, err)
+		a.Equal(tablestoretest.ParameterInvalid, err.(*aliTableStore.OtsError).Code)
+	}
+}
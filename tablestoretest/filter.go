@@ -0,0 +1,74 @@
+package tablestoretest
+
+import (
+	aliTableStore "github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+)
+
+// 判断行是否满足过滤条件, 行不存在时按照没有任何属性列处理
+func matchFilter(filter aliTableStore.ColumnFilter, current *row) bool {
+	switch condition := filter.(type) {
+	case nil:
+		return true
+	case *aliTableStore.SingleColumnCondition:
+		return matchSingleColumnCondition(condition, current)
+	case *aliTableStore.CompositeColumnValueFilter:
+		return matchCompositeColumnValueFilter(condition, current)
+	}
+
+	// 分页等其他类型的过滤器不影响行是否返回
+	return true
+}
+
+func matchSingleColumnCondition(condition *aliTableStore.SingleColumnCondition, current *row) bool {
+	var column *aliTableStore.AttributeColumn
+	if nil != current {
+		column = current.columns[*condition.ColumnName]
+	}
+
+	if nil == column {
+		return !condition.FilterIfMissing
+	}
+
+	sameType := valueRank(column.Value) == valueRank(condition.ColumnValue)
+	result := compareValue(column.Value, condition.ColumnValue)
+
+	switch *condition.Comparator {
+	case aliTableStore.CT_EQUAL:
+		return sameType && 0 == result
+	case aliTableStore.CT_NOT_EQUAL:
+		return !sameType || 0 != result
+	case aliTableStore.CT_GREATER_THAN:
+		return sameType && 0 < result
+	case aliTableStore.CT_GREATER_EQUAL:
+		return sameType && 0 <= result
+	case aliTableStore.CT_LESS_THAN:
+		return sameType && 0 > result
+	case aliTableStore.CT_LESS_EQUAL:
+		return sameType && 0 >= result
+	}
+
+	return false
+}
+
+func matchCompositeColumnValueFilter(condition *aliTableStore.CompositeColumnValueFilter, current *row) bool {
+	switch condition.Operator {
+	case aliTableStore.LO_NOT:
+		return 1 == len(condition.Filters) && !matchFilter(condition.Filters[0], current)
+	case aliTableStore.LO_AND:
+		for _, filter := range condition.Filters {
+			if !matchFilter(filter, current) {
+				return false
+			}
+		}
+		return true
+	case aliTableStore.LO_OR:
+		for _, filter := range condition.Filters {
+			if matchFilter(filter, current) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return false
+}
@@ -0,0 +1,203 @@
+package tablestoretest
+
+import (
+	"bytes"
+	"fmt"
+	aliTableStore "github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"sort"
+)
+
+type row struct {
+	primaryKeys []*aliTableStore.PrimaryKeyColumn
+	columns     map[string]*aliTableStore.AttributeColumn
+}
+
+// 只保留最新版本的数据, 列按照列名排序返回
+func (r *row) attributeColumns(columnsToGet []string) []*aliTableStore.AttributeColumn {
+	names := []string{}
+	for name := range r.columns {
+		if 0 < len(columnsToGet) && !containsString(columnsToGet, name) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	columns := []*aliTableStore.AttributeColumn{}
+	for _, name := range names {
+		column := *r.columns[name]
+		columns = append(columns, &column)
+	}
+	return columns
+}
+
+func (r *row) primaryKey() *aliTableStore.PrimaryKey {
+	return &aliTableStore.PrimaryKey{PrimaryKeys: copyPrimaryKeyColumns(r.primaryKeys)}
+}
+
+type table struct {
+	// 按照主键顺序排列
+	rows          []*row
+	autoIncrement int64
+
+	// 通过CreateTable创建的表才有, 写入时自动创建的表为nil
+	meta       *aliTableStore.TableMeta
+	option     aliTableStore.TableOption
+	throughput aliTableStore.ReservedThroughput
+	indexes    []*aliTableStore.IndexMeta
+}
+
+func (t *table) search(primaryKeys []*aliTableStore.PrimaryKeyColumn) (int, bool) {
+	index := sort.Search(len(t.rows), func(i int) bool {
+		return 0 <= comparePrimaryKey(t.rows[i].primaryKeys, primaryKeys)
+	})
+	return index, index < len(t.rows) && 0 == comparePrimaryKey(t.rows[index].primaryKeys, primaryKeys)
+}
+
+func (t *table) get(primaryKeys []*aliTableStore.PrimaryKeyColumn) *row {
+	if index, ok := t.search(primaryKeys); ok {
+		return t.rows[index]
+	}
+	return nil
+}
+
+func (t *table) set(r *row) {
+	index, ok := t.search(r.primaryKeys)
+	if ok {
+		t.rows[index] = r
+		return
+	}
+
+	t.rows = append(t.rows, nil)
+	copy(t.rows[index+1:], t.rows[index:])
+	t.rows[index] = r
+}
+
+func (t *table) remove(primaryKeys []*aliTableStore.PrimaryKeyColumn) {
+	if index, ok := t.search(primaryKeys); ok {
+		t.rows = append(t.rows[:index], t.rows[index+1:]...)
+	}
+}
+
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+func copyPrimaryKeyColumns(primaryKeys []*aliTableStore.PrimaryKeyColumn) []*aliTableStore.PrimaryKeyColumn {
+	columns := []*aliTableStore.PrimaryKeyColumn{}
+	for _, primaryKey := range primaryKeys {
+		column := *primaryKey
+		columns = append(columns, &column)
+	}
+	return columns
+}
+
+// 比较两个主键, INF_MIN小于任何值, INF_MAX大于任何值
+func comparePrimaryKey(a, b []*aliTableStore.PrimaryKeyColumn) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if result := comparePrimaryKeyColumn(a[i], b[i]); 0 != result {
+			return result
+		}
+	}
+	return len(a) - len(b)
+}
+
+func comparePrimaryKeyColumn(a, b *aliTableStore.PrimaryKeyColumn) int {
+	if a.PrimaryKeyOption == b.PrimaryKeyOption && aliTableStore.NONE != a.PrimaryKeyOption {
+		return 0
+	}
+
+	if aliTableStore.MIN == a.PrimaryKeyOption || aliTableStore.MAX == b.PrimaryKeyOption {
+		return -1
+	}
+
+	if aliTableStore.MAX == a.PrimaryKeyOption || aliTableStore.MIN == b.PrimaryKeyOption {
+		return 1
+	}
+
+	return compareValue(a.Value, b.Value)
+}
+
+// 比较两个同类型的值, 类型不同时按照 整形 < 浮点数 < 布尔值 < 字符串 < 二进制 排序
+func compareValue(a, b interface{}) int {
+	switch av := a.(type) {
+	case int64:
+		if bv, ok := b.(int64); ok {
+			if av < bv {
+				return -1
+			} else if av > bv {
+				return 1
+			}
+			return 0
+		}
+	case float64:
+		if bv, ok := b.(float64); ok {
+			if av < bv {
+				return -1
+			} else if av > bv {
+				return 1
+			}
+			return 0
+		}
+	case bool:
+		if bv, ok := b.(bool); ok {
+			if av == bv {
+				return 0
+			} else if !av {
+				return -1
+			}
+			return 1
+		}
+	case string:
+		if bv, ok := b.(string); ok {
+			if av < bv {
+				return -1
+			} else if av > bv {
+				return 1
+			}
+			return 0
+		}
+	case []byte:
+		if bv, ok := b.([]byte); ok {
+			return bytes.Compare(av, bv)
+		}
+	}
+	return valueRank(a) - valueRank(b)
+}
+
+func valueRank(value interface{}) int {
+	switch value.(type) {
+	case int64:
+		return 0
+	case float64:
+		return 1
+	case bool:
+		return 2
+	case string:
+		return 3
+	case []byte:
+		return 4
+	}
+	return 5
+}
+
+func checkPrimaryKeyValue(column *aliTableStore.PrimaryKeyColumn) error {
+	switch column.Value.(type) {
+	case int64, string, []byte:
+		return nil
+	}
+	return newParameterInvalidError(fmt.Sprintf("Invalid primary key column type %T of %s.", column.Value, column.ColumnName))
+}
+
+func checkColumnValue(name string, value interface{}) error {
+	switch value.(type) {
+	case int64, float64, bool, string, []byte:
+		return nil
+	}
+	return newParameterInvalidError(fmt.Sprintf("Invalid column type %T of %s.", value, name))
+}
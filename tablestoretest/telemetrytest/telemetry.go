@@ -0,0 +1,107 @@
+package telemetrytest
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// Recorder 在内存中记录span和指标, 不需要collector, 配合tablestore.WithTelemetry使用,
+// 单独一个包, 使用tablestoretest的时候不需要依赖otel的sdk
+//
+//	recorder := telemetrytest.New()
+//	client := tablestore.New("", "", "", "", tablestore.WithApi(tablestoretest.New()), tablestore.WithTelemetry(
+//		tablestore.WithTracerProvider(recorder.TracerProvider),
+//		tablestore.WithMeterProvider(recorder.MeterProvider),
+//	))
+type Recorder struct {
+	TracerProvider *sdktrace.TracerProvider
+	MeterProvider  *sdkmetric.MeterProvider
+
+	spans  *tracetest.InMemoryExporter
+	reader *sdkmetric.ManualReader
+}
+
+func New() *Recorder {
+	spans := tracetest.NewInMemoryExporter()
+	reader := sdkmetric.NewManualReader()
+	return &Recorder{
+		TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSyncer(spans)),
+		MeterProvider:  sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)),
+		spans:          spans,
+		reader:         reader,
+	}
+}
+
+// Spans 已经结束的span
+func (t *Recorder) Spans() tracetest.SpanStubs {
+	return t.spans.GetSpans()
+}
+
+// Reset 清空记录的span, 指标是累计值不会清空
+func (t *Recorder) Reset() {
+	t.spans.Reset()
+}
+
+// Metrics 收集当前的指标
+func (t *Recorder) Metrics() (metricdata.ResourceMetrics, error) {
+	var metrics metricdata.ResourceMetrics
+	err := t.reader.Collect(context.Background(), &metrics)
+	return metrics, err
+}
+
+// Sum 计数器中属性包含attributes的数据点的和
+func (t *Recorder) Sum(name string, attributes ...attribute.KeyValue) int64 {
+	var sum int64
+	t.eachMetric(name, func(data metricdata.Aggregation) {
+		if counter, ok := data.(metricdata.Sum[int64]); ok {
+			for _, point := range counter.DataPoints {
+				if matchAttributes(point.Attributes, attributes) {
+					sum += point.Value
+				}
+			}
+		}
+	})
+	return sum
+}
+
+// Count 直方图中属性包含attributes的记录次数
+func (t *Recorder) Count(name string, attributes ...attribute.KeyValue) uint64 {
+	var count uint64
+	t.eachMetric(name, func(data metricdata.Aggregation) {
+		if histogram, ok := data.(metricdata.Histogram[float64]); ok {
+			for _, point := range histogram.DataPoints {
+				if matchAttributes(point.Attributes, attributes) {
+					count += point.Count
+				}
+			}
+		}
+	})
+	return count
+}
+
+func (t *Recorder) eachMetric(name string, fn func(data metricdata.Aggregation)) {
+	metrics, err := t.Metrics()
+	if err != nil {
+		return
+	}
+	for _, scope := range metrics.ScopeMetrics {
+		for _, metric := range scope.Metrics {
+			if name == metric.Name {
+				fn(metric.Data)
+			}
+		}
+	}
+}
+
+func matchAttributes(set attribute.Set, attributes []attribute.KeyValue) bool {
+	for _, expected := range attributes {
+		if value, ok := set.Value(expected.Key); !ok || value != expected.Value {
+			return false
+		}
+	}
+	return true
+}
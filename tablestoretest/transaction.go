@@ -0,0 +1,171 @@
+package tablestoretest
+
+import (
+	"fmt"
+	aliTableStore "github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"net/http"
+	"sync/atomic"
+)
+
+const (
+	RowOperationConflict = "OTSRowOperationConflict"
+	TransactionNotExist  = "OTSSessionNotExist"
+)
+
+var transactionSequence int64
+
+// 局部事务, 事务中的写入先写到副本中, 提交的时候替换表中同一个分区的行
+type transaction struct {
+	tableName    string
+	partitionKey *aliTableStore.PrimaryKeyColumn
+	rows         *table
+}
+
+func (tx *transaction) contains(tableName string, primaryKeys []*aliTableStore.PrimaryKeyColumn) bool {
+	return tx.tableName == tableName && 0 < len(primaryKeys) && 0 == comparePrimaryKeyColumn(tx.partitionKey, primaryKeys[0])
+}
+
+func newTransactionNotExistError(transactionId string) *aliTableStore.OtsError {
+	return newError(TransactionNotExist, fmt.Sprintf("Transaction %s does not exist.", transactionId), http.StatusNotFound)
+}
+
+func newRowOperationConflictError() *aliTableStore.OtsError {
+	return newError(RowOperationConflict, "Data is being modified by the other request.", http.StatusConflict)
+}
+
+// 读写时使用的表, 事务中的读写使用事务的副本, 不在事务中的写入不能修改被事务锁定的分区, 调用时需要持有锁
+func (f *Fake) transactionTable(tableName string, transactionId *string, primaryKeys []*aliTableStore.PrimaryKeyColumn, write bool) (*table, error) {
+	if nil == transactionId {
+		for _, tx := range f.transactions {
+			if write && tx.contains(tableName, primaryKeys) {
+				return nil, newRowOperationConflictError()
+			}
+		}
+		return f.table(tableName), nil
+	}
+
+	tx, ok := f.transactions[*transactionId]
+	if !ok {
+		return nil, newTransactionNotExistError(*transactionId)
+	}
+
+	if !tx.contains(tableName, primaryKeys) {
+		return nil, newParameterInvalidError("The row is not in the partition of the transaction.")
+	}
+
+	return tx.rows, nil
+}
+
+func (f *Fake) StartLocalTransaction(request *aliTableStore.StartLocalTransactionRequest) (*aliTableStore.StartLocalTransactionResponse, error) {
+	if nil == request {
+		return nil, newEmptyRequestError()
+	}
+	if nil == request.PrimaryKey || 1 != len(request.PrimaryKey.PrimaryKeys) {
+		return nil, newParameterInvalidError("The primary key of transaction must only contain the partition key.")
+	}
+	if err := checkPrimaryKeyValue(request.PrimaryKey.PrimaryKeys[0]); err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.call("StartLocalTransaction")
+	if err := f.checkFault("StartLocalTransaction", request.TableName, request.PrimaryKey); err != nil {
+		return nil, err
+	}
+
+	// 同一个分区同时只能有一个事务
+	for _, tx := range f.transactions {
+		if tx.contains(request.TableName, request.PrimaryKey.PrimaryKeys) {
+			return nil, newRowOperationConflictError()
+		}
+	}
+
+	current := f.table(request.TableName)
+	transactionId := fmt.Sprintf("fake-transaction-%d", atomic.AddInt64(&transactionSequence, 1))
+	f.transactions[transactionId] = &transaction{
+		tableName:    request.TableName,
+		partitionKey: copyPrimaryKeyColumns(request.PrimaryKey.PrimaryKeys)[0],
+		rows:         &table{rows: append([]*row{}, current.rows...), autoIncrement: current.autoIncrement},
+	}
+
+	response := new(aliTableStore.StartLocalTransactionResponse)
+	response.RequestId = newRequestId()
+	response.TransactionId = &transactionId
+	return response, nil
+}
+
+func (f *Fake) CommitTransaction(request *aliTableStore.CommitTransactionRequest) (*aliTableStore.CommitTransactionResponse, error) {
+	if nil == request {
+		return nil, newEmptyRequestError()
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.call("CommitTransaction")
+	tx, err := f.endTransaction("CommitTransaction", request.TransactionId)
+	if err != nil {
+		return nil, err
+	}
+
+	current := f.table(tx.tableName)
+	rows := []*row{}
+	for _, item := range current.rows {
+		if !tx.contains(tx.tableName, item.primaryKeys) {
+			rows = append(rows, item)
+		}
+	}
+	current.rows = rows
+
+	for _, item := range tx.rows.rows {
+		if tx.contains(tx.tableName, item.primaryKeys) {
+			current.set(item)
+		}
+	}
+	if current.autoIncrement < tx.rows.autoIncrement {
+		current.autoIncrement = tx.rows.autoIncrement
+	}
+
+	response := new(aliTableStore.CommitTransactionResponse)
+	response.RequestId = newRequestId()
+	return response, nil
+}
+
+func (f *Fake) AbortTransaction(request *aliTableStore.AbortTransactionRequest) (*aliTableStore.AbortTransactionResponse, error) {
+	if nil == request {
+		return nil, newEmptyRequestError()
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.call("AbortTransaction")
+	if _, err := f.endTransaction("AbortTransaction", request.TransactionId); err != nil {
+		return nil, err
+	}
+
+	response := new(aliTableStore.AbortTransactionResponse)
+	response.RequestId = newRequestId()
+	return response, nil
+}
+
+// 结束事务, 模拟失败的时候事务保持不变, 调用时需要持有锁
+func (f *Fake) endTransaction(operation string, transactionId *string) (*transaction, error) {
+	if nil == transactionId {
+		return nil, newParameterInvalidError("The transaction id is empty.")
+	}
+
+	tx, ok := f.transactions[*transactionId]
+	if !ok {
+		return nil, newTransactionNotExistError(*transactionId)
+	}
+
+	if err := f.checkFault(operation, tx.tableName, nil); err != nil {
+		return nil, err
+	}
+
+	delete(f.transactions, *transactionId)
+	return tx, nil
+}
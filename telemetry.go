@@ -0,0 +1,266 @@
+package tablestore
+
+import (
+	"context"
+	"errors"
+	aliTableStore "github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+const instrumentationName = "github.com/hughcube-go/tablestore"
+
+// span和指标使用的属性
+const (
+	AttributeOperation         = attribute.Key("tablestore.operation")
+	AttributeTable             = attribute.Key("tablestore.table")
+	AttributeRows              = attribute.Key("tablestore.rows")
+	AttributeAttempts          = attribute.Key("tablestore.attempts")
+	AttributeRowsRead          = attribute.Key("tablestore.rows.read")
+	AttributeRowsWritten       = attribute.Key("tablestore.rows.written")
+	AttributeRowsFailed        = attribute.Key("tablestore.rows.failed")
+	AttributeReadCapacityUnit  = attribute.Key("tablestore.capacity_unit.read")
+	AttributeWriteCapacityUnit = attribute.Key("tablestore.capacity_unit.write")
+	AttributeErrorCode         = attribute.Key("tablestore.error_code")
+	AttributeRequestId         = attribute.Key("tablestore.request_id")
+)
+
+// 指标的名称
+const (
+	MetricDuration          = "tablestore.request.duration"
+	MetricRowsRead          = "tablestore.rows.read"
+	MetricRowsWritten       = "tablestore.rows.written"
+	MetricReadCapacityUnit  = "tablestore.capacity_unit.read"
+	MetricWriteCapacityUnit = "tablestore.capacity_unit.write"
+)
+
+type TelemetryOption func(*telemetry)
+
+// WithTracerProvider 默认使用otel.GetTracerProvider()
+func WithTracerProvider(provider trace.TracerProvider) TelemetryOption {
+	return func(t *telemetry) {
+		t.tracerProvider = provider
+	}
+}
+
+// WithMeterProvider 默认使用otel.GetMeterProvider()
+func WithMeterProvider(provider metric.MeterProvider) TelemetryOption {
+	return func(t *telemetry) {
+		t.meterProvider = provider
+	}
+}
+
+// WithTelemetry 每一次调用生成一个OpenTelemetry的span, 汇总请求数, 读写的行数和消耗的CU,
+// 调用中的每一个sdk请求生成一个子span, 并记录耗时, 读写的行数和消耗的CU, 重试包含在同一个子span中
+//
+//	client := tablestore.New(endPoint, instanceName, accessKeyId, accessKeySecret, tablestore.WithTelemetry())
+func WithTelemetry(options ...TelemetryOption) ClientOption {
+	t := &telemetry{
+		tracerProvider: otel.GetTracerProvider(),
+		meterProvider:  otel.GetMeterProvider(),
+	}
+	for _, option := range options {
+		option(t)
+	}
+	t.init()
+	return func(client *TableStore) {
+		client.telemetry = t
+		WithInterceptor(t.intercept)(client)
+	}
+}
+
+type telemetry struct {
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+
+	tracer            trace.Tracer
+	duration          metric.Float64Histogram
+	rowsRead          metric.Int64Counter
+	rowsWritten       metric.Int64Counter
+	readCapacityUnit  metric.Int64Counter
+	writeCapacityUnit metric.Int64Counter
+}
+
+// 创建指标失败的时候交给otel的ErrorHandler, 返回的指标仍然可以使用
+func (t *telemetry) init() {
+	var err error
+	t.tracer = t.tracerProvider.Tracer(instrumentationName)
+	meter := t.meterProvider.Meter(instrumentationName)
+
+	t.duration, err = meter.Float64Histogram(MetricDuration, metric.WithUnit("s"), metric.WithDescription("Duration of tablestore requests, including retries"))
+	handleTelemetryError(err)
+	t.rowsRead, err = meter.Int64Counter(MetricRowsRead, metric.WithUnit("{row}"), metric.WithDescription("Rows read from tablestore"))
+	handleTelemetryError(err)
+	t.rowsWritten, err = meter.Int64Counter(MetricRowsWritten, metric.WithUnit("{row}"), metric.WithDescription("Rows written to tablestore"))
+	handleTelemetryError(err)
+	t.readCapacityUnit, err = meter.Int64Counter(MetricReadCapacityUnit, metric.WithUnit("{cu}"), metric.WithDescription("Read capacity units consumed"))
+	handleTelemetryError(err)
+	t.writeCapacityUnit, err = meter.Int64Counter(MetricWriteCapacityUnit, metric.WithUnit("{cu}"), metric.WithDescription("Write capacity units consumed"))
+	handleTelemetryError(err)
+}
+
+func handleTelemetryError(err error) {
+	if err != nil {
+		otel.Handle(err)
+	}
+}
+
+func (t *telemetry) intercept(ctx context.Context, call *Call, next Handler) (interface{}, error) {
+	tableName := strings.Join(call.TableNames, ",")
+	ctx, span := t.tracer.Start(ctx, "tablestore."+call.Operation, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("db.system", "tablestore"),
+		AttributeOperation.String(call.Operation),
+		AttributeTable.String(tableName),
+		AttributeRows.Int(requestRows(call.Request)),
+	))
+	defer span.End()
+
+	start := time.Now()
+	response, err := next(ctx, call)
+	elapsed := time.Since(start)
+
+	usages := responseUsage(call, response)
+	total := tableUsage{}
+	for _, usage := range usages {
+		total.add(usage)
+	}
+	span.SetAttributes(
+		AttributeRowsRead.Int64(total.RowsRead),
+		AttributeRowsWritten.Int64(total.RowsWritten),
+		AttributeRowsFailed.Int64(total.RowsFailed),
+		AttributeReadCapacityUnit.Int64(total.ReadCapacityUnit),
+		AttributeWriteCapacityUnit.Int64(total.WriteCapacityUnit),
+	)
+
+	durationAttributes := []attribute.KeyValue{AttributeOperation.String(call.Operation), AttributeTable.String(tableName)}
+	if err != nil {
+		code := ErrorCode(err)
+		if "" == code {
+			code = "CLIENT_ERROR"
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.SetAttributes(AttributeErrorCode.String(code))
+		durationAttributes = append(durationAttributes, AttributeErrorCode.String(code))
+	}
+	if requestId := responseRequestId(err, response); "" != requestId {
+		span.SetAttributes(AttributeRequestId.String(requestId))
+	}
+	t.duration.Record(ctx, elapsed.Seconds(), metric.WithAttributes(durationAttributes...))
+
+	for name, usage := range usages {
+		attributes := metric.WithAttributes(AttributeOperation.String(call.Operation), AttributeTable.String(name))
+		if 0 < usage.RowsRead {
+			t.rowsRead.Add(ctx, usage.RowsRead, attributes)
+		}
+		if 0 < usage.RowsWritten {
+			t.rowsWritten.Add(ctx, usage.RowsWritten, attributes)
+		}
+		if 0 < usage.ReadCapacityUnit {
+			t.readCapacityUnit.Add(ctx, usage.ReadCapacityUnit, attributes)
+		}
+		if 0 < usage.WriteCapacityUnit {
+			t.writeCapacityUnit.Add(ctx, usage.WriteCapacityUnit, attributes)
+		}
+	}
+
+	return response, err
+}
+
+// 一次调用, 比如BatchInsert拆分的多个请求和重试, 统计请求数和CU, 开启WithTelemetry的时候生成span
+type operation struct {
+	stats *requestStats
+	span  trace.Span
+}
+
+func (t *TableStore) startOperation(ctx context.Context, name string) (context.Context, *operation) {
+	ctx, stats := withRequestStats(ctx)
+	op := &operation{stats: stats}
+	if nil != t.telemetry {
+		ctx, op.span = t.telemetry.tracer.Start(ctx, "tablestore."+name, trace.WithSpanKind(trace.SpanKindInternal), trace.WithAttributes(
+			attribute.String("db.system", "tablestore"),
+			AttributeOperation.String(name),
+		))
+	}
+	return ctx, op
+}
+
+// 结束调用, 返回请求数和消耗的CU
+func (op *operation) end(err error) (int, CapacityUnit) {
+	attempts, capacityUnit := op.stats.attempts(), op.stats.capacityUnit()
+	if nil == op.span {
+		return attempts, capacityUnit
+	}
+
+	op.stats.mu.Lock()
+	tableNames := make([]string, 0, len(op.stats.tables))
+	for tableName := range op.stats.tables {
+		tableNames = append(tableNames, tableName)
+	}
+	usage := op.stats.usage
+	op.stats.mu.Unlock()
+	sort.Strings(tableNames)
+
+	op.span.SetAttributes(
+		AttributeTable.String(strings.Join(tableNames, ",")),
+		AttributeAttempts.Int(attempts),
+		AttributeRowsRead.Int64(usage.RowsRead),
+		AttributeRowsWritten.Int64(usage.RowsWritten),
+		AttributeRowsFailed.Int64(usage.RowsFailed),
+		AttributeReadCapacityUnit.Int64(capacityUnit.Read),
+		AttributeWriteCapacityUnit.Int64(capacityUnit.Write),
+	)
+	if err != nil {
+		op.span.RecordError(err)
+		op.span.SetStatus(codes.Error, err.Error())
+		if code := ErrorCode(err); "" != code {
+			op.span.SetAttributes(AttributeErrorCode.String(code))
+		}
+	}
+	op.span.End()
+	return attempts, capacityUnit
+}
+
+// 请求中的行数, 范围查询和多元索引查询为0
+func requestRows(request interface{}) int {
+	switch request := request.(type) {
+	case *aliTableStore.PutRowRequest, *aliTableStore.GetRowRequest, *aliTableStore.UpdateRowRequest, *aliTableStore.DeleteRowRequest:
+		return 1
+	case *aliTableStore.BatchGetRowRequest:
+		count := 0
+		for _, criteria := range request.MultiRowQueryCriteria {
+			count += len(criteria.PrimaryKey)
+		}
+		return count
+	case *aliTableStore.BatchWriteRowRequest:
+		count := 0
+		for _, changes := range request.RowChangesGroupByTable {
+			count += len(changes)
+		}
+		return count
+	}
+	return 0
+}
+
+// sdk的响应都包含aliTableStore.ResponseInfo
+func responseRequestId(err error, response interface{}) string {
+	var tableStoreError *Error
+	if errors.As(err, &tableStoreError) {
+		return tableStoreError.RequestId
+	}
+	value := reflect.ValueOf(response)
+	if reflect.Ptr != value.Kind() || value.IsNil() {
+		return ""
+	}
+	if field := value.Elem().FieldByName("RequestId"); field.IsValid() && reflect.String == field.Kind() {
+		return field.String()
+	}
+	return ""
+}
@@ -0,0 +1,109 @@
+package tablestore
+
+import (
+	"context"
+	aliTableStore "github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/hughcube-go/tablestore/schema"
+)
+
+// Tx 局部事务, 只能读写开启事务时的表中分区键相同的行
+type Tx struct {
+	ctx    context.Context
+	client *TableStore
+	id     string
+}
+
+// Transaction 使用partitionKey的表和分区键开启局部事务, partitionKey只需要设置第一个主键,
+// fn返回nil的时候提交, 返回错误、panic或者提交失败的时候回滚, 提交和回滚不受ctx取消的影响
+//
+//	err := client.Transaction(ctx, &Order{UserId: 1}, func(tx *tablestore.Tx) error {
+//		if response := tx.Insert(&Order{UserId: 1, OrderId: 2}); nil != response.Error {
+//			return response.Error
+//		}
+//		return tx.UpdateOne(&Order{UserId: 1, OrderId: 1}, map[string]interface{}{"Status": 2}).Error
+//	})
+func (t *TableStore) Transaction(ctx context.Context, partitionKey schema.Tabler, fn func(tx *Tx) error) (err error) {
+	ctx, op := t.startOperation(ctx, "Transaction")
+	defer func() { op.end(err) }()
+
+	tableSchema, err := t.ParseSchema(partitionKey)
+	if err != nil {
+		return err
+	}
+
+	primaryKey, err := tableSchema.BuildPartitionKey(partitionKey)
+	if err != nil {
+		return err
+	}
+
+	response, err := t.startLocalTransaction(ctx, &aliTableStore.StartLocalTransactionRequest{TableName: partitionKey.TableName(), PrimaryKey: primaryKey})
+	if err != nil {
+		return err
+	}
+
+	client := *t
+	client.transactionId = response.TransactionId
+	tx := &Tx{ctx: ctx, client: &client, id: *response.TransactionId}
+
+	// ctx已经取消的时候也需要提交或者回滚, 否则分区会一直被锁定到事务超时
+	endCtx := context.WithoutCancel(ctx)
+	abort := func() {
+		_, _ = t.abortTransaction(endCtx, &aliTableStore.AbortTransactionRequest{TransactionId: response.TransactionId})
+	}
+
+	defer func() {
+		if r := recover(); nil != r {
+			abort()
+			panic(r)
+		}
+	}()
+
+	if err = fn(tx); err != nil {
+		abort()
+		return err
+	}
+
+	// 提交失败的时候事务仍然存在, 回滚释放分区, 如果事务实际已经提交回滚会失败, 忽略即可
+	if _, err = t.commitTransaction(endCtx, &aliTableStore.CommitTransactionRequest{TransactionId: response.TransactionId}); err != nil {
+		abort()
+		return err
+	}
+	return nil
+}
+
+func (tx *Tx) ID() string {
+	return tx.id
+}
+
+func (tx *Tx) Insert(row schema.Tabler, options ...func(*aliTableStore.PutRowRequest)) InstallResponse {
+	return tx.InsertCtx(tx.ctx, row, options...)
+}
+
+func (tx *Tx) InsertCtx(ctx context.Context, row schema.Tabler, options ...func(*aliTableStore.PutRowRequest)) InstallResponse {
+	return tx.client.InsertCtx(ctx, row, options...)
+}
+
+// QueryOne 可以读到事务中还没有提交的修改
+func (tx *Tx) QueryOne(row schema.Tabler, options ...func(*aliTableStore.GetRowRequest)) QueryOneResponse {
+	return tx.QueryOneCtx(tx.ctx, row, options...)
+}
+
+func (tx *Tx) QueryOneCtx(ctx context.Context, row schema.Tabler, options ...func(*aliTableStore.GetRowRequest)) QueryOneResponse {
+	return tx.client.QueryOneCtx(ctx, row, options...)
+}
+
+func (tx *Tx) UpdateOne(row schema.Tabler, columns map[string]interface{}, options ...func(*aliTableStore.UpdateRowRequest)) UpdateOneResponse {
+	return tx.UpdateOneCtx(tx.ctx, row, columns, options...)
+}
+
+func (tx *Tx) UpdateOneCtx(ctx context.Context, row schema.Tabler, columns map[string]interface{}, options ...func(*aliTableStore.UpdateRowRequest)) UpdateOneResponse {
+	return tx.client.UpdateOneCtx(ctx, row, columns, options...)
+}
+
+func (tx *Tx) DeleteOne(row schema.Tabler) DeleteResponse {
+	return tx.DeleteOneCtx(tx.ctx, row)
+}
+
+func (tx *Tx) DeleteOneCtx(ctx context.Context, row schema.Tabler) DeleteResponse {
+	return tx.client.DeleteOneCtx(ctx, row)
+}
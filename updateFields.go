@@ -0,0 +1,60 @@
+package tablestore
+
+import (
+	"context"
+	aliTableStore "github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/hughcube-go/tablestore/schema"
+)
+
+// UpdateFields 使用结构体中的字段更新, fields是字段名或者列名, 为空的时候更新所有的属性列, 空指针字段删除对应的列
+//
+//	user.Name = "new name"
+//	client.UpdateFields(user, "Name")
+func (t *TableStore) UpdateFields(row schema.Tabler, fields ...string) UpdateOneResponse {
+	return t.UpdateFieldsCtx(context.Background(), row, fields...)
+}
+
+func (t *TableStore) UpdateFieldsCtx(ctx context.Context, row schema.Tabler, fields ...string) UpdateOneResponse {
+	tableSchema, err := t.ParseSchema(row)
+	if err != nil {
+		return UpdateOneResponse{Error: err}
+	}
+
+	columns, err := tableSchema.BuildFieldColumns(row, fields...)
+	if err != nil {
+		return UpdateOneResponse{Error: err}
+	}
+
+	return t.UpdateOneCtx(ctx, row, columns)
+}
+
+// UpdateChanged 只更新modified中和original不同的字段, 成功之后填充modified, 没有不同的字段时不发起请求
+//
+//	modified := *original
+//	modified.Name = "new name"
+//	client.UpdateChanged(original, &modified)
+func (t *TableStore) UpdateChanged(original schema.Tabler, modified schema.Tabler, options ...func(*aliTableStore.UpdateRowRequest)) UpdateOneResponse {
+	return t.UpdateChangedCtx(context.Background(), original, modified, options...)
+}
+
+func (t *TableStore) UpdateChangedCtx(ctx context.Context, original schema.Tabler, modified schema.Tabler, options ...func(*aliTableStore.UpdateRowRequest)) UpdateOneResponse {
+	tableSchema, err := t.ParseSchema(modified)
+	if err != nil {
+		return UpdateOneResponse{Error: err}
+	}
+
+	fields, err := tableSchema.ChangedFields(original, modified)
+	if err != nil {
+		return UpdateOneResponse{Error: err}
+	}
+	if 0 == len(fields) {
+		return UpdateOneResponse{}
+	}
+
+	columns, err := tableSchema.BuildFieldColumns(modified, fields...)
+	if err != nil {
+		return UpdateOneResponse{Error: err}
+	}
+
+	return t.UpdateOneCtx(ctx, modified, columns, options...)
+}
@@ -1,40 +1,94 @@
 package tablestore
 
 import (
+	"context"
+	"errors"
 	aliTableStore "github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
 	"github.com/hughcube-go/tablestore/schema"
 )
 
+// ErrStaleObject 存在版本字段的时候, 行已经被其他请求修改或者删除, 同时还有Where等其他条件的时候返回原来的*Error
+var ErrStaleObject = errors.New("stale object, the row has been modified or deleted")
+
+// 版本是唯一条件的时候条件检查失败才返回ErrStaleObject, 否则无法区分是哪一个条件失败
+func staleObjectError(err error, onlyVersion bool) error {
+	if onlyVersion && IsConditionFailed(err) {
+		return ErrStaleObject
+	}
+	return err
+}
+
 type UpdateOneResponse struct {
 	Error    error
 	Response *aliTableStore.UpdateRowResponse
+
+	// 发起的UpdateRow请求数, 包含重试
+	Attempts int
+
+	// UpdateRow请求消耗的CU
+	CapacityUnit CapacityUnit
 }
 
 func (t *TableStore) UpdateOne(row schema.Tabler, columns map[string]interface{}, options ...func(*aliTableStore.UpdateRowRequest)) UpdateOneResponse {
+	return t.UpdateOneCtx(context.Background(), row, columns, options...)
+}
+
+func (t *TableStore) UpdateOneCtx(ctx context.Context, row schema.Tabler, columns map[string]interface{}, options ...func(*aliTableStore.UpdateRowRequest)) (result UpdateOneResponse) {
+	ctx, op := t.startOperation(ctx, "UpdateOne")
+	defer func() { result.Attempts, result.CapacityUnit = op.end(result.Error) }()
+
 	tableSchema, err := t.ParseSchema(row)
 	if err != nil {
 		return UpdateOneResponse{Error: err}
 	}
 
-	UpdateRowChange, directlyColumns := tableSchema.BuildRequestUpdateColumns(columns)
+	// 存在版本字段的时候要求版本没有被修改, 并且版本加1, 版本为0的时候允许更新没有版本列的行
+	versionFilter := tableSchema.BuildVersionFilter(row)
+	if version, _ := tableSchema.GetVersion(row); nil != versionFilter && 0 == version {
+		versionFilter = versionFilter.PassIfMissing()
+	}
+	condition, err := t.buildWriteCondition(tableSchema, aliTableStore.RowExistenceExpectation_IGNORE, versionFilter)
+	if err != nil {
+		return UpdateOneResponse{Error: err}
+	}
+
+	columns = tableSchema.WithAutoUpdateTime(columns, t.Now())
+	if nil != versionFilter {
+		columns = tableSchema.WithVersionIncrement(columns)
+	}
+	UpdateRowChange, directlyColumns, err := tableSchema.BuildRequestUpdateColumns(columns)
+	if err != nil {
+		return UpdateOneResponse{Error: err}
+	}
+
+	primaryKey, err := tableSchema.BuildRequestPrimaryKey(row)
+	if err != nil {
+		return UpdateOneResponse{Error: err}
+	}
 
 	request := new(aliTableStore.UpdateRowRequest)
 	request.UpdateRowChange = UpdateRowChange
 	request.UpdateRowChange.TableName = row.TableName()
-	request.UpdateRowChange.PrimaryKey = tableSchema.BuildRequestPrimaryKey(row)
-	request.UpdateRowChange.SetCondition(aliTableStore.RowExistenceExpectation_IGNORE)
+	request.UpdateRowChange.PrimaryKey = primaryKey
+	request.UpdateRowChange.Condition = condition
 
 	for _, option := range options {
 		option(request)
 	}
 
-	response, err := t.UpdateRow(request)
-	if err != nil {
+	response, err := t.updateRow(ctx, request)
+	if nil != versionFilter && err != nil {
+		return UpdateOneResponse{Error: staleObjectError(err, nil == t.where), Response: response}
+	} else if err != nil {
 		return UpdateOneResponse{Error: err, Response: response}
 	}
 
-	tableSchema.FillRowColumns(row, directlyColumns)
-	tableSchema.FillRow(row, ([]*aliTableStore.PrimaryKeyColumn{}), response.Columns)
+	if err = tableSchema.FillRowColumns(row, directlyColumns); err != nil {
+		return UpdateOneResponse{Error: err, Response: response}
+	}
+	if err = tableSchema.FillRow(row, ([]*aliTableStore.PrimaryKeyColumn{}), response.Columns); err != nil {
+		return UpdateOneResponse{Error: err, Response: response}
+	}
 
 	return UpdateOneResponse{Error: err, Response: response}
 }
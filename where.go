@@ -0,0 +1,35 @@
+package tablestore
+
+import (
+	aliTableStore "github.com/aliyun/aliyun-tablestore-go-sdk/tablestore"
+	"github.com/hughcube-go/tablestore/schema"
+)
+
+// Where 返回一个带条件的客户端, 写入时作为行条件, 读取时作为过滤器, 多次调用的条件用And合并
+//
+//	client.Where(schema.ExistsRow(), schema.Eq("Status", 1)).UpdateOne(row, columns)
+//	client.Where(schema.Gt("UpdatedAt", at)).QueryRange(&list, start, end, 100)
+func (t *TableStore) Where(filters ...*schema.Filter) *TableStore {
+	client := *t
+	client.where = schema.And(append([]*schema.Filter{t.where}, filters...)...)
+	return &client
+}
+
+// 读取时的过滤器, 包含软删除的过滤器和Where的条件
+func (t *TableStore) buildReadFilter(tableSchema *schema.Schema) (aliTableStore.ColumnFilter, error) {
+	filter, err := tableSchema.BuildFilter(t.where)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.unscoped {
+		return filter, nil
+	}
+
+	return schema.AndColumnFilter(tableSchema.BuildSoftDeleteFilter(), filter), nil
+}
+
+// 写入时的行条件, Where中没有ExistsRow或者NotExistsRow的时候使用expectation, filters和Where的条件用And合并
+func (t *TableStore) buildWriteCondition(tableSchema *schema.Schema, expectation aliTableStore.RowExistenceExpectation, filters ...*schema.Filter) (*aliTableStore.RowCondition, error) {
+	return tableSchema.BuildCondition(schema.And(append([]*schema.Filter{t.where}, filters...)...), expectation)
+}